@@ -4,15 +4,18 @@ import (
 	"bitburst-assessment-task/internal/client"
 	"bitburst-assessment-task/internal/db"
 	"bitburst-assessment-task/internal/server"
+	"bitburst-assessment-task/internal/webhook"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 
 	"github.com/rs/zerolog"
@@ -42,6 +45,8 @@ type config struct {
 	Client client.Config `mapstructure:"client"`
 
 	Database db.Config `mapstructure:"database"`
+
+	Webhook webhook.Config `mapstructure:"webhook"`
 }
 
 // setConfigDetails sets command flags, defaults and envs and default config file details
@@ -90,17 +95,232 @@ func setConfigDetails(v *viper.Viper, p *pflag.FlagSet) {
 	_ = v.BindPFlag("server.write_timeout", p.Lookup("server-write-timeout"))
 	v.SetDefault("server.write_timeout", 0)
 
-	p.Duration("server-shutdown-timeout", time.Second*5, "timeout duration for the server to shutdown")
+	p.Duration("server-shutdown-timeout", time.Second*5, "timeout duration for the server to shutdown, it includes readiness drain delay, waiting for callbacks being processed and background jobs to finish")
 	_ = v.BindPFlag("server.shutdown_timeout", p.Lookup("server-shutdown-timeout"))
 	v.SetDefault("server.shutdown_timeout", time.Second*5)
 
+	p.Duration("server-readiness-drain-delay", time.Second*2, "how long server keeps serving after it's reported as not ready on shutdown")
+	_ = v.BindPFlag("server.readiness_drain_delay", p.Lookup("server-readiness-drain-delay"))
+	v.SetDefault("server.readiness_drain_delay", time.Second*2)
+
+	p.Int64("server-max-body-size", 1<<20, "max size of /callback request body in bytes, 0 means no limit")
+	_ = v.BindPFlag("server.max_body_size", p.Lookup("server-max-body-size"))
+	v.SetDefault("server.max_body_size", 1<<20)
+
+	p.Int("server-max-object-ids", 1000, "max amount of object ids in one callback, 0 means no limit")
+	_ = v.BindPFlag("server.max_object_ids", p.Lookup("server-max-object-ids"))
+	v.SetDefault("server.max_object_ids", 1000)
+
+	p.Int("server-inbox-queue-size", 100, "max amount of accepted callbacks waiting in memory to be processed, callbacks that don't fit are replayed from inbox later")
+	_ = v.BindPFlag("server.inbox_queue_size", p.Lookup("server-inbox-queue-size"))
+	v.SetDefault("server.inbox_queue_size", 100)
+
+	p.Duration("server-inbox-replay-interval", time.Second*10, "interval of replaying callbacks that weren't processed yet")
+	_ = v.BindPFlag("server.inbox_replay_interval", p.Lookup("server-inbox-replay-interval"))
+	v.SetDefault("server.inbox_replay_interval", time.Second*10)
+
+	p.Duration("server-inbox-replay-min-age", time.Second*5, "min age of callbacks that weren't processed yet to be replayed, together with replay interval it must be shorter than max replay age")
+	_ = v.BindPFlag("server.inbox_replay_min_age", p.Lookup("server-inbox-replay-min-age"))
+	v.SetDefault("server.inbox_replay_min_age", time.Second*5)
+
+	p.Duration("server-inbox-retention", time.Hour, "how long processed callbacks are kept in inbox")
+	_ = v.BindPFlag("server.inbox_retention", p.Lookup("server-inbox-retention"))
+	v.SetDefault("server.inbox_retention", time.Hour)
+
+	p.Duration("server-inbox-max-replay-age", 0, "max age of inbox entry that is processed, older entries are dropped, so they don't bring back deleted objects, 0 means it's the same as database retention")
+	_ = v.BindPFlag("server.inbox_max_replay_age", p.Lookup("server-inbox-max-replay-age"))
+	v.SetDefault("server.inbox_max_replay_age", 0)
+
+	p.Duration("server-idempotency-key-ttl", time.Hour*24, "how long idempotency keys of callbacks are remembered, callback retried with the same key during this period isn't processed again")
+	_ = v.BindPFlag("server.idempotency_key_ttl", p.Lookup("server-idempotency-key-ttl"))
+	v.BindEnv("server.idempotency_key_ttl", "SERVER_IDEMPOTENCY_KEY_TTL")
+	v.SetDefault("server.idempotency_key_ttl", time.Hour*24)
+
+	p.StringSlice("server-callback-secrets", nil, "shared secrets that callbacks are signed with, callback signed with any of them is accepted, signatures aren't verified if it's empty")
+	_ = v.BindPFlag("server.callback_secrets", p.Lookup("server-callback-secrets"))
+	v.BindEnv("server.callback_secrets", "SERVER_CALLBACK_SECRETS")
+	v.SetDefault("server.callback_secrets", []string{})
+
+	p.Duration("server-callback-signature-tolerance", time.Minute*5, "max difference between callback signature timestamp and server time, older callbacks are rejected")
+	_ = v.BindPFlag("server.callback_signature_tolerance", p.Lookup("server-callback-signature-tolerance"))
+	v.SetDefault("server.callback_signature_tolerance", time.Minute*5)
+
+	p.Duration("server-processing-timeout", time.Second*16, "max time of looking up objects of one callback, storing them has the same timeout separately, it must cover all attempts of a lookup with backoffs between them")
+	_ = v.BindPFlag("server.processing_timeout", p.Lookup("server-processing-timeout"))
+	v.BindEnv("server.processing_timeout", "SERVER_PROCESSING_TIMEOUT")
+	v.SetDefault("server.processing_timeout", time.Second*16)
+
+	p.Int("server-deferred-size", 10000, "max amount of object ids which status couldn't be fetched in time queued to be looked up again, 0 disables the queue")
+	_ = v.BindPFlag("server.deferred.size", p.Lookup("server-deferred-size"))
+	v.SetDefault("server.deferred.size", 10000)
+
+	p.Duration("server-deferred-max-age", time.Minute*5, "how long deferred object ids are looked up again before they are dropped")
+	_ = v.BindPFlag("server.deferred.max_age", p.Lookup("server-deferred-max-age"))
+	v.SetDefault("server.deferred.max_age", time.Minute*5)
+
+	p.Duration("server-deferred-interval", time.Second*10, "how often deferred object ids are looked up")
+	_ = v.BindPFlag("server.deferred.interval", p.Lookup("server-deferred-interval"))
+	v.SetDefault("server.deferred.interval", time.Second*10)
+
+	p.String("server-deferred-path", "", "file where deferred object ids are persisted to survive restarts, they are kept only in memory if it's empty")
+	_ = v.BindPFlag("server.deferred.path", p.Lookup("server-deferred-path"))
+	v.BindEnv("server.deferred.path", "SERVER_DEFERRED_PATH")
+	v.SetDefault("server.deferred.path", "")
+
+	// static api keys are set in config file only, as a list of name, key and scopes
+	p.Bool("server-auth-disabled", false, "serve read and admin routes without authentication, server doesn't start without credentials otherwise")
+	_ = v.BindPFlag("server.auth.disabled", p.Lookup("server-auth-disabled"))
+	v.BindEnv("server.auth.disabled", "SERVER_AUTH_DISABLED")
+	v.SetDefault("server.auth.disabled", false)
+
+	p.String("server-auth-jwks-path", "", "JSON Web Key Set file with public keys that bearer tokens are verified with, it's read again when it changes")
+	_ = v.BindPFlag("server.auth.jwks_path", p.Lookup("server-auth-jwks-path"))
+	v.BindEnv("server.auth.jwks_path", "SERVER_AUTH_JWKS_PATH")
+	v.SetDefault("server.auth.jwks_path", "")
+
+	p.String("server-auth-issuer", "", "issuer that bearer tokens must be issued by, it isn't checked if it's empty")
+	_ = v.BindPFlag("server.auth.issuer", p.Lookup("server-auth-issuer"))
+	v.SetDefault("server.auth.issuer", "")
+
+	p.String("server-auth-audience", "", "audience that bearer tokens must be issued for, it isn't checked if it's empty")
+	_ = v.BindPFlag("server.auth.audience", p.Lookup("server-auth-audience"))
+	v.SetDefault("server.auth.audience", "")
+
+	p.String("server-tls-cert-file", "", "certificate file of http listener, server listens plain http if it's empty, certificate is reloaded when file changes")
+	_ = v.BindPFlag("server.tls.cert_file", p.Lookup("server-tls-cert-file"))
+	v.BindEnv("server.tls.cert_file", "SERVER_TLS_CERT_FILE")
+	v.SetDefault("server.tls.cert_file", "")
+
+	p.String("server-tls-key-file", "", "private key file of certificate of http listener")
+	_ = v.BindPFlag("server.tls.key_file", p.Lookup("server-tls-key-file"))
+	v.BindEnv("server.tls.key_file", "SERVER_TLS_KEY_FILE")
+	v.SetDefault("server.tls.key_file", "")
+
+	p.String("server-tls-min-version", "1.2", "min TLS version accepted from clients: 1.0, 1.1, 1.2 or 1.3")
+	_ = v.BindPFlag("server.tls.min_version", p.Lookup("server-tls-min-version"))
+	v.SetDefault("server.tls.min_version", "1.2")
+
+	p.String("server-tls-client-ca-file", "", "CA bundle that client certificates are verified against, clients aren't asked for certificates if it's empty")
+	_ = v.BindPFlag("server.tls.client_ca_file", p.Lookup("server-tls-client-ca-file"))
+	v.BindEnv("server.tls.client_ca_file", "SERVER_TLS_CLIENT_CA_FILE")
+	v.SetDefault("server.tls.client_ca_file", "")
+
+	p.String("server-tls-client-auth", "require", "policy of client certificates when CA bundle is set: require or verify_if_given")
+	_ = v.BindPFlag("server.tls.client_auth", p.Lookup("server-tls-client-auth"))
+	v.SetDefault("server.tls.client_auth", "require")
+
 	// for client
 	p.String("client-tester-service-address", "127.0.0.1:9010", "listen address of tester service")
 	_ = v.BindPFlag("client.tester_service_address", p.Lookup("client-tester-service-address"))
 	v.BindEnv("client.tester_service_address", "CLIENT_TESTER_SERVICE_ADDRESS")
 	v.SetDefault("client.tester_service_address", "127.0.0.1:9010")
 
+	p.Int("client-max-in-flight", 200, "max amount of concurrent lookups to tester service, 0 means no limit")
+	_ = v.BindPFlag("client.max_in_flight", p.Lookup("client-max-in-flight"))
+	v.SetDefault("client.max_in_flight", 200)
+
+	p.Int("client-queue-size", 1000, "max amount of lookups waiting for a free slot, lookups that don't fit are rejected")
+	_ = v.BindPFlag("client.queue_size", p.Lookup("client-queue-size"))
+	v.SetDefault("client.queue_size", 1000)
+
+	p.Int("client-max-conns-per-host", 200, "max amount of connections to tester service, 0 means no limit")
+	_ = v.BindPFlag("client.max_conns_per_host", p.Lookup("client-max-conns-per-host"))
+	v.SetDefault("client.max_conns_per_host", 200)
+
+	p.Int("client-max-idle-conns-per-host", 200, "max amount of idle keep-alive connections to tester service")
+	_ = v.BindPFlag("client.max_idle_conns_per_host", p.Lookup("client-max-idle-conns-per-host"))
+	v.SetDefault("client.max_idle_conns_per_host", 200)
+
+	p.Duration("client-timeout", time.Second*5, "max time of one request to tester service including reading response body")
+	_ = v.BindPFlag("client.timeout", p.Lookup("client-timeout"))
+	v.BindEnv("client.timeout", "CLIENT_TIMEOUT")
+	v.SetDefault("client.timeout", time.Second*5)
+
+	p.Duration("client-dial-timeout", time.Second*2, "max time of connecting to tester service, 0 means default timeout of http transport")
+	_ = v.BindPFlag("client.dial_timeout", p.Lookup("client-dial-timeout"))
+	v.BindEnv("client.dial_timeout", "CLIENT_DIAL_TIMEOUT")
+	v.SetDefault("client.dial_timeout", time.Second*2)
+
+	p.Duration("client-tls-handshake-timeout", time.Second*2, "max time of TLS handshake with tester service, 0 means default timeout of http transport")
+	_ = v.BindPFlag("client.tls_handshake_timeout", p.Lookup("client-tls-handshake-timeout"))
+	v.BindEnv("client.tls_handshake_timeout", "CLIENT_TLS_HANDSHAKE_TIMEOUT")
+	v.SetDefault("client.tls_handshake_timeout", time.Second*2)
+
+	p.Duration("client-response-header-timeout", 0, "max time of waiting for response headers of tester service after request is sent, 0 means it's limited only by client timeout")
+	_ = v.BindPFlag("client.response_header_timeout", p.Lookup("client-response-header-timeout"))
+	v.BindEnv("client.response_header_timeout", "CLIENT_RESPONSE_HEADER_TIMEOUT")
+	v.SetDefault("client.response_header_timeout", 0)
+
+	p.Int("client-retry-max-attempts", 3, "max amount of attempts to get object status, 1 disables retries")
+	_ = v.BindPFlag("client.retry.max_attempts", p.Lookup("client-retry-max-attempts"))
+	v.SetDefault("client.retry.max_attempts", 3)
+
+	p.Duration("client-retry-base-backoff", time.Millisecond*100, "backoff before the first retry, it's doubled for each next retry")
+	_ = v.BindPFlag("client.retry.base_backoff", p.Lookup("client-retry-base-backoff"))
+	v.SetDefault("client.retry.base_backoff", time.Millisecond*100)
+
+	p.Duration("client-retry-max-backoff", time.Second, "max backoff between retries")
+	_ = v.BindPFlag("client.retry.max_backoff", p.Lookup("client-retry-max-backoff"))
+	v.SetDefault("client.retry.max_backoff", time.Second)
+
+	p.Float64("client-retry-jitter", 0.5, "fraction of backoff in range [0, 1] that is randomized")
+	_ = v.BindPFlag("client.retry.jitter", p.Lookup("client-retry-jitter"))
+	v.SetDefault("client.retry.jitter", 0.5)
+
+	p.IntSlice("client-retry-retryable-status-codes", []int{429, 500, 502, 503, 504}, "response status codes of tester service after which lookup is retried")
+	_ = v.BindPFlag("client.retry.retryable_status_codes", p.Lookup("client-retry-retryable-status-codes"))
+	v.SetDefault("client.retry.retryable_status_codes", []int{429, 500, 502, 503, 504})
+
+	p.Bool("client-batch-enabled", false, "look up objects with POST /objects:batchGet of tester service, lookups fall back to GET /objects/:id if it isn't supported")
+	_ = v.BindPFlag("client.batch.enabled", p.Lookup("client-batch-enabled"))
+	v.BindEnv("client.batch.enabled", "CLIENT_BATCH_ENABLED")
+	v.SetDefault("client.batch.enabled", false)
+
+	p.Int("client-batch-max-size", 200, "max amount of object ids in a single batch lookup")
+	_ = v.BindPFlag("client.batch.max_size", p.Lookup("client-batch-max-size"))
+	v.SetDefault("client.batch.max_size", 200)
+
+	p.Duration("client-cache-ttl", 0, "how long looked up object status is reused by following callbacks, 0 disables the cache")
+	_ = v.BindPFlag("client.cache.ttl", p.Lookup("client-cache-ttl"))
+	v.BindEnv("client.cache.ttl", "CLIENT_CACHE_TTL")
+	v.SetDefault("client.cache.ttl", 0)
+
+	p.Int("client-cache-size", 10000, "max amount of cached object statuses, least recently used statuses are evicted")
+	_ = v.BindPFlag("client.cache.size", p.Lookup("client-cache-size"))
+	v.SetDefault("client.cache.size", 10000)
+
+	p.Float64("client-breaker-failure-rate", 0.5, "fraction of failed requests to tester service in range (0, 1] that opens circuit breaker, 0 disables the breaker")
+	_ = v.BindPFlag("client.breaker.failure_rate", p.Lookup("client-breaker-failure-rate"))
+	v.BindEnv("client.breaker.failure_rate", "CLIENT_BREAKER_FAILURE_RATE")
+	v.SetDefault("client.breaker.failure_rate", 0.5)
+
+	p.Int("client-breaker-min-requests", 20, "min amount of requests during the window before failure rate is checked")
+	_ = v.BindPFlag("client.breaker.min_requests", p.Lookup("client-breaker-min-requests"))
+	v.SetDefault("client.breaker.min_requests", 20)
+
+	p.Duration("client-breaker-window", time.Second*10, "period over which failure rate is counted")
+	_ = v.BindPFlag("client.breaker.window", p.Lookup("client-breaker-window"))
+	v.SetDefault("client.breaker.window", time.Second*10)
+
+	p.Duration("client-breaker-open-duration", time.Second*30, "how long lookups fail fast before tester service is probed")
+	_ = v.BindPFlag("client.breaker.open_duration", p.Lookup("client-breaker-open-duration"))
+	v.SetDefault("client.breaker.open_duration", time.Second*30)
+
+	p.Int("client-breaker-half-open-probes", 3, "amount of requests that are let through to probe tester service, breaker closes if all of them succeed")
+	_ = v.BindPFlag("client.breaker.half_open_probes", p.Lookup("client-breaker-half-open-probes"))
+	v.SetDefault("client.breaker.half_open_probes", 3)
+
 	// for database
+	p.String("database-driver", "postgres", "storage backend: postgres, sqlite for single node deployments, or memory for tests and small deployments where losing data on restart is acceptable")
+	v.BindPFlag("database.driver", p.Lookup("database-driver"))
+	v.BindEnv("database.driver", "DATABASE_DRIVER")
+	v.SetDefault("database.driver", "postgres")
+
+	p.String("database-path", "./bitburst.db", "path of sqlite database file, it's created if it doesn't exist")
+	v.BindPFlag("database.path", p.Lookup("database-path"))
+	v.BindEnv("database.path", "DATABASE_PATH")
+	v.SetDefault("database.path", "./bitburst.db")
+
 	p.StringP("database-host", "h", "127.0.0.1", "database host")
 	v.BindPFlag("database.host", p.Lookup("database-host"))
 	v.BindEnv("database.host", "DATABASE_HOST")
@@ -130,9 +350,107 @@ func setConfigDetails(v *viper.Viper, p *pflag.FlagSet) {
 	v.BindPFlag("database.sslmode", p.Lookup("database-sslmode"))
 	v.SetDefault("database.sslmode", "disable")
 
-	p.Int("database-migration-version", 3, "database migration version")
+	p.Int("database-migration-version", 7, "database migration version")
 	v.BindPFlag("database.migration_version", p.Lookup("database-migration-version"))
-	v.SetDefault("database.migration_version", 3)
+	v.SetDefault("database.migration_version", 7)
+
+	p.Duration("database-retention", time.Second*30, "objects that weren't seen for this duration are deleted")
+	v.BindPFlag("database.retention", p.Lookup("database-retention"))
+	v.BindEnv("database.retention", "DATABASE_RETENTION")
+	v.SetDefault("database.retention", time.Second*30)
+
+	p.Duration("database-sweep-interval", time.Second*30, "how often objects that weren't seen during retention are deleted, must not be longer than retention")
+	v.BindPFlag("database.sweep_interval", p.Lookup("database-sweep-interval"))
+	v.BindEnv("database.sweep_interval", "DATABASE_SWEEP_INTERVAL")
+	v.SetDefault("database.sweep_interval", time.Second*30)
+
+	p.Duration("database-history-retention", time.Hour*24*7, "how long status changes of objects are kept, they are pruned on sweeps, 0 keeps them forever")
+	v.BindPFlag("database.history_retention", p.Lookup("database-history-retention"))
+	v.BindEnv("database.history_retention", "DATABASE_HISTORY_RETENTION")
+	v.SetDefault("database.history_retention", time.Hour*24*7)
+
+	p.String("database-redis-address", "", "address of redis server used as a cache of objects status, cache is disabled if it's empty")
+	v.BindPFlag("database.redis.address", p.Lookup("database-redis-address"))
+	v.BindEnv("database.redis.address", "DATABASE_REDIS_ADDRESS")
+	v.SetDefault("database.redis.address", "")
+
+	p.String("database-redis-password", "", "password of redis server")
+	v.BindPFlag("database.redis.password", p.Lookup("database-redis-password"))
+	v.BindEnv("database.redis.password", "DATABASE_REDIS_PASSWORD")
+	v.SetDefault("database.redis.password", "")
+
+	p.Int("database-redis-db", 0, "redis database number")
+	v.BindPFlag("database.redis.db", p.Lookup("database-redis-db"))
+	v.SetDefault("database.redis.db", 0)
+
+	p.String("database-redis-key-prefix", "bitburst:", "prefix of all redis keys")
+	v.BindPFlag("database.redis.key_prefix", p.Lookup("database-redis-key-prefix"))
+	v.SetDefault("database.redis.key_prefix", "bitburst:")
+
+	p.Bool("database-legacy-upsert", false, "only store online objects, and don't refresh last_seen of offline objects")
+	v.BindPFlag("database.legacy_upsert", p.Lookup("database-legacy-upsert"))
+	v.SetDefault("database.legacy_upsert", false)
+
+	// for webhooks
+	p.Int("webhook-workers", 4, "amount of event batches that are delivered to webhooks concurrently")
+	_ = v.BindPFlag("webhook.workers", p.Lookup("webhook-workers"))
+	v.SetDefault("webhook.workers", 4)
+
+	p.Int("webhook-queue-size", 100, "max amount of event batches waiting for delivery, batches that don't fit are saved as dead letters")
+	_ = v.BindPFlag("webhook.queue_size", p.Lookup("webhook-queue-size"))
+	v.SetDefault("webhook.queue_size", 100)
+
+	p.Duration("webhook-timeout", time.Second*5, "timeout of one webhook delivery attempt")
+	_ = v.BindPFlag("webhook.timeout", p.Lookup("webhook-timeout"))
+	v.SetDefault("webhook.timeout", time.Second*5)
+
+	p.Int("webhook-max-attempts", 5, "max amount of webhook delivery attempts, after that payload is saved as dead letter")
+	_ = v.BindPFlag("webhook.max_attempts", p.Lookup("webhook-max-attempts"))
+	v.SetDefault("webhook.max_attempts", 5)
+
+	p.Duration("webhook-base-backoff", time.Second, "backoff before the first webhook delivery retry, it's doubled for each next retry")
+	_ = v.BindPFlag("webhook.base_backoff", p.Lookup("webhook-base-backoff"))
+	v.SetDefault("webhook.base_backoff", time.Second)
+
+	p.Duration("webhook-max-backoff", time.Second*30, "max backoff between webhook delivery retries")
+	_ = v.BindPFlag("webhook.max_backoff", p.Lookup("webhook-max-backoff"))
+	v.SetDefault("webhook.max_backoff", time.Second*30)
+}
+
+// newStorage constructs storage backend chosen in configuration
+func newStorage(conf *db.Config) (db.Storage, error) {
+	switch conf.Driver {
+	case "memory":
+		log.Logger.Warn().Msg("using in-memory storage, all data will be lost on restart")
+		return db.NewMemory(conf, &log.Logger)
+	case "sqlite":
+		log.Logger.Info().Str("path", conf.Path).Msg("opening sqlite database")
+		return db.NewSQLite(conf, &log.Logger)
+	case "postgres", "":
+	default:
+		return nil, errors.Errorf("unknown database driver %q", conf.Driver)
+	}
+
+	log.Logger.Info().Msg("connecting to database")
+	var (
+		database *db.DB
+		err      error
+	)
+	for i := 0; i < 3; i++ {
+		database, err = db.New(conf, &log.Logger)
+		if err != nil {
+			// sometimes database may be off for some secs, so retrying is a good practice
+			if strings.Contains(err.Error(), "connection refused") || strings.Contains(err.Error(), "failed to ping database") {
+				log.Logger.Warn().Err(err).Msg("couldn't establish database connection, retrying in 5 secs...")
+				time.Sleep(5 * time.Second)
+				continue
+			}
+			return nil, err
+		}
+		return database, nil
+	}
+
+	return nil, errors.WithMessage(err, "failed to establish database connection, database is off")
 }
 
 // Will be set using ldflags
@@ -195,6 +513,11 @@ func main() {
 		return
 	}
 
+	// callbacks older than retention of objects aren't processed by default
+	if conf.Server.InboxMaxReplayAge <= 0 {
+		conf.Server.InboxMaxReplayAge = conf.Database.Retention
+	}
+
 	// create new file for storing logs
 	conf.Log.file, err = os.Create(conf.Log.Path)
 	if err != nil {
@@ -228,31 +551,10 @@ func main() {
 	// set verbosity level
 	zerolog.SetGlobalLevel(zerolog.Level(conf.Log.Level))
 
-	// set up database
-	log.Logger.Info().Msg("connecting to database")
-	var (
-		database *db.DB
-		ok       bool
-	)
-	for i := 0; i < 3; i++ {
-		database, err = db.New(&conf.Database, &log.Logger)
-		if err != nil {
-			// sometimes database may be off for some secs, so retrying is a good practice
-			if strings.Contains(err.Error(), "connection refused") || strings.Contains(err.Error(), "failed to ping database") {
-				log.Logger.Warn().Err(err).Msg("couldn't establish database connection, retrying in 5 secs...")
-				time.Sleep(5 * time.Second)
-				continue
-			} else {
-				log.Logger.Err(err).Msg("failed to establish database connection")
-				retcode = -1
-				return
-			}
-		}
-		ok = true
-		break
-	}
-	if !ok {
-		log.Logger.Err(err).Msg("failed to establish database connection, database is off")
+	// set up storage
+	database, err := newStorage(&conf.Database)
+	if err != nil {
+		log.Logger.Err(err).Str("driver", conf.Database.Driver).Msg("failed to set up storage")
 		retcode = -1
 		return
 	}
@@ -263,6 +565,22 @@ func main() {
 		}
 	}()
 
+	// put redis cache of objects status in front of storage, if it's configured
+	if conf.Database.Redis.Address != "" {
+		log.Logger.Info().Str("address", conf.Database.Redis.Address).Msg("connecting to redis")
+		cache, err := db.NewRedisCache(&conf.Database, database, &log.Logger)
+		if err != nil {
+			log.Logger.Err(err).Msg("failed to set up redis cache")
+			retcode = -1
+			return
+		}
+		database = cache
+	}
+
+	// set up webhooks dispatcher, it's notified about object events by database
+	dispatcher := webhook.New(&conf.Webhook, database, &log.Logger)
+	database.OnEvents(dispatcher.Notify)
+
 	// set up client
 	cli := client.New(&conf.Client, &log.Logger)
 
@@ -274,25 +592,77 @@ func main() {
 	srvErrChan := make(chan error)
 	go srv.Start(srvErrChan)
 
-	// run a background job that will delete objects that weren't seen for 30 seconds
+	// background jobs are tracked, so they are finished before database is closed
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go database.DeleteNotSeenObjects(ctx)
+	jobs := &sync.WaitGroup{}
+	runJob := func(job func(ctx context.Context)) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job(ctx)
+		}()
+	}
+
+	// run a background job that will delete objects that weren't seen during retention window
+	runJob(database.DeleteNotSeenObjects)
+
+	// run a background job that will process accepted callbacks and replay unfinished ones
+	runJob(srv.ProcessCallbacks)
+
+	// run a background job that will look up objects which status couldn't be fetched in time
+	runJob(srv.ProcessDeferredLookups)
+
+	// run a background job that will deliver object events to webhook subscriptions
+	runJob(dispatcher.Run)
 
 	// catch interrupt signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	var shutdownCtx context.Context
 	select {
 	case q := <-quit:
 		log.Logger.Info().Str("signal", q.String()).Msg("received signal, closing server and other opened resources")
 
-		// close server
-		if err := srv.Close(); err != nil {
+		// one deadline covers the whole shutdown, so database is closed before orchestrator kills the process
+		var shutdownCancel context.CancelFunc
+		shutdownCtx, shutdownCancel = context.WithTimeout(context.Background(), conf.Server.ShutdownTimeout)
+		defer shutdownCancel()
+
+		// close server, it stops accepting callbacks and waits for ones being processed
+		if err := srv.Close(shutdownCtx); err != nil {
 			log.Logger.Warn().Err(err).Msg("failed to close the server")
 			retcode = -1
 		}
 	case err := <-srvErrChan:
 		log.Logger.Err(err).Msg("failed to start the server, closing other opened resources")
+
+		var shutdownCancel context.CancelFunc
+		shutdownCtx, shutdownCancel = context.WithTimeout(context.Background(), conf.Server.ShutdownTimeout)
+		defer shutdownCancel()
+	}
+
+	// stop background jobs and wait for them until the rest of shutdown timeout, database is closed only after that
+	cancel()
+	if !waitGroupContext(shutdownCtx, jobs) {
+		log.Logger.Warn().Msg("background jobs weren't finished before shutdown timeout")
+		retcode = -1
+	}
+}
+
+// waitGroupContext waits for wait group until context is done, it returns false if context is done first
+func waitGroupContext(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
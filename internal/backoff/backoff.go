@@ -0,0 +1,47 @@
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Exponential returns a jittered time to wait before given attempt, attempts start from 1.
+// Backoff before attempt n is min(base * 2^(n-2), max), jitter is a fraction of it in range [0, 1] that is randomized,
+// so concurrent retries don't happen at the same moment.
+func Exponential(base, max time.Duration, jitter float64, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt-1 && (max <= 0 || d < max); i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+
+	if jitter > 0 && d > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(rand.Int63n(int64(float64(d)*jitter) + 1))
+	}
+
+	return d
+}
+
+// Sleep waits for d, it returns false if context is done
+// or it's deadline will pass before d elapses, so there is no sense in waiting
+func Sleep(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bitburst-assessment-task/internal/metrics"
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// BatchConfig holds settings of batch lookups, when they are enabled statuses of many objects
+// are requested with one POST /objects:batchGet request instead of one GET /objects/:id request per object
+type BatchConfig struct {
+	// Enabled turns batch lookups on, ids that couldn't be looked up in a batch are looked up one by one,
+	// so it's safe to enable it even if tester service doesn't support batches
+	Enabled bool `mapstructure:"enabled"`
+
+	// MaxSize is a max amount of ids in one batch request
+	MaxSize int `mapstructure:"max_size"`
+}
+
+const (
+	// defaultBatchMaxSize is used when max batch size isn't set in config
+	defaultBatchMaxSize = 200
+
+	// batchReprobeInterval is how long batch lookups aren't tried after tester service reported it doesn't support them,
+	// so client picks up batch support after tester service is upgraded
+	batchReprobeInterval = 5 * time.Minute
+)
+
+// batchReqBody is a request body of tester service /objects:batchGet route
+type batchReqBody struct {
+	IDs []int32 `json:"ids"`
+}
+
+// batchRespBody is a response body of tester service /objects:batchGet route
+type batchRespBody struct {
+	Objects []*ObjectsRespBody `json:"objects"`
+}
+
+// batchSupported checks if batch lookups should be tried
+func (cli *Client) batchSupported() bool {
+	return cli.conf.Batch.Enabled && time.Now().UnixNano() >= atomic.LoadInt64(&cli.batchUnsupportedUntil)
+}
+
+// isBatchUnsupported checks if tester service responded that it doesn't know batch route
+func isBatchUnsupported(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	return statusErr.Code == http.StatusNotFound || statusErr.Code == http.StatusMethodNotAllowed || statusErr.Code == http.StatusNotImplemented
+}
+
+// doBatches looks up ids in batches concurrently and fills results of found ids,
+// results of ids that weren't found in batches are left nil
+func (cli *Client) doBatches(ctx context.Context, objectIDs []int32, results []*Result) {
+	maxSize := cli.conf.Batch.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultBatchMaxSize
+	}
+
+	wg := &sync.WaitGroup{}
+	for start := 0; start < len(objectIDs); start += maxSize {
+		end := start + maxSize
+		if end > len(objectIDs) {
+			end = len(objectIDs)
+		}
+
+		wg.Add(1)
+		go func(ids []int32, results []*Result) {
+			defer wg.Done()
+
+			cli.lookupBatch(ctx, ids, results)
+		}(objectIDs[start:end], results[start:end])
+	}
+
+	wg.Wait()
+}
+
+// lookupBatch gets statuses of ids with one batch request, retrying failed attempts,
+// and fills results of ids that tester service responded with
+func (cli *Client) lookupBatch(ctx context.Context, ids []int32, results []*Result) {
+	start := time.Now()
+
+	var (
+		statuses map[int32]*ObjectsRespBody
+		err      error
+		attempts int
+	)
+	for {
+		attempts++
+
+		var retryable bool
+		statuses, retryable, err = cli.attemptBatch(ctx, ids)
+		if err == nil || !retryable || attempts >= cli.conf.Retry.MaxAttempts {
+			break
+		}
+
+		if !cli.conf.Retry.wait(ctx, attempts+1) {
+			break
+		}
+		cli.logger.Debug().Err(err).Int("count", len(ids)).Int("attempt", attempts+1).Msg("retrying to get objects status in batch")
+	}
+
+	if err != nil {
+		if isBatchUnsupported(err) {
+			atomic.StoreInt64(&cli.batchUnsupportedUntil, time.Now().Add(batchReprobeInterval).UnixNano())
+			cli.logger.Info().Err(err).Msg("tester service doesn't support batch lookups, falling back to lookups by id")
+			return
+		}
+
+		cli.logger.Debug().Err(err).Int("count", len(ids)).Int("attempts", attempts).Msg("failed to get objects status in batch, falling back to lookups by id")
+		return
+	}
+
+	duration := time.Since(start).Seconds()
+	for i, id := range ids {
+		status, ok := statuses[id]
+		if !ok {
+			continue
+		}
+
+		results[i] = &Result{ID: id, Status: status, Attempts: attempts}
+		if cli.cache != nil {
+			cli.cache.set(status)
+		}
+		metrics.LookupDuration.WithLabelValues(outcome(nil)).Observe(duration)
+	}
+}
+
+// attemptBatch sends one batch request to get statuses of ids, and reports if it's worth to retry on failure.
+// Statuses of ids that weren't requested are dropped.
+func (cli *Client) attemptBatch(ctx context.Context, ids []int32) (map[int32]*ObjectsRespBody, bool, error) {
+	body, err := json.Marshal(&batchReqBody{IDs: ids})
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to encode request body")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cli.conf.TesterServiceAddress+"/objects:batchGet", bytes.NewReader(body))
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to construct request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp batchRespBody
+	if retryable, err := cli.send(req, &resp); err != nil {
+		return nil, retryable, err
+	}
+
+	requested := make(map[int32]struct{}, len(ids))
+	for _, id := range ids {
+		requested[id] = struct{}{}
+	}
+
+	statuses := make(map[int32]*ObjectsRespBody, len(resp.Objects))
+	for _, obj := range resp.Objects {
+		if obj == nil {
+			continue
+		}
+		if _, ok := requested[obj.ID]; !ok {
+			cli.logger.Debug().Int32("id", obj.ID).Msg("tester service responded with status of object that wasn't requested")
+			continue
+		}
+		statuses[obj.ID] = obj
+	}
+
+	return statuses, false, nil
+}
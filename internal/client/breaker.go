@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bitburst-assessment-task/internal/metrics"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// ErrCircuitOpen is returned when lookups fail fast, because tester service is considered down
+var ErrCircuitOpen = errors.New("tester service circuit is open")
+
+// BreakerConfig holds settings of circuit breaker around tester service.
+// Breaker opens when failure rate of requests during the window reaches FailureRate,
+// while it's open requests fail fast with ErrCircuitOpen. After OpenDuration breaker lets HalfOpenProbes
+// requests through, it closes if all of them succeed and opens again if any of them fails.
+type BreakerConfig struct {
+	// FailureRate is a fraction of failed requests in range (0, 1] that opens the breaker, 0 disables the breaker
+	FailureRate float64 `mapstructure:"failure_rate"`
+
+	// MinRequests is a min amount of requests during the window before failure rate is checked
+	MinRequests int `mapstructure:"min_requests"`
+
+	// Window is a period over which failure rate is counted
+	Window time.Duration `mapstructure:"window"`
+
+	// OpenDuration is how long breaker stays open before probing tester service
+	OpenDuration time.Duration `mapstructure:"open_duration"`
+
+	// HalfOpenProbes is an amount of requests that are let through to probe tester service
+	HalfOpenProbes int `mapstructure:"half_open_probes"`
+}
+
+// BreakerState is a state of circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerSnapshot holds state of circuit breaker at some moment
+type BreakerSnapshot struct {
+	State BreakerState `json:"state"`
+
+	// counters of requests during the current window, or of probes when breaker is half-open
+	Successes int `json:"successes"`
+	Failures  int `json:"failures"`
+
+	// OpenUntil is set when breaker is open, requests are probed after it
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// breaker is a circuit breaker, nil breaker lets all requests through
+type breaker struct {
+	conf BreakerConfig
+
+	mu    sync.Mutex
+	state BreakerState
+
+	// generation is incremented on every state change, so results of requests
+	// that were let through in the previous state aren't counted in the new one
+	generation uint64
+
+	windowStart time.Time
+	successes   int
+	failures    int
+
+	openedAt time.Time
+	probes   int // amount of probes let through while half-open
+
+	logger *zerolog.Logger
+}
+
+// newBreaker constructs a breaker, if failure rate isn't set breaker is disabled and nil is returned
+func newBreaker(conf BreakerConfig, logger *zerolog.Logger) *breaker {
+	if conf.FailureRate <= 0 {
+		return nil
+	}
+
+	if conf.HalfOpenProbes <= 0 {
+		conf.HalfOpenProbes = 1
+	}
+
+	b := &breaker{conf: conf, logger: logger}
+	b.setState(BreakerClosed, time.Now())
+
+	return b
+}
+
+// allow checks if request can be sent, and returns generation to pass to record with the result
+func (b *breaker) allow() (uint64, error) {
+	if b == nil {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.conf.OpenDuration {
+		b.setState(BreakerHalfOpen, now)
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		return 0, ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probes >= b.conf.HalfOpenProbes {
+			return 0, ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	return b.generation, nil
+}
+
+// record counts result of request that was let through in given generation
+func (b *breaker) record(generation uint64, failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	now := time.Now()
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.conf.Window {
+			b.windowStart, b.successes, b.failures = now, 0, 0
+		}
+
+		if failed {
+			b.failures++
+		} else {
+			b.successes++
+		}
+
+		total := b.successes + b.failures
+		if total >= b.conf.MinRequests && float64(b.failures)/float64(total) >= b.conf.FailureRate {
+			b.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(BreakerOpen, now)
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.conf.HalfOpenProbes {
+			b.setState(BreakerClosed, now)
+		}
+	}
+}
+
+// cancel releases request that was let through in given generation without counting its result,
+// so probe slot of half-open breaker isn't held by request that was canceled by caller
+func (b *breaker) cancel(generation uint64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.generation && b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// setState switches breaker to the state and resets counters, it must be called with mu locked
+func (b *breaker) setState(state BreakerState, now time.Time) {
+	if b.state != "" {
+		event := b.logger.Info()
+		if state == BreakerOpen {
+			event = b.logger.Warn()
+		}
+		event.Str("from", string(b.state)).Str("to", string(state)).Int("successes", b.successes).Int("failures", b.failures).
+			Msg("tester service circuit breaker changed state")
+	}
+
+	b.state = state
+	b.generation++
+	b.windowStart, b.successes, b.failures, b.probes = now, 0, 0, 0
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+
+	for _, s := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		metrics.BreakerState.WithLabelValues(string(s)).Set(value)
+	}
+}
+
+// snapshot returns the current state of breaker
+func (b *breaker) snapshot() BreakerSnapshot {
+	if b == nil {
+		return BreakerSnapshot{State: BreakerClosed}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerSnapshot{State: b.state, Successes: b.successes, Failures: b.failures}
+	if b.state == BreakerOpen {
+		openUntil := b.openedAt.Add(b.conf.OpenDuration)
+		s.OpenUntil = &openUntil
+		// breaker switches to half-open lazily on the next request
+		if !time.Now().Before(openUntil) {
+			s.State, s.OpenUntil = BreakerHalfOpen, nil
+		}
+	}
+
+	return s
+}
+
+// isBreakerFailure checks if lookup error tells that tester service is unhealthy: client timeout,
+// transport error or 5xx response. Rejected lookups and client side errors don't count,
+// and requests canceled by caller aren't recorded at all
+func isBreakerFailure(err error) bool {
+	var (
+		timeoutErr   *TimeoutError
+		transportErr *TransportError
+		statusErr    *StatusError
+	)
+	switch {
+	case errors.As(err, &timeoutErr), errors.As(err, &transportErr):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.Code >= 500
+	default:
+		return false
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bitburst-assessment-task/internal/metrics"
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheConfig holds settings of in-process cache of object statuses,
+// tester service sends overlapping ids in callbacks, so recently looked up statuses are reused
+type CacheConfig struct {
+	// TTL is how long looked up status is reused, 0 disables the cache
+	TTL time.Duration `mapstructure:"ttl"`
+
+	// Size is a max amount of cached statuses, least recently used statuses are evicted when it's reached
+	Size int `mapstructure:"size"`
+}
+
+// defaultCacheSize is used when cache size isn't set in config
+const defaultCacheSize = 10000
+
+// CacheStats holds statistics of status cache
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+
+	// Size is an amount of currently cached statuses, some of them may be expired but not evicted yet
+	Size int `json:"size"`
+}
+
+type cacheEntry struct {
+	status    ObjectsRespBody
+	expiresAt time.Time
+}
+
+// statusCache is a size bound LRU cache of object statuses with TTL
+type statusCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[int32]*list.Element
+	lru     *list.List // front is the most recently used entry
+
+	hits   uint64
+	misses uint64
+}
+
+func newStatusCache(conf CacheConfig) *statusCache {
+	size := conf.Size
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+
+	return &statusCache{
+		ttl:     conf.TTL,
+		size:    size,
+		entries: make(map[int32]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns a copy of cached status if it isn't expired
+func (c *statusCache) get(id int32) (*ObjectsRespBody, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[id]
+	if ok && time.Now().After(el.Value.(*cacheEntry).expiresAt) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		metrics.ClientCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	atomic.AddUint64(&c.hits, 1)
+	metrics.ClientCacheRequests.WithLabelValues("hit").Inc()
+
+	status := el.Value.(*cacheEntry).status
+	return &status, true
+}
+
+// set caches a copy of status, evicting least recently used status if cache is full
+func (c *statusCache) set(status *ObjectsRespBody) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{status: *status, expiresAt: time.Now().Add(c.ttl)}
+	if el, ok := c.entries[status.ID]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	if c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	c.entries[status.ID] = c.lru.PushFront(entry)
+}
+
+// remove deletes entry from cache, it must be called with mu locked
+func (c *statusCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).status.ID)
+}
+
+func (c *statusCache) stats() CacheStats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Size:   size,
+	}
+}
@@ -1,25 +1,88 @@
 package client
 
 import (
+	"bitburst-assessment-task/internal/metrics"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
 )
 
 type Config struct {
 	TesterServiceAddress string `mapstructure:"tester_service_address"`
+
+	// MaxInFlight is a max amount of concurrent lookups to tester service shared by all callers of a client,
+	// 0 means there is no limit
+	MaxInFlight int `mapstructure:"max_in_flight"`
+
+	// QueueSize is a max amount of lookups waiting for a free slot when MaxInFlight is reached,
+	// lookups that don't fit in the queue are rejected with ErrQueueFull
+	QueueSize int `mapstructure:"queue_size"`
+
+	// connection limits of http transport, 0 means default limits of http.Transport
+	MaxConnsPerHost     int `mapstructure:"max_conns_per_host"`
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host"`
+
+	// Timeout is a max time of one request to tester service including reading response body,
+	// default timeout is used if it's 0
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// timeouts of connecting to tester service, TLS handshake and waiting for response headers,
+	// 0 means default timeouts of http.Transport, response headers are waited until Timeout by default
+	DialTimeout           time.Duration `mapstructure:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+
+	Retry RetryConfig `mapstructure:"retry"`
+
+	Batch BatchConfig `mapstructure:"batch"`
+
+	Cache CacheConfig `mapstructure:"cache"`
+
+	Breaker BreakerConfig `mapstructure:"breaker"`
+}
+
+// defaultTimeout is used when request timeout isn't set in config, max possible response time of tester service is 4s,
+// and 1 more second is given, because request round-trip also adds time
+const defaultTimeout = 5 * time.Second
+
+// LookupTimeout returns max time of one lookup with all its retries, when every request takes the whole Timeout
+func (conf *Config) LookupTimeout() time.Duration {
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return conf.Retry.budget(timeout)
 }
 
 type Client struct {
 	c *http.Client
 
+	limiter *limiter
+
+	// cache is nil when caching is disabled
+	cache *statusCache
+
+	// breaker is nil when circuit breaker is disabled
+	breaker *breaker
+
+	// group de-duplicates concurrent lookups of the same id
+	group singleflight.Group
+
+	// batchUnsupportedUntil is unix time in nanoseconds until which batch lookups aren't tried
+	batchUnsupportedUntil int64
+
 	conf *Config
 
 	logger *zerolog.Logger
@@ -28,12 +91,34 @@ type Client struct {
 func New(conf *Config, logger *zerolog.Logger) *Client {
 	cli := &Client{}
 
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = conf.MaxConnsPerHost
+	transport.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+	if conf.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: conf.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if conf.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = conf.TLSHandshakeTimeout
+	}
+	transport.ResponseHeaderTimeout = conf.ResponseHeaderTimeout
+
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+
 	cli.c = &http.Client{
-		// max possible response time of tester_service is 4s, but I decided to give 1 more second,
-		// because request round-trip also adds time time for request
-		Timeout: 5 * time.Second,
+		Timeout:   conf.Timeout,
+		Transport: transport,
 	}
 
+	cli.limiter = newLimiter(conf.MaxInFlight, conf.QueueSize)
+
+	if conf.Cache.TTL > 0 {
+		cli.cache = newStatusCache(conf.Cache)
+	}
+
+	cli.breaker = newBreaker(conf.Breaker, logger)
+
 	cli.conf = conf
 
 	if !strings.Contains(cli.conf.TesterServiceAddress, "http://") {
@@ -47,81 +132,282 @@ func New(conf *Config, logger *zerolog.Logger) *Client {
 	return cli
 }
 
+// Ping checks if tester service is reachable by opening a tcp connection to it,
+// it doesn't send lookups, because they are slow and can't tell much more
+func (cli *Client) Ping(ctx context.Context) error {
+	u, err := url.Parse(cli.conf.TesterServiceAddress)
+	if err != nil {
+		return errors.WithMessage(err, "failed to parse tester service address")
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := (&net.Dialer{Timeout: cli.conf.DialTimeout}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return errors.WithMessage(err, "failed to connect to tester service")
+	}
+
+	return conn.Close()
+}
+
 // ObjectsRespBody is a response from tester_service /objects/:id route
 type ObjectsRespBody struct {
 	ID     int32
 	Online bool
 }
 
+// Result is a final outcome of object status lookup,
+// either Status or Err is set. Err is one of TimeoutError, TransportError, StatusError,
+// DecodeError, IDMismatchError, ErrQueueFull or ErrCircuitOpen possibly wrapped with a message, use errors.As to inspect it.
+type Result struct {
+	ID     int32
+	Status *ObjectsRespBody
+	Err    error
+
+	// Attempts is an amount of requests that were sent to tester service
+	Attempts int
+
+	// Cached tells that status was taken from cache, no requests were sent then
+	Cached bool
+}
+
+// LookupTimeout returns max time of one lookup with all its retries
+func (cli *Client) LookupTimeout() time.Duration {
+	return cli.conf.LookupTimeout()
+}
+
+// Breaker returns the current state of circuit breaker around tester service,
+// it's always closed if breaker is disabled
+func (cli *Client) Breaker() BreakerSnapshot {
+	return cli.breaker.snapshot()
+}
+
+// CacheStats returns statistics of status cache, they are zero if cache is disabled
+func (cli *Client) CacheStats() CacheStats {
+	if cli.cache == nil {
+		return CacheStats{}
+	}
+
+	return cli.cache.stats()
+}
+
 // Do sends a list of object ids to tester service concurrently,
 // and gets their online statuses.
-func (cli *Client) Do(ctx context.Context, objectIDs []int32) []*ObjectsRespBody {
-	objStatuses := make([]*ObjectsRespBody, 0, len(objectIDs))
-	objStatusesChan := make(chan *ObjectsRespBody, len(objectIDs))
-	// receive object ids from goroutines concurrently, so we read ids from buffer at the same time they are sent
-	go func() {
-		for obj := range objStatusesChan {
-			objStatuses = append(objStatuses, obj)
+// Statuses that are cached are not looked up again.
+// If batch lookups are enabled, ids are looked up in batches first, and the rest of ids are looked up one by one.
+// Concurrent lookups of the same id, even from different calls, share one lookup.
+// Failed lookups are retried according to retry policy until they succeed or LookupTimeout passes,
+// results of lookups that are still running when context is done are TimeoutError or TransportError.
+// Amount of concurrent lookups is limited by MaxInFlight and QueueSize.
+// While circuit breaker is open, lookups fail fast with ErrCircuitOpen.
+// Result is returned for every requested id, in the same order as ids.
+func (cli *Client) Do(ctx context.Context, objectIDs []int32) []*Result {
+	results := make([]*Result, len(objectIDs))
+
+	// indexes of ids that weren't found in cache
+	pending := make([]int, 0, len(objectIDs))
+	for i, id := range objectIDs {
+		if cli.cache != nil {
+			if status, ok := cli.cache.get(id); ok {
+				results[i] = &Result{ID: id, Status: status, Cached: true}
+				continue
+			}
 		}
-	}()
+		pending = append(pending, i)
+	}
+
+	// tester service is considered down, so don't waste time and resources on lookups
+	if len(pending) > 0 && cli.Breaker().State == BreakerOpen {
+		for _, i := range pending {
+			results[i] = &Result{ID: objectIDs[i], Err: ErrCircuitOpen}
+			metrics.LookupDuration.WithLabelValues(outcome(ErrCircuitOpen)).Observe(0)
+		}
+		return results
+	}
+
+	if len(pending) > 0 && cli.batchSupported() {
+		ids, batchResults := make([]int32, len(pending)), make([]*Result, len(pending))
+		for j, i := range pending {
+			ids[j] = objectIDs[i]
+		}
+
+		cli.doBatches(ctx, ids, batchResults)
+
+		for j, i := range pending {
+			results[i] = batchResults[j]
+		}
+	}
 
 	// send requests to get object statuses concurrently,
 	// because /objects/ route has unpredictable response time,
 	// and if we do it in 1 loop, then it will be unacceptably slow
 	wg := &sync.WaitGroup{}
-	for _, v := range objectIDs {
+	for i, v := range objectIDs {
+		if results[i] != nil { // already found in cache or a batch
+			continue
+		}
+
 		wg.Add(1)
-		go func(id int32, objStatusesChan chan *ObjectsRespBody, wg *sync.WaitGroup) {
+		go func(i int, id int32, wg *sync.WaitGroup) {
 			defer wg.Done()
 
-			// get the object status
-			resp, err := cli.c.Get(fmt.Sprintf("%s/objects/%d", cli.conf.TesterServiceAddress, id))
-			if err != nil {
-				// usually client requests default to timeout errors,
-				// if it's not the case then report the error
-				urlErr := err.(*url.Error)
-				if urlErr.Timeout() {
-					cli.logger.Warn().Err(err).Int32("id", id).Msg("failed to get object status due to timeout")
-				} else {
-					cli.logger.Warn().Err(err).Int32("id", id).Msg("failed to get object status due to unknown reason")
-				}
-				return
-			}
-			defer func() {
-				if err := resp.Body.Close(); err != nil {
-					cli.logger.Warn().Err(err).Msg("failed to close response body")
-				}
-			}()
-
-			// decode object status
-			objStatus := ObjectsRespBody{}
-			if err := json.NewDecoder(resp.Body).Decode(&objStatus); err != nil {
-				cli.logger.Warn().Err(err).Msg("failed to decode response body")
-			}
-
-			objStatusesChan <- &objStatus
-		}(v, objStatusesChan, wg)
+			results[i] = cli.lookupShared(ctx, id)
+		}(i, v, wg)
 	}
 
-	// wait for all requests to be processesed
+	// wait for all requests to be processesed, waiting is bound to context,
+	// so it won't take longer than context timeout
 	wg.Wait()
 
-	// wait until the last obj is received from channel and appended to objStatuses slice, or until context timeout is reached
-LOOP:
+	return results
+}
+
+// lookupShared gets the object status sharing the lookup with concurrent callers looking up the same id.
+// Shared lookup isn't bound to context of any caller, so a caller that gives up doesn't fail it for the others,
+// it's bound to LookupTimeout instead, and every caller waits for it until its own context is done. Successful result is cached.
+func (cli *Client) lookupShared(ctx context.Context, id int32) *Result {
+	ch := cli.group.DoChan(strconv.FormatInt(int64(id), 10), func() (interface{}, error) {
+		lookupCtx, cancel := context.WithTimeout(context.Background(), cli.conf.LookupTimeout())
+		defer cancel()
+
+		res := cli.lookup(lookupCtx, id)
+		if res.Err == nil && cli.cache != nil {
+			cli.cache.set(res.Status)
+		}
+
+		return res, nil
+	})
+
+	var v singleflight.Result
+	select {
+	case v = <-ch:
+	case <-ctx.Done():
+		var err error = &TransportError{Err: ctx.Err()}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = &TimeoutError{Err: ctx.Err()}
+		}
+		return &Result{ID: id, Err: err}
+	}
+
+	// every caller gets it's own copy, so callers can't affect each other
+	res := *v.Val.(*Result)
+	if res.Status != nil {
+		status := *res.Status
+		res.Status = &status
+	}
+
+	return &res
+}
+
+// lookup gets the object status, retrying failed attempts
+func (cli *Client) lookup(ctx context.Context, id int32) *Result {
+	res := &Result{ID: id}
+
+	start := time.Now()
+	defer func() {
+		metrics.LookupDuration.WithLabelValues(outcome(res.Err)).Observe(time.Since(start).Seconds())
+	}()
+
 	for {
-		select {
-		case <-ctx.Done():
-			close(objStatusesChan)
-			break LOOP
-		default:
-			if len(objStatuses) == len(objectIDs) {
-				close(objStatusesChan)
-				break LOOP
-			}
+		res.Attempts++
+
+		var retryable bool
+		res.Status, retryable, res.Err = cli.attempt(ctx, id)
+		if res.Err == nil || !retryable || res.Attempts >= cli.conf.Retry.MaxAttempts {
+			break
 		}
+
+		if !cli.conf.Retry.wait(ctx, res.Attempts+1) {
+			break
+		}
+		cli.logger.Debug().Err(res.Err).Int32("id", id).Int("attempt", res.Attempts+1).Msg("retrying to get object status")
+	}
+
+	if res.Err != nil {
+		cli.logger.Debug().Err(res.Err).Int32("id", id).Int("attempts", res.Attempts).Msg("failed to get object status")
+	}
+
+	return res
+}
+
+// attempt sends one request to get the object status, and reports if it's worth to retry on failure
+func (cli *Client) attempt(ctx context.Context, id int32) (*ObjectsRespBody, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/objects/%d", cli.conf.TesterServiceAddress, id), nil)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to construct request")
+	}
+
+	// decode object status
+	objStatus := ObjectsRespBody{}
+	if retryable, err := cli.send(req, &objStatus); err != nil {
+		return nil, retryable, err
+	}
+
+	// make sure tester service didn't respond with status of another object
+	if objStatus.ID != id {
+		return nil, false, &IDMismatchError{Requested: id, Received: objStatus.ID}
+	}
+
+	return &objStatus, false, nil
+}
+
+// send sends request to tester service and decodes response body into dst,
+// it reports if it's worth to retry on failure
+func (cli *Client) send(req *http.Request, dst interface{}) (retryable bool, err error) {
+	// wait for a free lookup slot, so we don't flood tester service with requests
+	if err := cli.limiter.acquire(req.Context()); err != nil {
+		// full queue is rejected right away, retrying it would only add more load
+		if err == ErrQueueFull {
+			return false, err
+		}
+		return false, &TimeoutError{Err: err}
+	}
+	defer cli.limiter.release()
+
+	generation, err := cli.breaker.allow()
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := cli.c.Do(req)
+	if err != nil && req.Context().Err() != nil {
+		// caller's deadline or cancellation doesn't tell anything about health of tester service,
+		// so it isn't counted by breaker and isn't retried
+		cli.breaker.cancel(generation)
+		if req.Context().Err() == context.DeadlineExceeded {
+			return false, &TimeoutError{Err: err}
+		}
+		return false, &TransportError{Err: err}
 	}
+	defer func() { cli.breaker.record(generation, isBreakerFailure(err)) }()
+	if err != nil {
+		// usually client requests default to timeout errors
+		urlErr, ok := err.(*url.Error)
+		if ok && urlErr.Timeout() {
+			return true, &TimeoutError{Err: err}
+		}
+		return true, &TransportError{Err: err}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			cli.logger.Warn().Err(err).Msg("failed to close response body")
+		}
+	}()
 
-	cli.c.CloseIdleConnections()
+	if resp.StatusCode != http.StatusOK {
+		return cli.conf.Retry.isRetryableStatus(resp.StatusCode), &StatusError{Code: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return false, &DecodeError{Err: err}
+	}
 
-	return objStatuses
+	return false, nil
 }
@@ -13,9 +13,11 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	json "github.com/json-iterator/go"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
@@ -54,12 +56,16 @@ func TestDo(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	objs := cli.Do(ctx, ids)
+	results := cli.Do(ctx, ids)
 
-	require.Equal(t, len(ids), len(objs), "length of received objects isn't equal to actual ids")
+	require.Equal(t, len(ids), len(results), "length of received objects isn't equal to actual ids")
 
 	// check if client received ids and their online are correct
-	for _, obj := range objs {
+	for i, res := range results {
+		require.Equal(t, ids[i], res.ID, "results aren't in the same order as ids")
+		require.Nilf(t, res.Err, "failed to get status of %d id", res.ID)
+
+		obj := res.Status
 		if obj.ID%2 == 0 {
 			require.Equalf(t, true, obj.Online, "% id has online false, when it should be true", obj.ID)
 		} else {
@@ -68,6 +74,75 @@ func TestDo(t *testing.T) {
 	}
 }
 
+func TestDoRetry(t *testing.T) {
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.DebugLevel)
+
+	tests := map[string]struct {
+		failures     int32 // amount of first requests that fail with 503
+		maxAttempts  int
+		wantErr      bool
+		wantAttempts int
+	}{
+		"no failures": {
+			failures:     0,
+			maxAttempts:  3,
+			wantAttempts: 1,
+		},
+		"recovers after retries": {
+			failures:     2,
+			maxAttempts:  3,
+			wantAttempts: 3,
+		},
+		"attempts exhausted": {
+			failures:     5,
+			maxAttempts:  3,
+			wantErr:      true,
+			wantAttempts: 3,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= tc.failures {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"id":1,"online":true}`))
+			}))
+			defer srv.Close()
+
+			cli := New(&Config{
+				TesterServiceAddress: srv.URL,
+				Retry: RetryConfig{
+					MaxAttempts:          tc.maxAttempts,
+					BaseBackoff:          10 * time.Millisecond,
+					MaxBackoff:           50 * time.Millisecond,
+					Jitter:               0.5,
+					RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+				},
+			}, &zlog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			results := cli.Do(ctx, []int32{1})
+
+			require.Len(t, results, 1, "expected one result")
+			require.Equal(t, tc.wantAttempts, results[0].Attempts, "got unexpected amount of attempts")
+			if tc.wantErr {
+				require.NotNil(t, results[0].Err, "expected lookup to fail")
+			} else {
+				require.Nil(t, results[0].Err, "expected lookup to succeed")
+				require.True(t, results[0].Status.Online, "expected object to be online")
+			}
+		})
+	}
+}
+
 func BenchmarkDo(b *testing.B) {
 	zlog := log.Output(zerolog.ConsoleWriter{
 		Out:        io.Discard,
@@ -112,3 +187,594 @@ func BenchmarkDo(b *testing.B) {
 		_ = cli.Do(ctx, ids)
 	})
 }
+
+func TestLimiter(t *testing.T) {
+	tests := map[string]struct {
+		maxInFlight int
+		queueSize   int
+		acquired    int
+		wantErr     error
+	}{
+		"no limit": {
+			maxInFlight: 0,
+			acquired:    1000,
+			wantErr:     nil,
+		},
+		"free slot": {
+			maxInFlight: 2,
+			queueSize:   0,
+			acquired:    1,
+			wantErr:     nil,
+		},
+		"queue full": {
+			maxInFlight: 2,
+			queueSize:   0,
+			acquired:    2,
+			wantErr:     ErrQueueFull,
+		},
+		"queued until timeout": {
+			maxInFlight: 2,
+			queueSize:   1,
+			acquired:    2,
+			wantErr:     context.DeadlineExceeded,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := newLimiter(tc.maxInFlight, tc.queueSize)
+
+			for i := 0; i < tc.acquired; i++ {
+				require.Nil(t, l.acquire(context.Background()), "failed to acquire free slot")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			require.Equal(t, tc.wantErr, l.acquire(ctx), "got unexpected error when acquiring slot")
+		})
+	}
+}
+
+func TestDoQueueFull(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer srv.Close()
+
+	cli := New(&Config{
+		TesterServiceAddress: srv.URL,
+		MaxInFlight:          1,
+		QueueSize:            0,
+		Retry:                RetryConfig{MaxAttempts: 3, BaseBackoff: time.Second, MaxBackoff: time.Second},
+	}, &zlog)
+
+	// the only lookup slot is busy
+	require.NoError(t, cli.limiter.acquire(context.Background()))
+	defer cli.limiter.release()
+
+	start := time.Now()
+	results := cli.Do(context.Background(), []int32{1})
+
+	require.Len(t, results, 1)
+	require.Equal(t, ErrQueueFull, results[0].Err)
+	require.True(t, IsTemporary(results[0].Err), "rejected lookup must be deferred")
+	require.Equal(t, 1, results[0].Attempts, "rejected lookup was retried")
+	require.Less(t, int64(time.Since(start)), int64(500*time.Millisecond), "rejected lookup waited for backoff")
+	require.Zero(t, atomic.LoadInt32(&requests))
+}
+
+func TestDoErrors(t *testing.T) {
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.DebugLevel)
+
+	tests := map[string]struct {
+		handler       http.HandlerFunc
+		wantErr       interface{}
+		wantTemporary bool
+	}{
+		"invalid body": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"id":1,"online":`))
+			},
+			wantErr: &DecodeError{},
+		},
+		"id mismatch": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"id":2,"online":true}`))
+			},
+			wantErr: &IDMismatchError{},
+		},
+		"not found": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			wantErr: &StatusError{},
+		},
+		"unavailable": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+			wantErr:       &StatusError{},
+			wantTemporary: true,
+		},
+		"timeout": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			wantErr:       &TimeoutError{},
+			wantTemporary: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(tc.handler)
+			defer srv.Close()
+
+			cli := New(&Config{TesterServiceAddress: srv.URL}, &zlog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			results := cli.Do(ctx, []int32{1})
+
+			require.Len(t, results, 1, "expected one result")
+			require.Nil(t, results[0].Status, "failed lookup shouldn't have status")
+			require.IsType(t, tc.wantErr, results[0].Err, "got unexpected error type")
+			require.Equal(t, tc.wantTemporary, IsTemporary(results[0].Err), "got unexpected temporary flag")
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.DebugLevel)
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	reachable := srv.URL
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	unreachable := closed.URL
+	closed.Close()
+	defer srv.Close()
+
+	tests := map[string]struct {
+		address string
+		wantErr bool
+	}{
+		"reachable": {
+			address: reachable,
+		},
+		"unreachable": {
+			address: unreachable,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cli := New(&Config{TesterServiceAddress: tc.address}, &zlog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			err := cli.Ping(ctx)
+			if tc.wantErr {
+				require.NotNil(t, err, "expected tester service to be unreachable")
+			} else {
+				require.Nil(t, err, "expected tester service to be reachable")
+			}
+		})
+	}
+}
+
+func TestDoBatch(t *testing.T) {
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.DebugLevel)
+
+	tests := map[string]struct {
+		batchHandler      http.HandlerFunc // nil means batch route isn't supported
+		wantBatchRequests int32
+		wantIDRequests    int32
+	}{
+		"batches": {
+			batchHandler: func(w http.ResponseWriter, r *http.Request) {
+				var body batchReqBody
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+				objs := make([]string, 0, len(body.IDs))
+				for _, id := range body.IDs {
+					objs = append(objs, fmt.Sprintf(`{"id":%d,"online":%v}`, id, id%2 == 0))
+				}
+				w.Write([]byte(`{"objects":[` + strings.Join(objs, ",") + `]}`))
+			},
+			wantBatchRequests: 3,
+		},
+		"partial response": {
+			batchHandler: func(w http.ResponseWriter, r *http.Request) {
+				// only status of object 0 is returned, along with a status of object that wasn't requested
+				w.Write([]byte(`{"objects":[{"id":0,"online":true},{"id":100,"online":true}]}`))
+			},
+			wantBatchRequests: 3,
+			wantIDRequests:    9,
+		},
+		"not supported": {
+			// chunks are looked up concurrently, so every chunk hits unsupported batch route once
+			wantBatchRequests: 3,
+			wantIDRequests:    10,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var batchRequests, idRequests int32
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/objects/", func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&idRequests, 1)
+
+				id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/objects/"))
+				require.NoError(t, err)
+				w.Write([]byte(fmt.Sprintf(`{"id":%d,"online":%v}`, id, id%2 == 0)))
+			})
+			if tc.batchHandler != nil {
+				mux.HandleFunc("/objects:batchGet", func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddInt32(&batchRequests, 1)
+					tc.batchHandler(w, r)
+				})
+			} else {
+				mux.HandleFunc("/objects:batchGet", func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddInt32(&batchRequests, 1)
+					http.NotFound(w, r)
+				})
+			}
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			cli := New(&Config{
+				TesterServiceAddress: srv.URL,
+				Batch:                BatchConfig{Enabled: true, MaxSize: 4},
+			}, &zlog)
+
+			ids := make([]int32, 0, 10)
+			for i := 0; i < cap(ids); i++ {
+				ids = append(ids, int32(i))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			results := cli.Do(ctx, ids)
+
+			require.Len(t, results, len(ids))
+			for i, res := range results {
+				require.Equal(t, ids[i], res.ID, "results aren't in the same order as ids")
+				require.Nilf(t, res.Err, "failed to get status of %d id", res.ID)
+				require.Equal(t, res.ID%2 == 0, res.Status.Online, "got unexpected status of %d id", res.ID)
+			}
+			require.Equal(t, tc.wantBatchRequests, atomic.LoadInt32(&batchRequests), "got unexpected amount of batch requests")
+			require.Equal(t, tc.wantIDRequests, atomic.LoadInt32(&idRequests), "got unexpected amount of requests by id")
+
+			// batch route isn't tried again for a while after tester service reported it's not supported
+			if tc.batchHandler == nil {
+				_ = cli.Do(ctx, ids[:1])
+				require.Equal(t, tc.wantBatchRequests, atomic.LoadInt32(&batchRequests), "batch route shouldn't be tried again")
+			}
+		})
+	}
+}
+
+func TestDoCache(t *testing.T) {
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.DebugLevel)
+
+	var requests int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/objects/"))
+		require.NoError(t, err)
+		w.Write([]byte(fmt.Sprintf(`{"id":%d,"online":%v}`, id, id%2 == 0)))
+	}))
+	defer srv.Close()
+
+	cli := New(&Config{
+		TesterServiceAddress: srv.URL,
+		Cache:                CacheConfig{TTL: time.Hour, Size: 100},
+	}, &zlog)
+
+	ids := []int32{0, 1, 2, 3, 4}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// concurrent lookups of the same ids share requests
+	resultsChan := make(chan []*Result, 2)
+	go func() { resultsChan <- cli.Do(ctx, ids) }()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == int32(len(ids)) }, time.Second, time.Millisecond)
+	go func() { resultsChan <- cli.Do(ctx, ids) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		results := <-resultsChan
+		require.Len(t, results, len(ids))
+		for j, res := range results {
+			require.Equal(t, ids[j], res.ID)
+			require.NoError(t, res.Err)
+			require.Equal(t, res.ID%2 == 0, res.Status.Online)
+		}
+	}
+	require.Equal(t, int32(len(ids)), atomic.LoadInt32(&requests), "concurrent lookups of the same ids weren't shared")
+
+	// following lookups are served from cache
+	results := cli.Do(ctx, ids)
+	for j, res := range results {
+		require.Equal(t, ids[j], res.ID)
+		require.NoError(t, res.Err)
+		require.True(t, res.Cached, "status of %d id wasn't taken from cache", res.ID)
+		require.Equal(t, 0, res.Attempts)
+	}
+	require.Equal(t, int32(len(ids)), atomic.LoadInt32(&requests), "cached statuses were looked up again")
+
+	require.Equal(t, CacheStats{Hits: 5, Misses: 10, Size: 5}, cli.CacheStats())
+}
+
+func TestStatusCache(t *testing.T) {
+	c := newStatusCache(CacheConfig{TTL: time.Hour, Size: 2})
+
+	c.set(&ObjectsRespBody{ID: 1, Online: true})
+	c.set(&ObjectsRespBody{ID: 2})
+	_, _ = c.get(1) // 2 becomes least recently used
+	c.set(&ObjectsRespBody{ID: 3})
+
+	_, ok := c.get(2)
+	require.False(t, ok, "least recently used status wasn't evicted")
+
+	status, ok := c.get(1)
+	require.True(t, ok)
+	require.Equal(t, &ObjectsRespBody{ID: 1, Online: true}, status)
+
+	// returned status is a copy
+	status.Online = false
+	status, _ = c.get(1)
+	require.True(t, status.Online, "cached status was modified")
+
+	c.ttl = time.Millisecond
+	c.set(&ObjectsRespBody{ID: 3})
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok = c.get(3)
+	require.False(t, ok, "expired status was returned")
+	require.Equal(t, CacheStats{Hits: 3, Misses: 2, Size: 1}, c.stats())
+}
+
+func TestBreaker(t *testing.T) {
+	zlog := zerolog.Nop()
+	b := newBreaker(BreakerConfig{FailureRate: 0.5, MinRequests: 4, Window: time.Hour, OpenDuration: 50 * time.Millisecond, HalfOpenProbes: 2}, &zlog)
+
+	// failure rate isn't checked until there are enough requests
+	for _, failed := range []bool{true, true, false} {
+		gen, err := b.allow()
+		require.NoError(t, err)
+		b.record(gen, failed)
+	}
+	require.Equal(t, BreakerSnapshot{State: BreakerClosed, Successes: 1, Failures: 2}, b.snapshot())
+
+	gen, err := b.allow()
+	require.NoError(t, err)
+
+	// request that was let through before the breaker opened is not counted after
+	staleGen, err := b.allow()
+	require.NoError(t, err)
+
+	b.record(gen, true)
+	require.Equal(t, BreakerOpen, b.snapshot().State)
+	require.NotNil(t, b.snapshot().OpenUntil)
+
+	_, err = b.allow()
+	require.Equal(t, ErrCircuitOpen, err, "open breaker let request through")
+
+	time.Sleep(60 * time.Millisecond)
+	require.Equal(t, BreakerHalfOpen, b.snapshot().State)
+
+	// only given amount of probes is let through
+	probe1, err := b.allow()
+	require.NoError(t, err)
+	b.record(staleGen, true)
+	probe2, err := b.allow()
+	require.NoError(t, err)
+	_, err = b.allow()
+	require.Equal(t, ErrCircuitOpen, err, "half-open breaker let too many probes through")
+
+	// failed probe opens the breaker again
+	b.record(probe1, true)
+	require.Equal(t, BreakerOpen, b.snapshot().State)
+	b.record(probe2, false)
+	require.Equal(t, BreakerOpen, b.snapshot().State)
+
+	time.Sleep(60 * time.Millisecond)
+
+	// probe canceled by caller frees its slot for another probe
+	probe1, err = b.allow()
+	require.NoError(t, err)
+	b.cancel(probe1)
+
+	// successful probes close the breaker
+	probe1, err = b.allow()
+	require.NoError(t, err)
+	probe2, err = b.allow()
+	require.NoError(t, err)
+	b.record(probe1, false)
+	require.Equal(t, BreakerHalfOpen, b.snapshot().State)
+	b.record(probe2, false)
+	require.Equal(t, BreakerSnapshot{State: BreakerClosed}, b.snapshot())
+}
+
+func TestDoBreaker(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cli := New(&Config{
+		TesterServiceAddress: srv.URL,
+		Retry:                RetryConfig{MaxAttempts: 1},
+		Breaker:              BreakerConfig{FailureRate: 1, MinRequests: 2, Window: time.Minute, OpenDuration: time.Hour},
+	}, &zlog)
+
+	results := cli.Do(context.Background(), []int32{1, 2})
+	for _, res := range results {
+		var statusErr *StatusError
+		require.ErrorAs(t, res.Err, &statusErr)
+	}
+	require.Equal(t, BreakerOpen, cli.Breaker().State)
+
+	results = cli.Do(context.Background(), []int32{1, 2, 3})
+	require.Len(t, results, 3)
+	for i, res := range results {
+		require.Equal(t, int32(i+1), res.ID)
+		require.Equal(t, ErrCircuitOpen, res.Err)
+		require.True(t, IsTemporary(res.Err))
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests), "lookups weren't failed fast while circuit is open")
+}
+
+func TestDoBreakerCallerDeadline(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			w.Write([]byte(fmt.Sprintf(`{"id":%s,"online":true}`, strings.TrimPrefix(r.URL.Path, "/objects/"))))
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	cli := New(&Config{
+		TesterServiceAddress: srv.URL,
+		Retry:                RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		Breaker:              BreakerConfig{FailureRate: 0.5, MinRequests: 1, Window: time.Minute, OpenDuration: time.Hour},
+	}, &zlog)
+
+	// deadline of callback processing expires while tester service is still responding
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	results := cli.Do(ctx, []int32{1, 2, 3})
+	for _, res := range results {
+		require.IsType(t, &TimeoutError{}, res.Err, "got unexpected error type")
+	}
+	require.Equal(t, BreakerSnapshot{State: BreakerClosed}, cli.Breaker(), "caller deadline was counted as failure of tester service")
+
+	// canceled lookups aren't counted either
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	results = cli.Do(ctx, []int32{1})
+	require.IsType(t, &TransportError{}, results[0].Err, "got unexpected error type")
+	require.Equal(t, BreakerSnapshot{State: BreakerClosed}, cli.Breaker(), "canceled lookup was counted as failure of tester service")
+
+	// lookups that callers gave up on keep going and aren't retried
+	close(release)
+	results = cli.Do(context.Background(), []int32{1, 2, 3})
+	for _, res := range results {
+		require.NoError(t, res.Err)
+		require.Equal(t, 1, res.Attempts, "lookup was retried after caller gave up")
+	}
+	require.Equal(t, BreakerClosed, cli.Breaker().State)
+}
+
+func TestDoSharedLookupCallerDeadline(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte(`{"id":1,"online":true}`))
+	}))
+	defer srv.Close()
+
+	cli := New(&Config{TesterServiceAddress: srv.URL, Retry: RetryConfig{MaxAttempts: 1}}, &zlog)
+
+	// the first caller gives up before tester service responds, the second one shares its lookup and waits longer
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	longCtx, longCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer longCancel()
+
+	resultsChan := make(chan []*Result, 1)
+	go func() { resultsChan <- cli.Do(shortCtx, []int32{1}) }()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 1 }, time.Second, time.Millisecond)
+
+	results := cli.Do(longCtx, []int32{1})
+	require.NoError(t, results[0].Err, "lookup failed with error of another caller")
+	require.True(t, results[0].Status.Online)
+
+	results = <-resultsChan
+	require.IsType(t, &TimeoutError{}, results[0].Err, "got unexpected error type")
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests), "lookup wasn't shared")
+}
+
+func TestDoTimeouts(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	tests := map[string]struct {
+		conf    Config
+		handler http.HandlerFunc
+	}{
+		"request timeout": {
+			conf: Config{Timeout: 100 * time.Millisecond},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		},
+		"response header timeout": {
+			conf: Config{ResponseHeaderTimeout: 100 * time.Millisecond},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(tc.handler)
+			defer srv.Close()
+
+			conf := tc.conf
+			conf.TesterServiceAddress = srv.URL
+			conf.Retry = RetryConfig{MaxAttempts: 1}
+			cli := New(&conf, &zlog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			results := cli.Do(ctx, []int32{1})
+
+			require.Less(t, int64(time.Since(start)), int64(time.Second), "lookup wasn't limited by configured timeout")
+			require.Len(t, results, 1)
+			require.IsType(t, &TimeoutError{}, results[0].Err, "got unexpected error type")
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// TimeoutError is returned when request to tester service timed out
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string { return "request timed out: " + e.Err.Error() }
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// TransportError is returned when request to tester service failed before response was received
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string { return "request failed: " + e.Err.Error() }
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// StatusError is returned when tester service responded with unexpected status code
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status code %d", e.Code)
+}
+
+// DecodeError is returned when response body of tester service couldn't be decoded
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string { return "failed to decode response body: " + e.Err.Error() }
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// IDMismatchError is returned when tester service responded with status of another object
+type IDMismatchError struct {
+	Requested int32
+	Received  int32
+}
+
+func (e *IDMismatchError) Error() string {
+	return fmt.Sprintf("requested status of %d id, but received status of %d id", e.Requested, e.Received)
+}
+
+// IsTemporary reports if lookup failed due to a transient problem,
+// so it's worth to look up the object again later
+func IsTemporary(err error) bool {
+	var (
+		timeoutErr   *TimeoutError
+		transportErr *TransportError
+		statusErr    *StatusError
+	)
+	switch {
+	case errors.As(err, &timeoutErr), errors.As(err, &transportErr), errors.Is(err, ErrQueueFull), errors.Is(err, ErrCircuitOpen):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+// outcome returns a short name of lookup outcome for metrics
+func outcome(err error) string {
+	var (
+		timeoutErr    *TimeoutError
+		transportErr  *TransportError
+		statusErr     *StatusError
+		decodeErr     *DecodeError
+		idMismatchErr *IDMismatchError
+	)
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrQueueFull):
+		return "rejected"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.As(err, &timeoutErr):
+		return "timeout"
+	case errors.As(err, &transportErr):
+		return "transport_error"
+	case errors.As(err, &statusErr):
+		return "status_error"
+	case errors.As(err, &decodeErr):
+		return "decode_error"
+	case errors.As(err, &idMismatchErr):
+		return "id_mismatch"
+	default:
+		return "unknown"
+	}
+}
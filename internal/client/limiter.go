@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrQueueFull is returned when all lookup slots are busy and lookups queue is full
+var ErrQueueFull = errors.New("lookups queue is full")
+
+// limiter caps the amount of concurrent lookups to tester service,
+// lookups that don't get a free slot wait in a queue of limited size,
+// and lookups that don't fit in the queue are rejected right away.
+type limiter struct {
+	slots chan struct{}
+
+	queueSize int32
+	queued    int32
+}
+
+// newLimiter constructs a limiter, if maxInFlight <= 0 there is no limit and nil is returned
+func newLimiter(maxInFlight, queueSize int) *limiter {
+	if maxInFlight <= 0 {
+		return nil
+	}
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	return &limiter{
+		slots:     make(chan struct{}, maxInFlight),
+		queueSize: int32(queueSize),
+	}
+}
+
+// acquire takes a lookup slot, it waits in the queue until a slot is free or context is done.
+// Don't forget to release the slot when the lookup is finished.
+func (l *limiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	// fast path, free slot is available
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if atomic.AddInt32(&l.queued, 1) > l.queueSize {
+		atomic.AddInt32(&l.queued, -1)
+		return ErrQueueFull
+	}
+	defer atomic.AddInt32(&l.queued, -1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a lookup slot
+func (l *limiter) release() {
+	if l == nil {
+		return
+	}
+
+	<-l.slots
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"bitburst-assessment-task/internal/backoff"
+	"context"
+	"time"
+)
+
+// RetryConfig holds retry policy of tester service lookups
+type RetryConfig struct {
+	// MaxAttempts is a max amount of attempts per lookup including the first one, 1 or less disables retries
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// backoff before attempt n is min(BaseBackoff * 2^(n-2), MaxBackoff), see backoff.Exponential
+	BaseBackoff time.Duration `mapstructure:"base_backoff"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+
+	// Jitter is a fraction of backoff in range [0, 1] that is randomized,
+	// so concurrent lookups don't retry at the same moment
+	Jitter float64 `mapstructure:"jitter"`
+
+	// RetryableStatusCodes are response status codes of tester service after which lookup is retried,
+	// transport errors and timeouts are always retried
+	RetryableStatusCodes []int `mapstructure:"retryable_status_codes"`
+}
+
+// isRetryableStatus checks if lookup should be retried after receiving response with given status code
+func (rc *RetryConfig) isRetryableStatus(code int) bool {
+	for _, c := range rc.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns a jittered time to wait before given attempt, attempts start from 1
+func (rc *RetryConfig) backoff(attempt int) time.Duration {
+	return backoff.Exponential(rc.BaseBackoff, rc.MaxBackoff, rc.Jitter, attempt)
+}
+
+// budget returns max time of a lookup with all its attempts and backoffs between them, when every attempt takes timeout
+func (rc *RetryConfig) budget(timeout time.Duration) time.Duration {
+	attempts := rc.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	d := time.Duration(attempts) * timeout
+	for n := 2; n <= attempts; n++ {
+		d += backoff.Exponential(rc.BaseBackoff, rc.MaxBackoff, 0, n)
+	}
+
+	return d
+}
+
+// wait sleeps for a backoff before given attempt, it returns false if context is done
+// or it's deadline will pass before the attempt, so there is no sense in retrying
+func (rc *RetryConfig) wait(ctx context.Context, attempt int) bool {
+	return backoff.Sleep(ctx, rc.backoff(attempt))
+}
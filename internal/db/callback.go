@@ -0,0 +1,70 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Callback is an accepted /callback payload stored in the durable inbox
+type Callback struct {
+	ID         int64
+	ObjectIDs  []int32
+	ReceivedAt time.Time
+}
+
+// SaveCallback persists received object ids in the callbacks inbox and returns id of the inbox entry,
+// it should be called before acknowledging a callback, so the payload survives a crash
+func (db *DB) SaveCallback(ctx context.Context, objectIDs []int32) (int64, error) {
+	id, err := db.q.InsertCallback(ctx, objectIDs)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to insert callback")
+	}
+
+	return id, nil
+}
+
+// MarkCallbackProcessed marks inbox entry as done, so it won't be replayed again
+func (db *DB) MarkCallbackProcessed(ctx context.Context, id int64) error {
+	if err := db.q.MarkCallbackProcessed(ctx, id); err != nil {
+		return errors.WithMessagef(err, "failed to mark callback %d as processed", id)
+	}
+
+	return nil
+}
+
+// UnprocessedCallbacks returns at most limit inbox entries that weren't marked as done
+// and that were received at least minAge ago, oldest entries come first
+func (db *DB) UnprocessedCallbacks(ctx context.Context, minAge time.Duration, limit int32) ([]*Callback, error) {
+	rows, err := db.q.GetUnprocessedCallbacks(ctx, objects.GetUnprocessedCallbacksParams{
+		MinAgeMs: minAge.Milliseconds(),
+		MaxRows:  limit,
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get unprocessed callbacks")
+	}
+
+	callbacks := make([]*Callback, 0, len(rows))
+	for _, row := range rows {
+		callbacks = append(callbacks, &Callback{
+			ID:         row.CID,
+			ObjectIDs:  row.ObjectIds,
+			ReceivedAt: row.ReceivedAt,
+		})
+	}
+
+	return callbacks, nil
+}
+
+// DeleteProcessedCallbacks deletes inbox entries that were processed more than retention ago,
+// and returns amount of deleted entries
+func (db *DB) DeleteProcessedCallbacks(ctx context.Context, retention time.Duration) (int64, error) {
+	n, err := db.q.DeleteProcessedCallbacks(ctx, retention.Milliseconds())
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to delete processed callbacks")
+	}
+
+	return n, nil
+}
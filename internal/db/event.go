@@ -0,0 +1,78 @@
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is a type of object event
+type EventType string
+
+const (
+	EventObjectOnline  EventType = "object.online"
+	EventObjectOffline EventType = "object.offline"
+	EventObjectExpired EventType = "object.expired"
+)
+
+// EventTypes holds all known event types
+var EventTypes = []EventType{EventObjectOnline, EventObjectOffline, EventObjectExpired}
+
+// Event is emitted when object changes state or expires
+type Event struct {
+	Type       EventType `json:"type"`
+	ObjectID   int32     `json:"o_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// emitter notifies registered listeners about object events, it's embedded in storages
+type emitter struct {
+	listenersMu sync.RWMutex
+	listeners   []func(events []Event)
+}
+
+// OnEvents registers a listener that is called with events after they are committed to storage,
+// listener is called synchronously, so it shouldn't block
+func (e *emitter) OnEvents(listener func(events []Event)) {
+	e.listenersMu.Lock()
+	defer e.listenersMu.Unlock()
+
+	e.listeners = append(e.listeners, listener)
+}
+
+// emit passes events to all registered listeners
+func (e *emitter) emit(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	e.listenersMu.RLock()
+	defer e.listenersMu.RUnlock()
+
+	for _, listener := range e.listeners {
+		listener(events)
+	}
+}
+
+// transitionEvents converts object status changes to events
+func transitionEvents(ts []Transition, at time.Time) []Event {
+	events := make([]Event, 0, len(ts))
+	for _, t := range ts {
+		e := Event{Type: EventObjectOffline, ObjectID: t.ObjectID, OccurredAt: at}
+		if t.NewOnline {
+			e.Type = EventObjectOnline
+		}
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// expiredEvents converts ids of deleted objects to events
+func expiredEvents(ids []int32, at time.Time) []Event {
+	events := make([]Event, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, Event{Type: EventObjectExpired, ObjectID: id, OccurredAt: at})
+	}
+
+	return events
+}
@@ -0,0 +1,242 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Transition is a change of object online status
+type Transition struct {
+	ObjectID  int32
+	OldOnline *bool // nil when object wasn't stored before
+	NewOnline bool
+}
+
+// StatusChange is an entry of object status history
+type StatusChange struct {
+	ID         int64     `json:"h_id"`
+	ObjectID   int32     `json:"o_id"`
+	OldOnline  *bool     `json:"old_online"`
+	NewOnline  bool      `json:"new_online"`
+	ObservedAt time.Time `json:"observed_at"`
+	CallbackID *int64    `json:"callback_id"`
+}
+
+// Uptime is a share of time object was online during a window
+type Uptime struct {
+	ObjectID int32 `json:"o_id"`
+
+	// Percentage is calculated over observed part of the window, that starts when object status was first recorded
+	Percentage      float64 `json:"uptime_percentage"`
+	ObservedSeconds float64 `json:"observed_seconds"`
+}
+
+// lockObjectsStatus locks stored objects with given ids till the end of transaction and returns their online statuses
+func lockObjectsStatus(ctx context.Context, q *objects.Queries, ids []int32) (map[int32]bool, error) {
+	rows, err := q.GetObjectsStatusForUpdate(ctx, ids)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get objects status")
+	}
+
+	statuses := make(map[int32]bool, len(rows))
+	for _, row := range rows {
+		statuses[row.OID] = row.Online
+	}
+
+	return statuses, nil
+}
+
+// transitions returns status changes of objects, comparing statuses before processing of callback with new ones.
+// Offline objects that weren't stored before are skipped if skipNewOffline is true, as they aren't stored in legacy mode.
+func transitions(before map[int32]bool, onlineIDs []int32, offlineIDs []int32, skipNewOffline bool) []Transition {
+	ts := make([]Transition, 0)
+
+	add := func(id int32, online bool) {
+		old, ok := before[id]
+		switch {
+		case !ok && !online && skipNewOffline:
+		case !ok:
+			ts = append(ts, Transition{ObjectID: id, NewOnline: online})
+		case old != online:
+			ts = append(ts, Transition{ObjectID: id, OldOnline: &old, NewOnline: online})
+		}
+	}
+	for _, id := range onlineIDs {
+		add(id, true)
+	}
+	for _, id := range offlineIDs {
+		add(id, false)
+	}
+
+	return ts
+}
+
+// expiredTransitions returns changes to offline of objects that were deleted for not being seen,
+// deleted holds statuses of objects they had before deletion
+func expiredTransitions(deleted map[int32]bool) []Transition {
+	ts := make([]Transition, 0, len(deleted))
+	for id, online := range deleted {
+		old := online
+		ts = append(ts, Transition{ObjectID: id, OldOnline: &old, NewOnline: false})
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i].ObjectID < ts[j].ObjectID })
+
+	return ts
+}
+
+// recordTransitions appends status changes to objects status history
+func recordTransitions(ctx context.Context, q *objects.Queries, callbackID int64, ts []Transition) error {
+	if len(ts) == 0 {
+		return nil
+	}
+
+	params := objects.InsertStatusHistoryParams{
+		CallbackID: callbackID,
+		Ids:        make([]int32, 0, len(ts)),
+		IsNew:      make([]bool, 0, len(ts)),
+		OldOnline:  make([]bool, 0, len(ts)),
+		NewOnline:  make([]bool, 0, len(ts)),
+	}
+	for _, t := range ts {
+		params.Ids = append(params.Ids, t.ObjectID)
+		params.IsNew = append(params.IsNew, t.OldOnline == nil)
+		params.OldOnline = append(params.OldOnline, t.OldOnline != nil && *t.OldOnline)
+		params.NewOnline = append(params.NewOnline, t.NewOnline)
+	}
+
+	if err := q.InsertStatusHistory(ctx, params); err != nil {
+		return errors.WithMessage(err, "failed to insert status history")
+	}
+
+	return nil
+}
+
+// ObjectTimeline returns at most limit status changes of an object that were observed since given time, oldest first
+func (db *DB) ObjectTimeline(ctx context.Context, id int32, since time.Time, limit int32) ([]*StatusChange, error) {
+	rows, err := db.q.GetObjectTimeline(ctx, objects.GetObjectTimelineParams{
+		OID:     id,
+		Since:   since,
+		MaxRows: limit,
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to get timeline of object %d", id)
+	}
+
+	changes := make([]*StatusChange, 0, len(rows))
+	for _, row := range rows {
+		change := &StatusChange{
+			ID:         row.HID,
+			ObjectID:   row.OID,
+			NewOnline:  row.NewOnline,
+			ObservedAt: row.ObservedAt,
+		}
+		if row.OldOnline.Valid {
+			change.OldOnline = &row.OldOnline.Bool
+		}
+		if row.CallbackID.Valid {
+			change.CallbackID = &row.CallbackID.Int64
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Uptime returns uptime percentage of every object that has status history during the window ending now
+func (db *DB) Uptime(ctx context.Context, window time.Duration) ([]*Uptime, error) {
+	to := time.Now()
+	from := to.Add(-window)
+
+	beforeRows, err := db.q.GetStatusesBefore(ctx, from)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get statuses before window")
+	}
+
+	before := make(map[int32]bool, len(beforeRows))
+	for _, row := range beforeRows {
+		before[row.OID] = row.NewOnline
+	}
+
+	rows, err := db.q.GetStatusHistorySince(ctx, from)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get status history")
+	}
+
+	changes := make([]statusSample, 0, len(rows))
+	for _, row := range rows {
+		changes = append(changes, statusSample{objectID: row.OID, online: row.NewOnline, at: row.ObservedAt})
+	}
+
+	return computeUptime(before, changes, from, to), nil
+}
+
+// statusSample is an observed object status at some moment
+type statusSample struct {
+	objectID int32
+	online   bool
+	at       time.Time
+}
+
+// computeUptime calculates uptime of objects in window [from, to).
+// before holds statuses of objects at the start of the window,
+// changes must be ordered by object id and observation time.
+// Object status is assumed unchanged until next observation, objects deleted for not being seen have a change to offline.
+func computeUptime(before map[int32]bool, changes []statusSample, from, to time.Time) []*Uptime {
+	type acc struct {
+		online, observed time.Duration
+		last             *statusSample
+	}
+	accs := make(map[int32]*acc, len(before))
+	ids := make([]int32, 0, len(before))
+
+	get := func(id int32) *acc {
+		a, ok := accs[id]
+		if !ok {
+			a = &acc{}
+			accs[id] = a
+			ids = append(ids, id)
+		}
+		return a
+	}
+
+	// account time between status observations
+	flush := func(a *acc, till time.Time) {
+		if a.last == nil {
+			return
+		}
+		d := till.Sub(a.last.at)
+		a.observed += d
+		if a.last.online {
+			a.online += d
+		}
+	}
+
+	for id, online := range before {
+		get(id).last = &statusSample{objectID: id, online: online, at: from}
+	}
+
+	for i := range changes {
+		a := get(changes[i].objectID)
+		flush(a, changes[i].at)
+		a.last = &changes[i]
+	}
+
+	uptimes := make([]*Uptime, 0, len(ids))
+	for _, id := range ids {
+		a := accs[id]
+		flush(a, to)
+
+		u := &Uptime{ObjectID: id, ObservedSeconds: a.observed.Seconds()}
+		if a.observed > 0 {
+			u.Percentage = float64(a.online) / float64(a.observed) * 100
+		}
+		uptimes = append(uptimes, u)
+	}
+	sort.Slice(uptimes, func(i, j int) bool { return uptimes[i].ObjectID < uptimes[j].ObjectID })
+
+	return uptimes
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitions(t *testing.T) {
+	online, offline := true, false
+
+	tests := map[string]struct {
+		before         map[int32]bool
+		onlineIDs      []int32
+		offlineIDs     []int32
+		skipNewOffline bool
+		want           []Transition
+	}{
+		"new objects": {
+			before:     map[int32]bool{},
+			onlineIDs:  []int32{1},
+			offlineIDs: []int32{2},
+			want: []Transition{
+				{ObjectID: 1, NewOnline: true},
+				{ObjectID: 2, NewOnline: false},
+			},
+		},
+		"new offline objects in legacy mode": {
+			before:         map[int32]bool{},
+			onlineIDs:      []int32{1},
+			offlineIDs:     []int32{2},
+			skipNewOffline: true,
+			want: []Transition{
+				{ObjectID: 1, NewOnline: true},
+			},
+		},
+		"changed and unchanged objects": {
+			before:     map[int32]bool{1: false, 2: true, 3: true},
+			onlineIDs:  []int32{1, 3},
+			offlineIDs: []int32{2},
+			want: []Transition{
+				{ObjectID: 1, OldOnline: &offline, NewOnline: true},
+				{ObjectID: 2, OldOnline: &online, NewOnline: false},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := transitions(tc.before, tc.onlineIDs, tc.offlineIDs, tc.skipNewOffline)
+
+			diff := cmp.Diff(tc.want, got)
+			require.Empty(t, diff, "failed to get same transitions")
+		})
+	}
+}
+
+func TestComputeUptime(t *testing.T) {
+	from := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	tests := map[string]struct {
+		before  map[int32]bool
+		changes []statusSample
+		want    []*Uptime
+	}{
+		"status known before window": {
+			before: map[int32]bool{1: true},
+			changes: []statusSample{
+				{objectID: 1, online: false, at: from.Add(15 * time.Minute)},
+			},
+			want: []*Uptime{{ObjectID: 1, Percentage: 25, ObservedSeconds: 3600}},
+		},
+		"object first seen during window": {
+			before: map[int32]bool{},
+			changes: []statusSample{
+				{objectID: 2, online: true, at: from.Add(30 * time.Minute)},
+				{objectID: 2, online: false, at: from.Add(45 * time.Minute)},
+			},
+			want: []*Uptime{{ObjectID: 2, Percentage: 50, ObservedSeconds: 1800}},
+		},
+		"no changes during window": {
+			before: map[int32]bool{3: false, 1: true},
+			want: []*Uptime{
+				{ObjectID: 1, Percentage: 100, ObservedSeconds: 3600},
+				{ObjectID: 3, Percentage: 0, ObservedSeconds: 3600},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := computeUptime(tc.before, tc.changes, from, to)
+
+			diff := cmp.Diff(tc.want, got)
+			require.Empty(t, diff, "failed to get same uptime")
+		})
+	}
+}
+
+func TestObjectTimeline(t *testing.T) {
+	t.Parallel()
+
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.DebugLevel)
+
+	database, err := New(startDatabase(t, &zlog), &zlog)
+	require.Nil(t, err, "failed to establish connection")
+	t.Cleanup(func() {
+		assert.Nil(t, database.Close(), "failed to close connection")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// object goes online, stays online, and then goes offline
+	_, _, err = database.InsertObjectsOrUpdate(ctx, 1, []int32{42}, nil)
+	require.Nil(t, err, "failed to process objects")
+	_, _, err = database.InsertObjectsOrUpdate(ctx, 2, []int32{42}, nil)
+	require.Nil(t, err, "failed to process objects")
+	_, _, err = database.InsertObjectsOrUpdate(ctx, 3, nil, []int32{42})
+	require.Nil(t, err, "failed to process objects")
+
+	changes, err := database.ObjectTimeline(ctx, 42, time.Now().Add(-time.Hour), 100)
+	require.Nil(t, err, "failed to get object timeline")
+	require.Len(t, changes, 2, "only status changes should be recorded")
+
+	assert.Nil(t, changes[0].OldOnline, "first change should have no old status")
+	assert.True(t, changes[0].NewOnline, "object should go online first")
+	assert.Equal(t, int64(1), *changes[0].CallbackID, "first change should come from first callback")
+
+	assert.True(t, *changes[1].OldOnline, "object should be online before going offline")
+	assert.False(t, changes[1].NewOnline, "object should go offline")
+	assert.Equal(t, int64(3), *changes[1].CallbackID, "second change should come from third callback")
+
+	uptimes, err := database.Uptime(ctx, time.Hour)
+	require.Nil(t, err, "failed to get uptime")
+	require.Len(t, uptimes, 1, "expected uptime of one object")
+	assert.Equal(t, int32(42), uptimes[0].ObjectID, "got uptime of wrong object")
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrIdempotencyKeyReused is returned when idempotency key was already used with another payload
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with another payload")
+
+// IdempotencyKey identifies a callback, so retries of the same callback aren't processed twice
+type IdempotencyKey struct {
+	Key string
+
+	// Fingerprint is a hash of callback payload, key can't be reused with another payload
+	Fingerprint string
+
+	// TTL is how long key is remembered
+	TTL time.Duration
+}
+
+// SaveCallbackOnce persists received object ids in the callbacks inbox like SaveCallback,
+// unless a callback with the same idempotency key was already saved and the key hasn't expired yet.
+// In that case id of the original inbox entry is returned and duplicate is true,
+// ErrIdempotencyKeyReused is returned if the original callback had another payload.
+func (db *DB) SaveCallbackOnce(ctx context.Context, key *IdempotencyKey, objectIDs []int32) (id int64, duplicate bool, err error) {
+	subLogger := db.logger.With().Str("func", "SaveCallbackOnce").Logger()
+
+	conn, tx, err := db.startTx(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { // release connection to pool
+		if err := conn.Close(); err != nil {
+			subLogger.Warn().Err(err).Msg("failed to release connection to pool")
+		}
+	}()
+	finished := false
+	defer func() { // rollback tx if it wasn't finished
+		if !finished {
+			if terr := tx.Rollback(); terr != nil {
+				subLogger.Warn().Err(terr).Msg("failed to rollback transaction")
+			}
+		}
+	}()
+	txQ := db.q.WithTx(tx) // attach queries in tx
+
+	// expired key can be used again
+	if err := txQ.DeleteExpiredIdempotencyKey(ctx, key.Key); err != nil {
+		return 0, false, errors.WithMessage(err, "failed to delete expired idempotency key")
+	}
+
+	row, err := txQ.GetIdempotencyKey(ctx, key.Key)
+	switch {
+	case err == nil:
+		return originalCallback(key, row.Fingerprint, row.CallbackID)
+	case err != sql.ErrNoRows:
+		return 0, false, errors.WithMessage(err, "failed to get idempotency key")
+	}
+
+	id, err = txQ.InsertCallback(ctx, objectIDs)
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to insert callback")
+	}
+
+	n, err := txQ.InsertIdempotencyKey(ctx, objects.InsertIdempotencyKeyParams{
+		Key:         key.Key,
+		Fingerprint: key.Fingerprint,
+		CallbackID:  id,
+		TtlMs:       key.TTL.Milliseconds(),
+	})
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to insert idempotency key")
+	}
+
+	// concurrent callback with the same key was saved first, so this one is a duplicate,
+	// insert waits for the concurrent transaction, so the key can be read after rollback
+	if n == 0 {
+		if err := tx.Rollback(); err != nil {
+			subLogger.Warn().Err(err).Msg("failed to rollback transaction")
+		}
+		finished = true
+
+		row, err := db.q.GetIdempotencyKey(ctx, key.Key)
+		if err != nil {
+			return 0, false, errors.WithMessage(err, "failed to get idempotency key")
+		}
+		return originalCallback(key, row.Fingerprint, row.CallbackID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, errors.WithMessage(err, "failed to commit transaction")
+	}
+	finished = true
+
+	return id, false, nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes idempotency keys which TTL passed,
+// and returns amount of deleted keys
+func (db *DB) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	n, err := db.q.DeleteExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to delete expired idempotency keys")
+	}
+
+	return n, nil
+}
+
+// originalCallback returns id of callback that was saved with the key first,
+// or ErrIdempotencyKeyReused if it had another payload
+func originalCallback(key *IdempotencyKey, fingerprint string, callbackID int64) (int64, bool, error) {
+	if fingerprint != key.Fingerprint {
+		return 0, false, ErrIdempotencyKeyReused
+	}
+
+	return callbackID, true, nil
+}
@@ -0,0 +1,546 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"bitburst-assessment-task/internal/metrics"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// memCallback is an inbox entry of in-memory storage
+type memCallback struct {
+	id          int64
+	objectIDs   []int32
+	receivedAt  time.Time
+	processedAt *time.Time
+}
+
+// memIdempotencyKey is an idempotency key of in-memory storage
+type memIdempotencyKey struct {
+	fingerprint string
+	callbackID  int64
+	expiresAt   time.Time
+}
+
+// Memory is an in-memory storage, it's intended for tests and small deployments,
+// where losing data on restart is acceptable
+type Memory struct {
+	mu sync.Mutex
+
+	objects     map[int32]*objects.BitburstObject
+	history     []*StatusChange // ordered by id
+	callbacks   []*memCallback  // ordered by id
+	keys        map[string]*memIdempotencyKey
+	subs        []*WebhookSubscription
+	deadLetters []*WebhookDeadLetter
+
+	// last assigned ids, like sequences in database
+	lastHistoryID, lastCallbackID, lastSubID, lastDeadLetterID int64
+
+	// emitter notifies listeners about object events
+	emitter
+
+	logger *zerolog.Logger
+	conf   *Config
+}
+
+// NewMemory constructs new in-memory storage
+func NewMemory(conf *Config, logger *zerolog.Logger) (*Memory, error) {
+	if err := conf.validate(); err != nil {
+		return nil, errors.WithMessage(err, "invalid configuration")
+	}
+
+	return &Memory{
+		objects: make(map[int32]*objects.BitburstObject),
+		keys:    make(map[string]*memIdempotencyKey),
+		logger:  logger,
+		conf:    conf,
+	}, nil
+}
+
+// Ping always succeeds, as memory is always reachable
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// CheckMigrations always succeeds, as in-memory storage doesn't have schemas
+func (m *Memory) CheckMigrations(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+// Close does nothing, stored data is released with storage itself
+func (m *Memory) Close() error {
+	return nil
+}
+
+// InsertObjectsOrUpdate behaves the same as DB.InsertObjectsOrUpdate
+func (m *Memory) InsertObjectsOrUpdate(ctx context.Context, callbackID int64, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error) {
+	m.mu.Lock()
+
+	now := time.Now()
+
+	before := make(map[int32]bool, len(onlineIDs)+len(offlineIDs))
+	for _, ids := range [][]int32{onlineIDs, offlineIDs} {
+		for _, id := range ids {
+			if obj, ok := m.objects[id]; ok {
+				before[id] = obj.Online
+			}
+		}
+	}
+
+	upsert := func(id int32, online bool) (inserted bool) {
+		obj, ok := m.objects[id]
+		if !ok {
+			obj = &objects.BitburstObject{OID: id}
+			m.objects[id] = obj
+		}
+		obj.Online = online
+		obj.LastSeen = zero.TimeFrom(now)
+
+		return !ok
+	}
+
+	if m.conf.LegacyUpsert {
+		for _, id := range onlineIDs {
+			upsert(id, true)
+			insertedIDs = append(insertedIDs, id)
+		}
+		for _, id := range offlineIDs {
+			if obj, ok := m.objects[id]; ok {
+				obj.Online = false
+				updatedIDs = append(updatedIDs, id)
+			}
+		}
+	} else {
+		add := func(id int32, online bool) {
+			if upsert(id, online) {
+				insertedIDs = append(insertedIDs, id)
+			} else {
+				updatedIDs = append(updatedIDs, id)
+			}
+		}
+		for _, id := range onlineIDs {
+			add(id, true)
+		}
+		for _, id := range offlineIDs {
+			add(id, false)
+		}
+	}
+
+	ts := transitions(before, onlineIDs, offlineIDs, m.conf.LegacyUpsert)
+	for _, t := range ts {
+		m.lastHistoryID++
+		change := &StatusChange{
+			ID:         m.lastHistoryID,
+			ObjectID:   t.ObjectID,
+			OldOnline:  t.OldOnline,
+			NewOnline:  t.NewOnline,
+			ObservedAt: now,
+		}
+		if callbackID != 0 {
+			id := callbackID
+			change.CallbackID = &id
+		}
+		m.history = append(m.history, change)
+	}
+
+	m.mu.Unlock()
+
+	metrics.DBObjects.WithLabelValues("inserted").Add(float64(len(insertedIDs)))
+	metrics.DBObjects.WithLabelValues("updated").Add(float64(len(updatedIDs)))
+
+	m.emit(transitionEvents(ts, now))
+
+	return insertedIDs, updatedIDs, nil
+}
+
+// DeleteNotSeenObjects deletes objects that weren't seen during retention window every sweep interval,
+// it's to run in background. When a job is done on behalf of this function, context should be canceled.
+func (m *Memory) DeleteNotSeenObjects(ctx context.Context) {
+	runSweeper(ctx, m.conf.SweepInterval, nil, m.SweepNotSeenObjects, m.logger)
+}
+
+// SweepNotSeenObjects runs one sweep of objects that weren't seen during retention window,
+// and returns ids of deleted objects
+func (m *Memory) SweepNotSeenObjects(ctx context.Context) (deletedIDs []int32, err error) {
+	m.mu.Lock()
+
+	now := time.Now()
+	deadline := now.Add(-m.conf.Retention)
+	deleted := make(map[int32]bool)
+	for id, obj := range m.objects {
+		if obj.LastSeen.Time.Before(deadline) {
+			delete(m.objects, id)
+			deletedIDs = append(deletedIDs, id)
+			deleted[id] = obj.Online
+		}
+	}
+
+	// record that deleted objects went away, so they don't count as online in uptime anymore
+	for _, t := range expiredTransitions(deleted) {
+		m.lastHistoryID++
+		m.history = append(m.history, &StatusChange{
+			ID:         m.lastHistoryID,
+			ObjectID:   t.ObjectID,
+			OldOnline:  t.OldOnline,
+			NewOnline:  t.NewOnline,
+			ObservedAt: now,
+		})
+	}
+
+	if m.conf.HistoryRetention > 0 {
+		m.pruneHistory(now.Add(-m.conf.HistoryRetention))
+	}
+
+	m.mu.Unlock()
+
+	sort.Slice(deletedIDs, func(i, j int) bool { return deletedIDs[i] < deletedIDs[j] })
+
+	metrics.SweepDeletedObjects.Observe(float64(len(deletedIDs)))
+	metrics.DBObjects.WithLabelValues("deleted").Add(float64(len(deletedIDs)))
+
+	m.emit(expiredEvents(deletedIDs, now))
+
+	return deletedIDs, nil
+}
+
+// pruneHistory deletes status changes observed before given time, the latest of them is kept for objects that still exist,
+// so their status at the start of a window is known. It must be called with mutex locked
+func (m *Memory) pruneHistory(before time.Time) {
+	latest := make(map[int32]int64)
+	for _, change := range m.history {
+		if change.ObservedAt.Before(before) {
+			latest[change.ObjectID] = change.ID
+		}
+	}
+
+	kept := m.history[:0]
+	for _, change := range m.history {
+		_, exists := m.objects[change.ObjectID]
+		if !change.ObservedAt.Before(before) || (exists && latest[change.ObjectID] == change.ID) {
+			kept = append(kept, change)
+		}
+	}
+	for i := len(kept); i < len(m.history); i++ {
+		m.history[i] = nil
+	}
+	m.history = kept
+}
+
+// GetObject returns object by it's id, or ErrObjectNotFound if it doesn't exist
+func (m *Memory) GetObject(ctx context.Context, id int32) (*objects.BitburstObject, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[id]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+
+	objCopy := *obj
+	return &objCopy, nil
+}
+
+// ListObjects returns objects that match the filter ordered by id
+func (m *Memory) ListObjects(ctx context.Context, filter *ListObjectsFilter) ([]objects.BitburstObject, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objs := make([]objects.BitburstObject, 0)
+	for _, obj := range m.objects {
+		switch {
+		case filter.After != nil && obj.OID <= *filter.After:
+		case filter.Online != nil && obj.Online != *filter.Online:
+		case filter.SeenAfter != nil && obj.LastSeen.Time.Before(*filter.SeenAfter):
+		case filter.SeenBefore != nil && !obj.LastSeen.Time.Before(*filter.SeenBefore):
+		default:
+			objs = append(objs, *obj)
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].OID < objs[j].OID })
+
+	if int(filter.Limit) < len(objs) {
+		objs = objs[:filter.Limit]
+	}
+
+	return objs, nil
+}
+
+// ObjectTimeline returns at most limit status changes of an object that were observed since given time, oldest first
+func (m *Memory) ObjectTimeline(ctx context.Context, id int32, since time.Time, limit int32) ([]*StatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changes := make([]*StatusChange, 0)
+	for _, change := range m.history {
+		if len(changes) >= int(limit) {
+			break
+		}
+		if change.ObjectID == id && !change.ObservedAt.Before(since) {
+			changeCopy := *change
+			changes = append(changes, &changeCopy)
+		}
+	}
+
+	return changes, nil
+}
+
+// Uptime returns uptime percentage of every object that has status history during the window ending now
+func (m *Memory) Uptime(ctx context.Context, window time.Duration) ([]*Uptime, error) {
+	to := time.Now()
+	from := to.Add(-window)
+
+	m.mu.Lock()
+
+	// history is ordered by observation time, so the last status before window wins
+	before := make(map[int32]bool)
+	changes := make([]statusSample, 0)
+	for _, change := range m.history {
+		if change.ObservedAt.Before(from) {
+			before[change.ObjectID] = change.NewOnline
+			continue
+		}
+		changes = append(changes, statusSample{objectID: change.ObjectID, online: change.NewOnline, at: change.ObservedAt})
+	}
+
+	m.mu.Unlock()
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].objectID < changes[j].objectID })
+
+	return computeUptime(before, changes, from, to), nil
+}
+
+// SaveCallback persists received object ids in the callbacks inbox and returns id of the inbox entry
+func (m *Memory) SaveCallback(ctx context.Context, objectIDs []int32) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastCallbackID++
+	m.callbacks = append(m.callbacks, &memCallback{
+		id:         m.lastCallbackID,
+		objectIDs:  append([]int32(nil), objectIDs...),
+		receivedAt: time.Now(),
+	})
+
+	return m.lastCallbackID, nil
+}
+
+// SaveCallbackOnce behaves the same as DB.SaveCallbackOnce
+func (m *Memory) SaveCallbackOnce(ctx context.Context, key *IdempotencyKey, objectIDs []int32) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if k, ok := m.keys[key.Key]; ok && now.Before(k.expiresAt) {
+		return originalCallback(key, k.fingerprint, k.callbackID)
+	}
+
+	m.lastCallbackID++
+	m.callbacks = append(m.callbacks, &memCallback{
+		id:         m.lastCallbackID,
+		objectIDs:  append([]int32(nil), objectIDs...),
+		receivedAt: now,
+	})
+	m.keys[key.Key] = &memIdempotencyKey{
+		fingerprint: key.Fingerprint,
+		callbackID:  m.lastCallbackID,
+		expiresAt:   now.Add(key.TTL),
+	}
+
+	return m.lastCallbackID, false, nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes idempotency keys which TTL passed,
+// and returns amount of deleted keys
+func (m *Memory) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	var n int64
+	for key, k := range m.keys {
+		if !now.Before(k.expiresAt) {
+			delete(m.keys, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// MarkCallbackProcessed marks inbox entry as done, so it won't be replayed again
+func (m *Memory) MarkCallbackProcessed(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.callbacks {
+		if c.id == id {
+			now := time.Now()
+			c.processedAt = &now
+			break
+		}
+	}
+
+	return nil
+}
+
+// UnprocessedCallbacks returns at most limit inbox entries that weren't marked as done
+// and that were received at least minAge ago, oldest entries come first
+func (m *Memory) UnprocessedCallbacks(ctx context.Context, minAge time.Duration, limit int32) ([]*Callback, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	receivedBefore := time.Now().Add(-minAge)
+
+	callbacks := make([]*Callback, 0)
+	for _, c := range m.callbacks {
+		if len(callbacks) >= int(limit) {
+			break
+		}
+		if c.processedAt == nil && !c.receivedAt.After(receivedBefore) {
+			callbacks = append(callbacks, &Callback{
+				ID:         c.id,
+				ObjectIDs:  append([]int32(nil), c.objectIDs...),
+				ReceivedAt: c.receivedAt,
+			})
+		}
+	}
+
+	return callbacks, nil
+}
+
+// DeleteProcessedCallbacks deletes inbox entries that were processed more than retention ago,
+// and returns amount of deleted entries
+func (m *Memory) DeleteProcessedCallbacks(ctx context.Context, retention time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	processedBefore := time.Now().Add(-retention)
+
+	kept := m.callbacks[:0]
+	for _, c := range m.callbacks {
+		if c.processedAt == nil || !c.processedAt.Before(processedBefore) {
+			kept = append(kept, c)
+		}
+	}
+	n := int64(len(m.callbacks) - len(kept))
+	m.callbacks = kept
+
+	return n, nil
+}
+
+// CreateWebhookSubscription stores a new webhook subscription
+func (m *Memory) CreateWebhookSubscription(ctx context.Context, url, secret string, events []EventType) (*WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSubID++
+	sub := &WebhookSubscription{
+		ID:        m.lastSubID,
+		URL:       url,
+		Secret:    secret,
+		Events:    append(make([]EventType, 0, len(events)), events...),
+		CreatedAt: time.Now(),
+	}
+	m.subs = append(m.subs, sub)
+
+	subCopy := *sub
+	return &subCopy, nil
+}
+
+// WebhookSubscriptions returns all webhook subscriptions
+func (m *Memory) WebhookSubscriptions(ctx context.Context) ([]*WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subs := make([]*WebhookSubscription, 0, len(m.subs))
+	for _, sub := range m.subs {
+		subCopy := *sub
+		subs = append(subs, &subCopy)
+	}
+
+	return subs, nil
+}
+
+// DeleteWebhookSubscription deletes webhook subscription with it's dead letters,
+// or returns ErrWebhookSubscriptionNotFound if it doesn't exist
+func (m *Memory) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.webhookSubscriptionIndex(id)
+	if i < 0 {
+		return ErrWebhookSubscriptionNotFound
+	}
+	m.subs = append(m.subs[:i], m.subs[i+1:]...)
+
+	kept := m.deadLetters[:0]
+	for _, dl := range m.deadLetters {
+		if dl.SubscriptionID != id {
+			kept = append(kept, dl)
+		}
+	}
+	m.deadLetters = kept
+
+	return nil
+}
+
+// SaveWebhookDeadLetter stores a webhook delivery that failed after all retries
+func (m *Memory) SaveWebhookDeadLetter(ctx context.Context, subscriptionID int64, payload []byte, attempts int, lastErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.webhookSubscriptionIndex(subscriptionID) < 0 {
+		return errors.WithMessage(ErrWebhookSubscriptionNotFound, "failed to insert webhook dead letter")
+	}
+
+	m.lastDeadLetterID++
+	m.deadLetters = append(m.deadLetters, &WebhookDeadLetter{
+		ID:             m.lastDeadLetterID,
+		SubscriptionID: subscriptionID,
+		Payload:        append([]byte(nil), payload...),
+		Attempts:       int32(attempts),
+		LastError:      lastErr.Error(),
+		FailedAt:       time.Now(),
+	})
+
+	return nil
+}
+
+// WebhookDeadLetters returns at most limit dead letters with id greater than after, oldest first
+func (m *Memory) WebhookDeadLetters(ctx context.Context, after int64, limit int32) ([]*WebhookDeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	letters := make([]*WebhookDeadLetter, 0)
+	for _, dl := range m.deadLetters {
+		if len(letters) >= int(limit) {
+			break
+		}
+		if dl.ID > after {
+			dlCopy := *dl
+			letters = append(letters, &dlCopy)
+		}
+	}
+
+	return letters, nil
+}
+
+// webhookSubscriptionIndex returns index of subscription in subs, or -1 if it doesn't exist,
+// it must be called with mu locked
+func (m *Memory) webhookSubscriptionIndex(id int64) int {
+	for i, sub := range m.subs {
+		if sub.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bitburst."callbacks" CASCADE;
\ No newline at end of file
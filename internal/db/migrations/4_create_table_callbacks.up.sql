@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS bitburst."callbacks" (
+	c_id BIGSERIAL PRIMARY KEY NOT NULL,
+	object_ids INTEGER[] NOT NULL,
+	received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	processed_at TIMESTAMPTZ NULL
+);
+
+-- create a partial index on unprocessed callbacks, so replaying the inbox stays fast when it grows
+CREATE INDEX IF NOT EXISTS callbacks_unprocessed_idx ON bitburst."callbacks" (c_id) WHERE processed_at IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bitburst."object_status_history" CASCADE;
\ No newline at end of file
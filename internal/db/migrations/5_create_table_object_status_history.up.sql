@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS bitburst."object_status_history" (
+	h_id BIGSERIAL PRIMARY KEY NOT NULL,
+	o_id INTEGER NOT NULL,
+	old_online BOOLEAN NULL, -- NULL when object wasn't stored before
+	new_online BOOLEAN NOT NULL,
+	observed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	callback_id BIGINT NULL
+);
+
+-- create indexes for fetching timeline of an object and history of all objects in a window
+CREATE INDEX IF NOT EXISTS object_status_history_o_id_idx ON bitburst."object_status_history" (o_id, observed_at);
+CREATE INDEX IF NOT EXISTS object_status_history_observed_at_idx ON bitburst."object_status_history" (observed_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bitburst."webhook_dead_letters" CASCADE;
+DROP TABLE IF EXISTS bitburst."webhook_subscriptions" CASCADE;
\ No newline at end of file
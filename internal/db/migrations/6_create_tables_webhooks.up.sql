@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS bitburst."webhook_subscriptions" (
+	w_id BIGSERIAL PRIMARY KEY NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL, -- empty array means all events
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- deliveries that failed after all retries end up here
+CREATE TABLE IF NOT EXISTS bitburst."webhook_dead_letters" (
+	d_id BIGSERIAL PRIMARY KEY NOT NULL,
+	w_id BIGINT NOT NULL REFERENCES bitburst."webhook_subscriptions" ( w_id ) ON DELETE CASCADE,
+	payload JSONB NOT NULL,
+	attempts INTEGER NOT NULL,
+	last_error TEXT NOT NULL,
+	failed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
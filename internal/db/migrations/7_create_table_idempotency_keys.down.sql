@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bitburst."idempotency_keys" CASCADE;
\ No newline at end of file
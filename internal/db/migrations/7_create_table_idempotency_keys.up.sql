@@ -0,0 +1,10 @@
+-- idempotency keys of callbacks, so callbacks retried by tester service aren't processed twice
+CREATE TABLE IF NOT EXISTS bitburst."idempotency_keys" (
+	key TEXT PRIMARY KEY NOT NULL,
+	fingerprint TEXT NOT NULL, -- hash of callback payload, key can't be reused with another payload
+	callback_id BIGINT NOT NULL, -- not a foreign key, as processed callbacks can be deleted before key expires
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON bitburst."idempotency_keys" ( expires_at );
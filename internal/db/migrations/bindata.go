@@ -11,6 +11,14 @@
 //  internal/db/migrations/2_create_table_objects.up.sql
 //  internal/db/migrations/3_create_index_last_seen.down.sql
 //  internal/db/migrations/3_create_index_last_seen.up.sql
+//  internal/db/migrations/4_create_table_callbacks.down.sql
+//  internal/db/migrations/4_create_table_callbacks.up.sql
+//  internal/db/migrations/5_create_table_object_status_history.down.sql
+//  internal/db/migrations/5_create_table_object_status_history.up.sql
+//  internal/db/migrations/6_create_tables_webhooks.down.sql
+//  internal/db/migrations/6_create_tables_webhooks.up.sql
+//  internal/db/migrations/7_create_table_idempotency_keys.down.sql
+//  internal/db/migrations/7_create_table_idempotency_keys.up.sql
 
 package migrations
 
@@ -67,14 +75,14 @@ var _bindata = map[string]*asset{
 		name: "1_create_schema_bitburst.down.sql",
 		data: "" +
 			"\x72\x09\xf2\x0f\x50\x08\x76\xf6\x70\xf5\x75\x54\xf0\x74\x53\xf0\xf3\x0f\x51\x70\x8d\xf0\x0c\x0e" +
-			"\x09\x56\x48\xca\x2c\x49\x2a\x2d\x2a\x2e\xb1\x06\x04\x00\x00\xff\xff",
+			"\x09\x56\x48\xca\x2c\x49\x2a\x2d\x2a\x2e\xb1\x06\x0c\x00",
 		size: 35,
 	},
 	"1_create_schema_bitburst.up.sql": &asset{
 		name: "1_create_schema_bitburst.up.sql",
 		data: "" +
 			"\x72\x0e\x72\x75\x0c\x71\x55\x08\x76\xf6\x70\xf5\x75\x54\xf0\x74\x53\xf0\xf3\x0f\x51\x70\x8d\xf0" +
-			"\x0c\x0e\x09\x56\x48\xca\x2c\x49\x2a\x2d\x2a\x2e\xb1\x06\x04\x00\x00\xff\xff",
+			"\x0c\x0e\x09\x56\x48\xca\x2c\x49\x2a\x2d\x2a\x2e\xb1\x06\x0c\x00",
 		size: 37,
 	},
 	"2_create_table_objects.down.sql": &asset{
@@ -82,7 +90,7 @@ var _bindata = map[string]*asset{
 		data: "" +
 			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\xca\x2c" +
 			"\x49\x2a\x2d\x2a\x2e\xd1\x53\xca\x4f\xca\x4a\x4d\x2e\x29\x56\x52\x70\x76\x0c\x76\x76\x74\x71\xb5" +
-			"\x06\x04\x00\x00\xff\xff",
+			"\x06\x0c\x00",
 		size: 48,
 	},
 	"2_create_table_objects.up.sql": &asset{
@@ -96,15 +104,14 @@ var _bindata = map[string]*asset{
 			"\x69\x23\x8a\xb9\x4f\xb9\x4b\xd6\x7a\xb0\xaa\xa8\x61\x59\x9d\xf9\xe3\xaf\x79\x68\x8d\x21\xcd\xdd" +
 			"\x53\x10\xeb\x9d\x10\xdb\x2d\xc6\x68\xfb\x6c\xd1\x7b\x38\x3f\xd9\x07\x82\xc7\xf2\x6c\x0c\xf3\xfd" +
 			"\xcb\xe3\x16\x22\x6e\x7d\xca\x36\x22\xda\x7e\x4a\xe2\xb7\x53\xe9\x23\x5d\x16\xb3\x73\xd3\x03\xb5" +
-			"\xfe\xb7\xf2\x87\xaf\x77\xdf\x01\x00\x00\xff\xff",
+			"\xfe\xb7\xf2\x87\xaf\x77\xdf\x03\x00",
 		size: 283,
 	},
 	"3_create_index_last_seen.down.sql": &asset{
 		name: "3_create_index_last_seen.down.sql",
 		data: "" +
 			"\x72\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\xca\x2c" +
-			"\x49\x2a\x2d\x2a\x2e\xd1\xcb\x49\x2c\x2e\x89\x2f\x4e\x4d\xcd\x8b\xcf\x4c\xa9\xb0\x06\x04\x00\x00" +
-			"\xff\xff",
+			"\x49\x2a\x2d\x2a\x2e\xd1\xcb\x49\x2c\x2e\x89\x2f\x4e\x4d\xcd\x8b\xcf\x4c\xa9\xb0\x06\x0c\x00",
 		size: 44,
 	},
 	"3_create_index_last_seen.up.sql": &asset{
@@ -114,9 +121,115 @@ var _bindata = map[string]*asset{
 			"\x0c\xdd\x4a\xfe\x9c\x42\x24\x9e\x40\xce\x29\xf4\xf1\xdd\x74\xff\xbe\x71\x44\xea\x14\x94\x10\x18" +
 			"\x85\x33\x1d\x68\x8c\x1a\x44\x57\x21\x62\xa4\x56\xf7\x37\x63\x6b\x1d\x5b\x10\xa5\x8e\x4c\x95\x94" +
 			"\xe4\xef\xf6\x34\x57\x6f\x60\xdd\xdd\x2c\xb0\x0f\xb8\xc9\xc3\x2c\x76\xf6\xf3\xaf\xaf\x25\x1f\x98" +
-			"\x1c\x62\xd1\xb8\x77\xd1\xf3\xd0\xe2\x8b\x92\xca\x80\xff\x2f\x3a\x5d\x3e\x01\x00\x00\xff\xff",
+			"\x1c\x62\xd1\xb8\x77\xd1\xf3\xd0\xe2\x8b\x92\xca\x80\xff\x2f\x3a\x5d\x3e\x03\x00",
 		size: 133,
 	},
+	"4_create_table_callbacks.down.sql": &asset{
+		name: "4_create_table_callbacks.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\xca\x2c" +
+			"\x49\x2a\x2d\x2a\x2e\xd1\x53\x4a\x4e\xcc\xc9\x49\x4a\x4c\xce\x2e\x56\x52\x70\x76\x0c\x76\x76\x74" +
+			"\x71\xb5\x06\x0c\x00",
+		size: 50,
+	},
+	"4_create_table_callbacks.up.sql": &asset{
+		name: "4_create_table_callbacks.up.sql",
+		data: "" +
+			"\x74\x90\xc1\x6e\xea\x30\x14\x44\xd7\xf8\x2b\x46\xac\x1e\x12\xbc\x1f\x60\x15\xe0\x42\xad\x9a\x80" +
+			"\x1c\x47\x85\x56\x55\xe4\x38\x2e\xb8\x8d\x12\x64\x9b\x02\x7f\x5f\x15\x55\x11\xad\xda\xf5\x9d\x99" +
+			"\xab\x73\xa6\x92\x12\x45\x50\xc9\x44\x10\xf8\x1c\xe9\x4a\x81\x36\x3c\x53\x19\x4a\x17\xcb\xa3\x0f" +
+			"\xf1\x7f\xdf\xe8\xba\x2e\xb5\x79\x0b\x7d\xfc\x63\x3d\x53\xb8\x0a\x13\xbe\xc8\x48\xf2\x44\x60\x2d" +
+			"\xf9\x32\x91\x5b\xdc\xd3\xf6\xda\x4e\x73\x21\x86\xac\xd7\x96\xaf\xd6\xc4\xc2\x55\x01\x3c\x55\xb4" +
+			"\x20\xf9\xf4\x7c\x7b\xf7\xd6\x58\xf7\x6e\xab\x42\x47\x28\xbe\xa4\x4c\x25\xcb\xb5\x7a\xec\x22\x98" +
+			"\xd1\x3c\xc9\x85\xc2\x34\x97\x92\x52\x55\x74\xa1\x21\xeb\x1d\x7c\x6b\x6c\x08\xbf\xb4\x73\x21\xd8" +
+			"\x60\xcc\xd8\x68\x04\xe3\xad\x8e\x16\x1a\x07\xed\xa3\xd3\x35\x5c\x53\xd9\x33\xda\x06\xc7\xa6\x1b" +
+			"\x40\xc7\x36\x44\x68\xe1\xed\xa1\xd6\x17\xd7\xec\x10\xf7\x16\xae\x29\xdb\x33\x42\xd4\x97\x80\x17" +
+			"\x1d\x22\x4e\x7b\xdb\xc0\x45\xec\x7c\x7b\x0a\xec\xcb\x1d\x4f\x67\xb4\xf9\xe1\xae\x5b\x2d\x6e\x7e" +
+			"\x15\xae\x3a\x63\x95\xfe\x21\xf6\x53\xeb\x00\x0f\x77\x24\x09\xdf\xf8\x78\x76\xc5\x1a\xb3\x8f\x01" +
+			"\x00",
+		size: 428,
+	},
+	"5_create_table_object_status_history.down.sql": &asset{
+		name: "5_create_table_object_status_history.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\xca\x2c" +
+			"\x49\x2a\x2d\x2a\x2e\xd1\x53\xca\x4f\xca\x4a\x4d\x2e\x89\x2f\x2e\x49\x2c\x29\x2d\x8e\xcf\xc8\x2c" +
+			"\x2e\xc9\x2f\xaa\x54\x52\x70\x76\x0c\x76\x76\x74\x71\xb5\x06\x0c\x00",
+		size: 62,
+	},
+	"5_create_table_object_status_history.up.sql": &asset{
+		name: "5_create_table_object_status_history.up.sql",
+		data: "" +
+			"\x9c\x91\xc1\x8e\x9b\x30\x18\x84\xcf\xf8\x29\x46\x7b\x69\x57\x0a\x7d\x81\x3d\x91\x5d\x6f\x64\x95" +
+			"\x98\x08\x1c\x29\xe9\xc5\x32\xd8\x14\xb7\xd4\x96\xb0\x53\xd2\xb7\xaf\x20\x28\x4d\xda\x1e\xda\xbd" +
+			"\x21\x66\xfe\xff\xf3\x3f\xf3\x5c\xd2\x4c\x50\x88\x6c\x9d\x53\xb0\x57\xf0\x42\x80\x1e\x58\x25\x2a" +
+			"\xd4\x36\xd6\xa7\x21\xc4\x0f\x0f\xbe\xfe\x62\x9a\x28\x43\x54\xf1\x14\x64\x67\x43\xf4\xc3\x8f\x07" +
+			"\xbc\x27\x49\x27\xad\xc6\x9a\x6d\x2a\x5a\xb2\x2c\xc7\xae\x64\xdb\xac\x3c\xe2\x23\x3d\xce\x9b\xf8" +
+			"\x3e\xcf\x57\x24\xf1\x93\x8b\x71\x41\x37\xb4\xbc\xfb\xdf\x6b\xe9\x5d\x6f\x9d\xc1\xba\x28\x72\x9a" +
+			"\xf1\x8b\x82\x34\x9d\x3f\x30\x76\xc6\xe1\x42\xc7\xa8\x82\x7b\x17\x31\xb1\x8d\x46\x6d\x5a\x3f\x18" +
+			"\x92\x38\x33\xfe\xb1\xe2\x06\x50\x07\x33\x7c\x37\x5a\xaa\x08\xc1\xb6\xb4\x12\xd9\x76\x27\x3e\x5d" +
+			"\x2d\x78\xa1\xaf\xd9\x3e\x17\x78\xde\x97\x25\xe5\x42\x5e\x4d\x2b\x92\x34\xaa\xef\x6b\xd5\x7c\x5d" +
+			"\x4e\x64\xfc\x32\x43\x1e\x9f\x08\x49\x53\x34\x83\x51\xd1\xc0\x3a\x6d\xce\x26\xa0\xf5\x03\x5a\x13" +
+			"\x9b\xce\xba\xcf\x88\xf6\x9b\x99\xdf\xe4\x5b\xa8\xeb\x05\xca\x69\x2c\xe1\xcd\x42\xdf\x2f\x4a\x80" +
+			"\x75\x50\x18\xad\xd3\x7e\x24\x4b\x25\x8c\xbf\xd0\xc3\x6f\x95\xfc\xb5\x08\x39\xc5\x2b\xad\x3e\xa3" +
+			"\xe0\xff\x50\xda\xe4\x5e\xe1\x26\x99\xc7\xa7\x37\x20\x7f\x8d\xff\x07\xf9\x8e\xf9\x73\x00",
+		size: 634,
+	},
+	"6_create_tables_webhooks.down.sql": &asset{
+		name: "6_create_tables_webhooks.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\xca\x2c" +
+			"\x49\x2a\x2d\x2a\x2e\xd1\x53\x2a\x4f\x4d\xca\xc8\xcf\xcf\x8e\x4f\x49\x4d\x4c\x89\xcf\x49\x2d\x29" +
+			"\x49\x2d\x2a\x56\x52\x70\x76\x0c\x76\x76\x74\x71\xb5\xe6\x22\x52\x77\x71\x69\x52\x71\x72\x51\x66" +
+			"\x41\x49\x66\x7e\x1e\x92\x76\xc0\x00",
+		size: 124,
+	},
+	"6_create_tables_webhooks.up.sql": &asset{
+		name: "6_create_tables_webhooks.up.sql",
+		data: "" +
+			"\x9c\x91\x41\x6b\x1b\x31\x14\x84\xcf\xd6\xaf\x18\x72\x6a\x20\xee\x1f\xe8\x49\x5e\x3f\x07\xb5\x6b" +
+			"\x39\x68\x65\x48\x5a\xca\x22\x5b\x2f\x78\xa9\xe2\x5d\xa4\xb7\x09\xfe\xf7\xa5\x6b\x9a\x18\x72\x49" +
+			"\x7b\x9d\x79\x6f\x06\xbe\xa9\x1c\x69\x4f\xf0\x7a\x51\x13\xcc\x0a\x76\xe3\x41\xf7\xa6\xf1\x0d\x76" +
+			"\x9d\xec\xc6\x5c\xe4\xf3\xd5\x0b\xef\x0e\x7d\xff\xab\x2d\xe3\xae\xec\x73\x37\x48\xd7\x1f\xcb\x15" +
+			"\x3e\xa9\xd9\x4b\xdb\x45\x2c\xcc\x6d\x43\xce\xe8\x1a\x77\xce\xac\xb5\x7b\xc0\x37\x7a\x98\x92\xec" +
+			"\xb6\xae\x6f\xd4\x6c\xcc\x09\x9e\xee\xfd\xa5\x56\x78\x9f\x59\xde\xc9\xfc\xcc\x47\x29\x93\xfc\xe3" +
+			"\xe7\x9b\x81\xf9\x1c\xfc\x34\xc8\x09\x21\xe7\x70\xc2\x13\x87\x63\x41\x48\x09\xe7\x07\x35\xdb\x67" +
+			"\x0e\xc2\xb1\x0d\x02\x6f\xd6\xd4\x78\xbd\xbe\xf3\xdf\x5f\x13\xb0\xa4\x95\xde\xd6\x1e\xd5\xd6\x39" +
+			"\xb2\xbe\x7d\x3d\x52\xd7\x5f\x94\x9a\xcf\x11\x39\x75\xcf\x9c\x3b\x2e\x90\x43\x10\x3c\x86\x2e\x71" +
+			"\x44\x78\x14\xce\x53\x53\x66\x99\x5c\x3e\x46\x8c\x03\x0e\x9c\x59\xfd\x13\xbe\xc8\x21\xb6\x89\x45" +
+			"\x38\x9f\xe9\xc5\x0f\xd1\xfb\xcb\xd8\xd8\x37\x52\x70\xb4\x22\x47\xb6\xa2\x8f\xec\x84\x29\xe2\x1a" +
+			"\x1b\x8b\x25\xd5\xe4\x09\x95\x6e\x2a\xbd\xa4\x1b\x35\x1b\xc2\x29\xf5\x21\xe2\x6b\xb3\xb1\x8b\xcb" +
+			"\xda\x20\xf2\x87\x78\x81\xb1\x9e\x6e\xc9\x5d\x7a\x29\x14\x69\x39\xe7\x3e\xbf\x1b\xf0\x8c\xed\xff" +
+			"\x66\xf8\x3d\x00",
+		size: 653,
+	},
+	"7_create_table_idempotency_keys.down.sql": &asset{
+		name: "7_create_table_idempotency_keys.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\xca\x2c" +
+			"\x49\x2a\x2d\x2a\x2e\xd1\x53\xca\x4c\x49\xcd\x2d\xc8\x2f\x49\xcd\x4b\xae\x8c\xcf\x4e\xad\x2c\x56" +
+			"\x52\x70\x76\x0c\x76\x76\x74\x71\xb5\x06\x0c\x00",
+		size: 57,
+	},
+	"7_create_table_idempotency_keys.up.sql": &asset{
+		name: "7_create_table_idempotency_keys.up.sql",
+		data: "" +
+			"\x7c\x51\xdd\x6a\xdb\x30\x18\xbd\xb6\x9e\xe2\xd0\x9b\xad\x60\xef\x05\x7a\xe5\xb6\xea\x30\x73\xdc" +
+			"\xe2\x28\x90\xee\xc6\xc8\xd2\x97\x5a\xc4\x93\x8c\xa4\xae\xf1\xdb\x0f\x65\x4b\x1c\x02\xeb\xa5\xd0" +
+			"\xf9\xfb\xce\x29\x0a\x18\x4d\xbf\x26\x17\xc9\xaa\x19\x7b\x9a\x03\xdc\x0e\x4a\x8e\x63\x2f\xd5\x3e" +
+			"\xe4\x08\x6e\x79\xc1\x53\xf4\x86\x34\xfa\x19\x91\x42\x24\x8f\x40\xfe\xb7\x51\x04\xe9\xc9\x7e\x89" +
+			"\x98\xbc\x53\x14\x02\x69\xc4\x0f\xa3\x88\x3d\xb4\xbc\x14\x1c\xa2\xbc\xaf\x39\xaa\x27\x34\xcf\x02" +
+			"\x7c\x5b\xad\xc5\x1a\xbd\x89\xfd\xbb\x0f\xf1\xdb\xcd\x45\x82\x2e\x25\xb8\xc1\x57\x96\xed\x69\x86" +
+			"\xe0\x5b\x81\x97\xb6\x5a\x95\xed\x2b\x7e\xf0\xd7\x23\xbd\xd9\xd4\x75\xce\xb2\x9d\xb1\x6f\xe4\x27" +
+			"\x6f\x6c\xfc\x8b\x3b\xff\xa1\x28\x30\xc8\x30\x5c\x1e\x82\x49\xce\xa3\x93\x3a\x4f\x27\x42\xc9\x94" +
+			"\xb5\x27\x78\x7a\x4f\x59\x3f\x4c\x1c\x20\xad\x8b\x03\xf9\x13\x92\x65\x27\x6e\x67\x34\xee\xab\xef" +
+			"\x55\x73\xe5\x61\x5d\x84\xc4\xce\x79\x32\x6f\x36\xe9\xe6\x90\xe1\xa2\x81\xa5\x36\x25\x6d\x72\xd3" +
+			"\x34\x52\x4c\xed\x51\x22\x1d\x93\xd0\x61\x32\x9e\x02\xcb\x94\x27\x19\x49\x77\x32\x42\x54\x2b\xbe" +
+			"\x16\xe5\xea\x45\xfc\x3c\x1b\xe2\x91\x3f\x95\x9b\x5a\xe0\x61\xd3\xb6\xbc\x11\xdd\x19\x94\xb3\xec" +
+			"\x9f\xc8\xff\xb8\xec\xf6\x8e\x9d\x86\xa8\x9a\x47\xbe\xbd\x1a\xe2\xba\xfe\x6e\xd1\xeb\x8c\x3e\xe0" +
+			"\xb9\xf9\x7c\x2b\x2c\x78\xdc\xde\xb1\x3f\x03\x00",
+		size: 595,
+	},
 }
 
 // AssetAndInfo loads and returns the asset and asset info for the
@@ -238,10 +351,18 @@ func AssetDir(name string) ([]string, error) {
 type bintree map[string]bintree
 
 var _bintree = bintree{
-	"1_create_schema_bitburst.down.sql": bintree{},
-	"1_create_schema_bitburst.up.sql":   bintree{},
-	"2_create_table_objects.down.sql":   bintree{},
-	"2_create_table_objects.up.sql":     bintree{},
-	"3_create_index_last_seen.down.sql": bintree{},
-	"3_create_index_last_seen.up.sql":   bintree{},
+	"1_create_schema_bitburst.down.sql":             bintree{},
+	"1_create_schema_bitburst.up.sql":               bintree{},
+	"2_create_table_objects.down.sql":               bintree{},
+	"2_create_table_objects.up.sql":                 bintree{},
+	"3_create_index_last_seen.down.sql":             bintree{},
+	"3_create_index_last_seen.up.sql":               bintree{},
+	"4_create_table_callbacks.down.sql":             bintree{},
+	"4_create_table_callbacks.up.sql":               bintree{},
+	"5_create_table_object_status_history.down.sql": bintree{},
+	"5_create_table_object_status_history.up.sql":   bintree{},
+	"6_create_tables_webhooks.down.sql":             bintree{},
+	"6_create_tables_webhooks.up.sql":               bintree{},
+	"7_create_table_idempotency_keys.down.sql":      bintree{},
+	"7_create_table_idempotency_keys.up.sql":        bintree{},
 }
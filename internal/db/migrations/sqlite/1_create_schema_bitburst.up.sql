@@ -0,0 +1,2 @@
+-- sqlite doesn't have schemas, tables live in the main database
+SELECT 1;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS objects;
\ No newline at end of file
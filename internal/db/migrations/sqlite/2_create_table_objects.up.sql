@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS objects (
+	o_id INTEGER PRIMARY KEY NOT NULL,
+	online BOOLEAN NOT NULL DEFAULT TRUE,
+	last_seen INTEGER NULL -- unix time in microseconds
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS last_seen_idx;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- create an index on last_seen column for faster deletes
+CREATE INDEX IF NOT EXISTS last_seen_idx ON objects (last_seen);
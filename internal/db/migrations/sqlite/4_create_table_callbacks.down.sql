@@ -0,0 +1 @@
+DROP TABLE IF EXISTS callbacks;
\ No newline at end of file
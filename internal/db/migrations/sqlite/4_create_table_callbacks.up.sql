@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS callbacks (
+	c_id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	object_ids TEXT NOT NULL, -- json array
+	received_at INTEGER NOT NULL, -- unix time in microseconds
+	processed_at INTEGER NULL
+);
+
+-- create a partial index on unprocessed callbacks, so replaying the inbox stays fast when it grows
+CREATE INDEX IF NOT EXISTS callbacks_unprocessed_idx ON callbacks (c_id) WHERE processed_at IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS object_status_history;
\ No newline at end of file
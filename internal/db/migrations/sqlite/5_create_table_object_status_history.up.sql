@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS object_status_history (
+	h_id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	o_id INTEGER NOT NULL,
+	old_online BOOLEAN NULL, -- NULL when object wasn't stored before
+	new_online BOOLEAN NOT NULL,
+	observed_at INTEGER NOT NULL, -- unix time in microseconds
+	callback_id INTEGER NULL
+);
+
+-- create indexes for fetching timeline of an object and history of all objects in a window
+CREATE INDEX IF NOT EXISTS object_status_history_o_id_idx ON object_status_history (o_id, observed_at);
+CREATE INDEX IF NOT EXISTS object_status_history_observed_at_idx ON object_status_history (observed_at);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	w_id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT NOT NULL, -- json array, empty array means all events
+	created_at INTEGER NOT NULL -- unix time in microseconds
+);
+
+-- deliveries that failed after all retries end up here
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+	d_id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	w_id INTEGER NOT NULL REFERENCES webhook_subscriptions ( w_id ) ON DELETE CASCADE,
+	payload TEXT NOT NULL, -- json
+	attempts INTEGER NOT NULL,
+	last_error TEXT NOT NULL,
+	failed_at INTEGER NOT NULL -- unix time in microseconds
+);
@@ -0,0 +1,10 @@
+-- idempotency keys of callbacks, so callbacks retried by tester service aren't processed twice
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY NOT NULL,
+	fingerprint TEXT NOT NULL, -- hash of callback payload, key can't be reused with another payload
+	callback_id INTEGER NOT NULL, -- not a foreign key, as processed callbacks can be deleted before key expires
+	created_at INTEGER NOT NULL, -- unix time in microseconds
+	expires_at INTEGER NOT NULL -- unix time in microseconds
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys ( expires_at );
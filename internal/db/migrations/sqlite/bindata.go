@@ -0,0 +1,358 @@
+// Code generated by go-bindata. DO NOT EDIT.
+//  memcopy: true
+//  compress: true
+//  decompress: once
+//  asset-dir: true
+//  restore: true
+// sources:
+//  internal/db/migrations/sqlite/1_create_schema_bitburst.down.sql
+//  internal/db/migrations/sqlite/1_create_schema_bitburst.up.sql
+//  internal/db/migrations/sqlite/2_create_table_objects.down.sql
+//  internal/db/migrations/sqlite/2_create_table_objects.up.sql
+//  internal/db/migrations/sqlite/3_create_index_last_seen.down.sql
+//  internal/db/migrations/sqlite/3_create_index_last_seen.up.sql
+//  internal/db/migrations/sqlite/4_create_table_callbacks.down.sql
+//  internal/db/migrations/sqlite/4_create_table_callbacks.up.sql
+//  internal/db/migrations/sqlite/5_create_table_object_status_history.down.sql
+//  internal/db/migrations/sqlite/5_create_table_object_status_history.up.sql
+//  internal/db/migrations/sqlite/6_create_tables_webhooks.down.sql
+//  internal/db/migrations/sqlite/6_create_tables_webhooks.up.sql
+//  internal/db/migrations/sqlite/7_create_table_idempotency_keys.down.sql
+//  internal/db/migrations/sqlite/7_create_table_idempotency_keys.up.sql
+
+package sqlite
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmthrgd/go-bindata/restore"
+)
+
+type asset struct {
+	name string
+	data string
+	size int64
+
+	once  sync.Once
+	bytes []byte
+	err   error
+}
+
+func (a *asset) Name() string {
+	return a.name
+}
+
+func (a *asset) Size() int64 {
+	return a.size
+}
+
+func (a *asset) Mode() os.FileMode {
+	return 0
+}
+
+func (a *asset) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (*asset) IsDir() bool {
+	return false
+}
+
+func (*asset) Sys() interface{} {
+	return nil
+}
+
+// _bindata is a table, holding each asset generator, mapped to its name.
+var _bindata = map[string]*asset{
+	"1_create_schema_bitburst.down.sql": &asset{
+		name: "1_create_schema_bitburst.down.sql",
+		data: "" +
+			"\x0a\x76\xf5\x71\x75\x0e\x51\x30\xb4\x06\x0c\x00",
+		size: 9,
+	},
+	"1_create_schema_bitburst.up.sql": &asset{
+		name: "1_create_schema_bitburst.up.sql",
+		data: "" +
+			"\x04\xc0\x31\x0e\xc3\x20\x10\x04\xc0\x3e\xaf\xd8\x2e\x4d\x28\x52\xa7\x8c\xe8\xdc\xd9\x1f\x58\xcc" +
+			"\x4a\x9c\x04\x58\xd6\x9d\x78\xbf\x27\x25\xf8\xdd\x2d\x84\x7a\xc9\xe7\x3b\xd0\xb8\x04\x3f\x9b\x06" +
+			"\xfd\x83\x60\xe9\x72\x74\x5b\x82\x4d\x44\x13\x06\x6d\xa2\x32\x58\xe8\x7a\xed\x79\xcb\xff\x03\xdf" +
+			"\xdf\x33\x00",
+		size: 74,
+	},
+	"2_create_table_objects.down.sql": &asset{
+		name: "2_create_table_objects.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\xc8\x4f\xca" +
+			"\x4a\x4d\x2e\x29\xb6\x06\x0c\x00",
+		size: 29,
+	},
+	"2_create_table_objects.up.sql": &asset{
+		name: "2_create_table_objects.up.sql",
+		data: "" +
+			"\x3c\xcc\xb1\xaa\x83\x30\x14\x87\xf1\xd9\x3c\xc5\x7f\xbc\x17\xf4\x09\x3a\xc5\xf6\x58\x42\xd3\x58" +
+			"\xe2\x11\xea\x24\xad\x66\x48\xd1\x04\x9a\x14\xfa\xf8\x05\x07\xe7\xef\xc7\x77\xb4\x24\x99\xc0\xb2" +
+			"\xd6\x04\xd5\xc0\xb4\x0c\xba\xab\x8e\x3b\xc4\xe7\xcb\x4d\x39\xe1\x4f\x14\x71\xf4\x33\x94\x61\x3a" +
+			"\x93\xc5\xcd\xaa\xab\xb4\x03\x2e\x34\x6c\xdc\xf4\x5a\x97\xa2\x88\x61\xf1\xc1\xa1\x6e\x5b\x4d\xd2" +
+			"\xec\x05\x27\x6a\x64\xaf\x19\x6c\x7b\x2a\x45\xb1\x3c\x52\x1e\x93\x73\x61\xff\x6d\xaa\xaa\xf0\x09" +
+			"\xfe\x8b\xec\x57\x07\x1f\xb0\xfa\xe9\x1d\x93\x9b\x62\x98\x93\xf8\x3f\x88\xdf\x00",
+		size: 168,
+	},
+	"3_create_index_last_seen.down.sql": &asset{
+		name: "3_create_index_last_seen.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\xc8\x49\x2c" +
+			"\x2e\x89\x2f\x4e\x4d\xcd\x8b\xcf\x4c\xa9\xb0\x06\x0c\x00",
+		size: 35,
+	},
+	"3_create_index_last_seen.up.sql": &asset{
+		name: "3_create_index_last_seen.up.sql",
+		data: "" +
+			"\x44\xcb\xb1\xaa\xc2\x30\x14\x06\xe0\xbd\x4f\xf1\x8f\xf7\x0e\x7d\x02\x27\xd1\x08\x59\x52\xb0\x19" +
+			"\xba\x95\x98\xfc\x85\x4a\x3c\x81\x9c\x23\xf4\xf1\xdd\x74\xfe\xf8\xc6\x11\xb9\x33\x19\x91\x04\xbb" +
+			"\x14\x1e\x68\x82\x9a\xd4\x56\x25\x05\xb9\xd5\xf7\x4b\xb0\xb5\x8e\x2d\xa9\xb1\xa3\xb0\xd2\xa8\xc3" +
+			"\xe5\xee\xce\xd1\xc1\x87\xab\x5b\xe0\x6f\x08\x53\x84\x5b\xfc\x1c\xe7\x5f\x5f\xf7\x72\x60\x0a\x68" +
+			"\x8f\x27\xb3\x29\xfe\xbe\xf2\x7f\x1a\x3e\x03\x00",
+		size: 123,
+	},
+	"4_create_table_callbacks.down.sql": &asset{
+		name: "4_create_table_callbacks.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\x4e\xcc" +
+			"\xc9\x49\x4a\x4c\xce\x2e\xb6\x06\x0c\x00",
+		size: 31,
+	},
+	"4_create_table_callbacks.up.sql": &asset{
+		name: "4_create_table_callbacks.up.sql",
+		data: "" +
+			"\x74\x90\xc1\x6e\xea\x30\x10\x45\xd7\xf1\x57\xdc\xe5\x43\x22\x5f\xc0\x2a\x8f\x4e\xdb\xa8\xc1\x54" +
+			"\xc1\xa8\xb0\x8a\x8c\xe3\x82\x69\xb0\x91\xc7\x94\xf0\xf7\x15\xa8\xa2\x50\xa9\xeb\x99\xab\xa3\x73" +
+			"\xc6\x35\x15\x8a\xa0\x8a\xff\x15\xa1\x7c\x84\x9c\x2a\xd0\xa2\x9c\xa9\x19\x8c\xee\xba\x95\x36\x1f" +
+			"\x8c\x7f\x22\x33\x8d\x6b\x51\x4a\x45\x4f\x54\xe3\xb5\x2e\x27\x45\xbd\xc4\x0b\x2d\x51\xcc\xd5\xb4" +
+			"\x94\xe3\x9a\x26\x24\xd5\x65\x2e\xe7\x55\x35\x14\x59\x58\x6d\xad\x49\x8d\x6b\x19\x8a\x16\x37\x27" +
+			"\xe4\x39\xb6\x1c\x3c\x74\x8c\xfa\x24\xb2\x68\x8d\x75\x9f\xb6\x6d\x74\xba\x12\xee\x9e\x0f\xde\xf5" +
+			"\x48\x6e\x67\xe1\x3c\x76\xce\xc4\xc0\xd6\x04\xdf\xb2\xc8\xf6\x31\x18\xcb\xfc\x6b\x3c\xaf\x2a\x31" +
+			"\x18\x09\x91\xe7\x30\xd1\xea\x64\xa1\xb1\xd7\x31\x39\xdd\xc1\xf9\xd6\xf6\x08\x1e\x07\x7f\x1d\xff" +
+			"\xa8\x0e\xc1\x01\xd1\xee\x3b\x7d\x72\x7e\x8d\xb4\x39\x33\x57\xa1\x07\x27\x7d\x62\xbc\x6b\x4e\x38" +
+			"\x6e\xac\x87\x4b\x58\xc7\x70\x64\xf1\x1d\xb0\x94\x0f\xb4\xf8\x2b\x60\x73\xc3\x6a\x5c\xdb\x63\x2a" +
+			"\x6f\xeb\x9e\xdb\x0e\xf0\xf6\x4c\x35\xe1\x5e\x68\x76\x71\x19\x89\xaf\x01\x00",
+		size: 422,
+	},
+	"5_create_table_object_status_history.down.sql": &asset{
+		name: "5_create_table_object_status_history.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\xc8\x4f\xca" +
+			"\x4a\x4d\x2e\x89\x2f\x2e\x49\x2c\x29\x2d\x8e\xcf\xc8\x2c\x2e\xc9\x2f\xaa\xb4\x06\x0c\x00",
+		size: 43,
+	},
+	"5_create_table_object_status_history.up.sql": &asset{
+		name: "5_create_table_object_status_history.up.sql",
+		data: "" +
+			"\x9c\x91\xc1\x4e\xf3\x30\x10\x84\xcf\xf1\x53\xec\xed\xff\x2b\x35\x4f\xd0\x53\x5a\x0c\x8a\x48\x1d" +
+			"\x94\xba\x52\x7b\xb2\x1c\x7b\x43\x0c\xa9\x2d\xc5\x2e\x29\x6f\x8f\x1c\x0a\xa4\xaa\x10\x88\x9b\xe5" +
+			"\xf5\xce\x37\x9e\x59\x55\x34\xe3\x14\x78\xb6\x2c\x28\xe4\xb7\xc0\x4a\x0e\x74\x97\x6f\xf8\x06\x5c" +
+			"\xfd\x84\x2a\x08\x1f\x64\x38\x7a\xd1\x1a\x1f\x5c\xff\x0a\xff\x49\xd2\x0a\xa3\x21\x67\x9c\xde\xd1" +
+			"\x0a\x1e\xaa\x7c\x9d\x55\x7b\xb8\xa7\x7b\xc8\xb6\xbc\xcc\xd9\xaa\xa2\x6b\xca\xf8\x28\xc5\xb6\x45" +
+			"\x31\x27\x89\x9b\x6e\x4c\xef\x3b\x2d\x9c\xed\x8c\x45\x58\x96\x65\x41\x33\xf6\x3e\x81\x34\x1d\x0f" +
+			"\x30\xb4\x68\xcf\x46\x60\x90\xde\xfe\x0b\x10\x6d\xa0\x86\x1a\x1b\xd7\x23\x49\x2c\x0e\x57\x12\x13" +
+			"\x40\xed\xb1\x7f\x41\x2d\x64\xb8\xe6\x47\xca\xd1\x9a\x13\x04\x73\x40\x30\x16\x0e\x46\xf5\xce\xa3" +
+			"\x72\x56\x7b\x92\x28\xd9\x75\xb5\x54\xcf\x17\xde\xb7\x45\x41\x66\x0b\x42\xd2\x14\x54\x8f\x32\xc4" +
+			"\x3d\x8d\x27\xf4\xd0\xb8\x1e\x1a\x0c\xaa\x35\xf6\x71\x54\x1c\x3d\xb9\x06\xe4\xe7\x0f\xa4\xd5\xf0" +
+			"\x91\x63\x1c\x74\xdd\x79\xe2\x23\x5d\xc2\x60\xac\x76\x03\x39\x77\x92\xb3\x1b\xba\xfb\x4d\x27\x22" +
+			"\xc6\x2b\x8c\x3e\x41\xc9\xbe\x6b\x2d\x3e\x99\xc3\x24\x8e\xd9\xe2\x0f\x9c\xaf\xf5\x9f\x70\x17\xa0" +
+			"\xb7\x01\x00",
+		size: 613,
+	},
+	"6_create_tables_webhooks.down.sql": &asset{
+		name: "6_create_tables_webhooks.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x28\x4f\x4d" +
+			"\xca\xc8\xcf\xcf\x8e\x4f\x49\x4d\x4c\x89\xcf\x49\x2d\x29\x49\x2d\x2a\xb6\xe6\xc2\xab\xb4\xb8\x34" +
+			"\xa9\x38\xb9\x28\xb3\xa0\x24\x33\x3f\xaf\xd8\x1a\x30\x00",
+		size: 86,
+	},
+	"6_create_tables_webhooks.up.sql": &asset{
+		name: "6_create_tables_webhooks.up.sql",
+		data: "" +
+			"\x9c\x91\xc1\x6a\xdc\x30\x10\x86\xcf\xd6\x53\xfc\xc7\x06\xec\x27\xe8\xc9\x75\x26\xc5\xd4\xf1\x16" +
+			"\xaf\x02\xc9\xc9\x28\xd6\x84\x55\x2b\x4b\x46\x1a\x27\xdd\xb7\x2f\xf1\x92\x42\xba\x2c\xb4\x39\xea" +
+			"\x13\xf3\xcf\xf0\xfd\xcd\x40\xb5\x26\xe8\xfa\x4b\x47\x68\x6f\xd0\xef\x34\xe8\xbe\xdd\xeb\x3d\x5e" +
+			"\xf8\xf1\x10\xe3\xcf\x31\xaf\x8f\x79\x4a\x6e\x11\x17\x43\xc6\x27\x55\xbc\x8c\xce\xa2\xed\x35\x7d" +
+			"\xa5\x01\xdf\x87\xf6\xb6\x1e\x1e\xf0\x8d\x1e\x50\xdf\xe9\x5d\xdb\x37\x03\xdd\x52\xaf\xb7\xa8\xfe" +
+			"\xae\xeb\x4a\x55\xac\xc9\x43\xd3\xfd\x3b\x96\x79\x4a\x2c\x67\x98\x9f\x39\x48\xfe\x0b\xa3\xaa\xf0" +
+			"\x23\xc7\x00\x93\x92\x39\x96\xe0\x79\x91\xe3\xe9\x81\x99\x4d\xc8\x30\xde\xe3\x34\xaa\x8a\x29\xb1" +
+			"\x11\xb6\xa3\x91\x3f\x57\xbe\x25\xbd\x06\xad\xc1\xfd\x82\xb8\x99\xe1\x02\x66\x37\xa5\x98\x79\x8a" +
+			"\xc1\x66\x75\xf5\x59\xa9\xaa\x82\x65\xef\x9e\x39\x39\xce\x90\x83\x11\x3c\x19\xe7\xd9\xc2\x3c\x09" +
+			"\xa7\x6d\x51\x62\xd9\x7e\x39\x58\xac\x0b\x0e\x9c\x58\xfd\x83\x48\xcb\xc6\x8e\x9e\x45\x38\x6d\x1e" +
+			"\xed\x7f\x7b\x7c\x67\xfe\x8d\x63\xa0\x1b\x1a\xa8\x6f\xe8\x62\x67\xd8\x06\xaf\xb0\xeb\x71\x4d\x1d" +
+			"\x69\x42\x53\xef\x9b\xfa\x9a\x4a\x55\x2c\xe6\xe8\xa3\xb1\x17\x8c\xab\xc2\x88\xbc\xea\xce\x67\x7b" +
+			"\x4b\x55\x78\x93\x65\xe4\x94\x62\x3a\xeb\xf1\x24\xed\x43\x1d\xfc\x1e\x00",
+		size: 660,
+	},
+	"7_create_table_idempotency_keys.down.sql": &asset{
+		name: "7_create_table_idempotency_keys.down.sql",
+		data: "" +
+			"\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\xc8\x4c\x49" +
+			"\xcd\x2d\xc8\x2f\x49\xcd\x4b\xae\x8c\xcf\x4e\xad\x2c\xb6\x06\x0c\x00",
+		size: 38,
+	},
+	"7_create_table_idempotency_keys.up.sql": &asset{
+		name: "7_create_table_idempotency_keys.up.sql",
+		data: "" +
+			"\x84\x91\x41\x6f\xe2\x30\x10\x85\xcf\xf1\xaf\x78\xb7\x5d\xa4\xe4\x17\x70\x62\x77\xbd\x55\x54\x1a" +
+			"\xaa\x90\x4a\x70\x8a\x8c\x3d\x90\x11\xc1\x8e\x6c\x53\xc8\xbf\xaf\x42\x4b\x43\x25\xd4\x1e\x2d\xbf" +
+			"\x37\xdf\x9b\x79\x59\x06\x36\x74\xe8\x5c\x24\xab\x7b\xec\xa9\x0f\x70\x5b\x68\xd5\xb6\x1b\xa5\xf7" +
+			"\x21\x45\x70\xe3\x0b\x9e\xa2\x67\x32\xd8\xf4\x88\x14\x22\x79\x04\xf2\xaf\xac\x09\xca\x93\xfd\x15" +
+			"\xd1\x79\xa7\x29\x04\x32\x88\x27\xd6\x24\xfe\x96\x72\x56\x49\x54\xb3\x3f\x73\x89\xfc\x3f\x8a\x45" +
+			"\x05\xb9\xca\x97\xd5\xf2\x96\x5b\x5f\xb8\xbf\x45\xb2\xa7\x1e\x95\x5c\x55\x78\x2e\xf3\xa7\x59\xb9" +
+			"\xc6\xa3\x5c\x5f\x3c\xc5\xcb\x7c\x9e\x8a\x64\xcb\x76\x47\xbe\xf3\x6c\xe3\xbb\xee\xf3\x0f\x59\x86" +
+			"\x46\x85\xe6\x36\x3d\x3a\xd5\xb7\x4e\x99\x74\xd8\x0b\x5a\x0d\x01\x37\x04\x4f\xc7\x21\xe0\x89\x63" +
+			"\x03\x65\x5d\x6c\xc8\x5f\x95\x22\xb9\x7a\x6b\x36\xc8\x8b\x4a\x3e\xc8\xf2\x2b\xc4\xba\x08\x85\xad" +
+			"\xf3\xc4\x3b\x3b\x0c\x4e\xa1\xc2\xcd\xde\xe3\xb1\xb4\xb2\x03\xce\x50\x4b\x71\xb8\x19\x0d\xa6\x4b" +
+			"\x14\x3a\x77\xec\x29\x88\x44\x7b\x52\x91\x4c\xad\xe2\x7d\xd8\xd1\xf2\x19\x91\x0f\x04\xb6\x38\xb0" +
+			"\xf6\x2e\x90\x76\xd6\x04\x91\x7c\xcc\xb8\x67\xfd\xde\x39\x99\x8a\x6b\x2d\x79\xf1\x4f\xae\x7e\xa8" +
+			"\xa5\x1e\x41\x35\x9b\x33\x16\xc5\x9d\xe6\x30\x8a\x30\x99\x8a\xb7\x01\x00",
+		size: 598,
+	},
+}
+
+// AssetAndInfo loads and returns the asset and asset info for the
+// given name. It returns an error if the asset could not be found
+// or could not be loaded.
+func AssetAndInfo(name string) ([]byte, os.FileInfo, error) {
+	a, ok := _bindata[filepath.ToSlash(name)]
+	if !ok {
+		return nil, nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	a.once.Do(func() {
+		fr := flate.NewReader(strings.NewReader(a.data))
+
+		var buf bytes.Buffer
+		if _, a.err = io.Copy(&buf, fr); a.err != nil {
+			return
+		}
+
+		if a.err = fr.Close(); a.err == nil {
+			a.bytes = buf.Bytes()
+		}
+	})
+	if a.err != nil {
+		return nil, nil, &os.PathError{Op: "read", Path: name, Err: a.err}
+	}
+
+	return a.bytes, a, nil
+}
+
+// AssetInfo loads and returns the asset info for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func AssetInfo(name string) (os.FileInfo, error) {
+	a, ok := _bindata[filepath.ToSlash(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	return a, nil
+}
+
+// Asset loads and returns the asset for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func Asset(name string) ([]byte, error) {
+	data, _, err := AssetAndInfo(name)
+	return data, err
+}
+
+// MustAsset is like Asset but panics when Asset would return an error.
+// It simplifies safe initialization of global variables.
+func MustAsset(name string) []byte {
+	a, err := Asset(name)
+	if err != nil {
+		panic("asset: Asset(" + name + "): " + err.Error())
+	}
+
+	return a
+}
+
+// AssetNames returns the names of the assets.
+func AssetNames() []string {
+	names := make([]string, 0, len(_bindata))
+	for name := range _bindata {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// RestoreAsset restores an asset under the given directory
+func RestoreAsset(dir, name string) error {
+	return restore.Asset(dir, name, AssetAndInfo)
+}
+
+// RestoreAssets restores an asset under the given directory recursively
+func RestoreAssets(dir, name string) error {
+	return restore.Assets(dir, name, AssetDir, AssetAndInfo)
+}
+
+// AssetDir returns the file names below a certain
+// directory embedded in the file by go-bindata.
+// For example if you run go-bindata on data/... and data contains the
+// following hierarchy:
+//     data/
+//       foo.txt
+//       img/
+//         a.png
+//         b.png
+// then AssetDir("data") would return []string{"foo.txt", "img"}
+// AssetDir("data/img") would return []string{"a.png", "b.png"}
+// AssetDir("foo.txt") and AssetDir("notexist") would return an error
+// AssetDir("") will return []string{"data"}.
+func AssetDir(name string) ([]string, error) {
+	node := _bintree
+
+	if name != "" {
+		var ok bool
+		for _, p := range strings.Split(filepath.ToSlash(name), "/") {
+			if node, ok = node[p]; !ok {
+				return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+			}
+		}
+	}
+
+	if len(node) == 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	rv := make([]string, 0, len(node))
+	for name := range node {
+		rv = append(rv, name)
+	}
+
+	return rv, nil
+}
+
+type bintree map[string]bintree
+
+var _bintree = bintree{
+	"1_create_schema_bitburst.down.sql":             bintree{},
+	"1_create_schema_bitburst.up.sql":               bintree{},
+	"2_create_table_objects.down.sql":               bintree{},
+	"2_create_table_objects.up.sql":                 bintree{},
+	"3_create_index_last_seen.down.sql":             bintree{},
+	"3_create_index_last_seen.up.sql":               bintree{},
+	"4_create_table_callbacks.down.sql":             bintree{},
+	"4_create_table_callbacks.up.sql":               bintree{},
+	"5_create_table_object_status_history.down.sql": bintree{},
+	"5_create_table_object_status_history.up.sql":   bintree{},
+	"6_create_tables_webhooks.down.sql":             bintree{},
+	"6_create_tables_webhooks.up.sql":               bintree{},
+	"7_create_table_idempotency_keys.down.sql":      bintree{},
+	"7_create_table_idempotency_keys.up.sql":        bintree{},
+}
@@ -1,13 +1,31 @@
 package db
 
 import (
+	"bitburst-assessment-task/internal/db/objects"
+	"bitburst-assessment-task/internal/metrics"
 	"context"
 	"database/sql"
+	"math"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+// ErrObjectNotFound is returned when requested object doesn't exist in database
+var ErrObjectNotFound = errors.New("object not found")
+
+// ListObjectsFilter holds filters and pagination options for listing objects,
+// nil fields are not applied
+type ListObjectsFilter struct {
+	Online     *bool
+	SeenAfter  *time.Time // inclusive
+	SeenBefore *time.Time // exclusive
+
+	// After is a pagination cursor, only objects with id greater than After are returned
+	After *int32
+	Limit int32
+}
+
 // startTx starts postgres transaction, don't forget to close connection and transaction when the work is done
 func (db *DB) startTx(ctx context.Context) (*sql.Conn, *sql.Tx, error) {
 	// check if database is alive before starting transaction
@@ -33,62 +51,95 @@ func (db *DB) startTx(ctx context.Context) (*sql.Conn, *sql.Tx, error) {
 	return conn, tx, nil
 }
 
-// DeleteNotSeenObjects deletes objects that have last_seen < 30 seconds,
+// DeleteNotSeenObjects deletes objects that weren't seen during retention window every sweep interval,
 // it's to run in background. When a job is done on behalf of this function, context should be canceled.
 func (db *DB) DeleteNotSeenObjects(ctx context.Context) {
-	tick := time.NewTicker(30 * time.Second)
-
-	subLogger := db.logger.With().Str("func", "DeleteNotSeenObjects").Logger()
-	for {
-		select {
-		case <-ctx.Done():
-			tick.Stop()
-			return
-		case <-tick.C:
-			newCtx, cancel := context.WithTimeout(ctx, 5 * time.Second)
-			defer cancel()
-
-			conn, tx, err := db.startTx(newCtx)
-			if err != nil {
-				subLogger.Warn().Msgf("%v", err)
-				continue
-			}
-			defer func() { // release connection to pool
-				if err := conn.Close(); err != nil {
-					subLogger.Warn().Err(err).Msg("failed to release connection to pool")
-				}
-			}()
-			defer func() { // rollback tx on error
-				if err != nil {
-					if terr := tx.Rollback(); terr != nil {
-						subLogger.Warn().Err(terr).Msg("failed to rollback transaction")
-					}
-				}
-			}()
-			txQ := db.q.WithTx(tx) // attach queries in tx
-
-			deletedIDs, err := txQ.DeleteNotSeenObjects(newCtx)
-			if err != nil {
-				subLogger.Warn().Err(err).Msg("failed to delete not seen objects")
-				continue
-			}
+	runSweeper(ctx, db.conf.SweepInterval, nil, db.SweepNotSeenObjects, db.logger)
+}
+
+// SweepNotSeenObjects runs one sweep of objects that weren't seen during retention window,
+// and returns ids of deleted objects
+func (db *DB) SweepNotSeenObjects(ctx context.Context) (deletedIDs []int32, err error) {
+	subLogger := db.logger.With().Str("func", "SweepNotSeenObjects").Logger()
+
+	start := time.Now()
+	defer func() {
+		metrics.DBQueryDuration.WithLabelValues("delete_not_seen_objects").Observe(time.Since(start).Seconds())
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-			// commit transaction
-			if err := tx.Commit(); err != nil {
-				subLogger.Warn().Err(err).Msg("failed to commit transaction")
-				continue
+	conn, tx, err := db.startTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { // release connection to pool
+		if err := conn.Close(); err != nil {
+			subLogger.Warn().Err(err).Msg("failed to release connection to pool")
+		}
+	}()
+	defer func() { // rollback tx on error
+		if err != nil {
+			if terr := tx.Rollback(); terr != nil {
+				subLogger.Warn().Err(terr).Msg("failed to rollback transaction")
 			}
+		}
+	}()
+	txQ := db.q.WithTx(tx) // attach queries in tx
+
+	rows, err := txQ.DeleteNotSeenObjects(ctx, db.conf.Retention.Milliseconds())
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to delete not seen objects")
+	}
+
+	deleted := make(map[int32]bool, len(rows))
+	for _, row := range rows {
+		deletedIDs = append(deletedIDs, row.OID)
+		deleted[row.OID] = row.Online
+	}
+
+	// record that deleted objects went away, so they don't count as online in uptime anymore
+	if err = recordTransitions(ctx, txQ, 0, expiredTransitions(deleted)); err != nil {
+		return nil, err
+	}
 
-			db.logger.Info().Ints32("ids", deletedIDs).Msg("successfully deleted objects from database")
+	if db.conf.HistoryRetention > 0 {
+		var pruned int64
+		pruned, err = txQ.DeleteOldStatusHistory(ctx, time.Now().Add(-db.conf.HistoryRetention))
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to prune status history")
 		}
+		subLogger.Debug().Int64("count", pruned).Msg("pruned status history")
+	}
+
+	// commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, errors.WithMessage(err, "failed to commit transaction")
 	}
+
+	metrics.SweepDeletedObjects.Observe(float64(len(deletedIDs)))
+	metrics.DBObjects.WithLabelValues("deleted").Add(float64(len(deletedIDs)))
+
+	db.emit(expiredEvents(deletedIDs, time.Now()))
+
+	return deletedIDs, nil
 }
 
 // InsertObjectsOrUpdate inserts objects in database if they don't exist,
-// else it updates it's online status and last_seen date
-func (db *DB) InsertObjectsOrUpdate(ctx context.Context, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error) {
+// else it updates it's online status and last_seen date, both online and offline objects are stored.
+// In legacy upsert mode only online objects are inserted or updated, offline objects are only marked as offline
+// if they already exist and their last_seen isn't refreshed, all online ids are reported as inserted.
+// Status changes are appended to objects status history in the same transaction,
+// callbackID is an id of inbox entry the statuses came from, 0 if they didn't come from a callback.
+func (db *DB) InsertObjectsOrUpdate(ctx context.Context, callbackID int64, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error) {
 	subLogger := db.logger.With().Str("func", "InsertObjectsOrUpdate").Logger()
 
+	start := time.Now()
+	defer func() {
+		metrics.DBQueryDuration.WithLabelValues("insert_objects_or_update").Observe(time.Since(start).Seconds())
+	}()
+
 	conn, tx, err := db.startTx(ctx)
 	if err != nil {
 		return nil, nil, err
@@ -107,14 +158,27 @@ func (db *DB) InsertObjectsOrUpdate(ctx context.Context, onlineIDs []int32, offl
 	}()
 	txQ := db.q.WithTx(tx) // attach queries in tx
 
-	insertedIDs, err = txQ.InsertObjectsOrUpdate(ctx, onlineIDs)
+	// lock objects and remember their statuses, so status changes can be recorded
+	allIDs := make([]int32, 0, len(onlineIDs)+len(offlineIDs))
+	allIDs = append(append(allIDs, onlineIDs...), offlineIDs...)
+	before, err := lockObjectsStatus(ctx, txQ, allIDs)
 	if err != nil {
-		return nil, nil, errors.WithMessage(err, "failed to insert/update objects")
+		return nil, nil, err
 	}
 
-	updatedIDs, err = txQ.UpdateObjects(ctx, offlineIDs)
+	if db.conf.LegacyUpsert {
+		insertedIDs, updatedIDs, err = legacyUpsertObjects(ctx, txQ, onlineIDs, offlineIDs)
+	} else {
+		insertedIDs, updatedIDs, err = upsertObjects(ctx, txQ, onlineIDs, offlineIDs)
+	}
 	if err != nil {
-		return nil, nil, errors.WithMessage(err, "failed to update objects")
+		return nil, nil, err
+	}
+
+	ts := transitions(before, onlineIDs, offlineIDs, db.conf.LegacyUpsert)
+	err = recordTransitions(ctx, txQ, callbackID, ts)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// commit transaction
@@ -122,5 +186,97 @@ func (db *DB) InsertObjectsOrUpdate(ctx context.Context, onlineIDs []int32, offl
 		return nil, nil, errors.WithMessage(err, "failed to commit transaction")
 	}
 
+	metrics.DBObjects.WithLabelValues("inserted").Add(float64(len(insertedIDs)))
+	metrics.DBObjects.WithLabelValues("updated").Add(float64(len(updatedIDs)))
+
+	db.emit(transitionEvents(ts, time.Now()))
+
+	return insertedIDs, updatedIDs, nil
+}
+
+// upsertObjects inserts or updates all objects with their current online status and refreshes their last_seen
+func upsertObjects(ctx context.Context, q *objects.Queries, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error) {
+	ids := make([]int32, 0, len(onlineIDs)+len(offlineIDs))
+	online := make([]bool, 0, len(onlineIDs)+len(offlineIDs))
+	for _, id := range onlineIDs {
+		ids = append(ids, id)
+		online = append(online, true)
+	}
+	for _, id := range offlineIDs {
+		ids = append(ids, id)
+		online = append(online, false)
+	}
+
+	rows, err := q.UpsertObjects(ctx, objects.UpsertObjectsParams{Ids: ids, Online: online})
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to upsert objects")
+	}
+
+	for _, row := range rows {
+		if row.Inserted {
+			insertedIDs = append(insertedIDs, row.OID)
+		} else {
+			updatedIDs = append(updatedIDs, row.OID)
+		}
+	}
+
 	return insertedIDs, updatedIDs, nil
-}
\ No newline at end of file
+}
+
+// legacyUpsertObjects inserts or updates online objects, and marks existing offline objects as offline
+func legacyUpsertObjects(ctx context.Context, q *objects.Queries, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error) {
+	insertedIDs, err = q.InsertObjectsOrUpdate(ctx, onlineIDs)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to insert/update objects")
+	}
+
+	updatedIDs, err = q.UpdateObjects(ctx, offlineIDs)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to update objects")
+	}
+
+	return insertedIDs, updatedIDs, nil
+}
+
+// GetObject returns object by it's id, or ErrObjectNotFound if it doesn't exist
+func (db *DB) GetObject(ctx context.Context, id int32) (*objects.BitburstObject, error) {
+	obj, err := db.q.GetObject(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, errors.WithMessagef(err, "failed to get object %d", id)
+	}
+
+	return &obj, nil
+}
+
+// ListObjects returns objects that match the filter ordered by id
+func (db *DB) ListObjects(ctx context.Context, filter *ListObjectsFilter) ([]objects.BitburstObject, error) {
+	params := objects.ListObjectsParams{
+		AfterID: math.MinInt32,
+		MaxRows: filter.Limit,
+	}
+	if filter.After != nil {
+		params.AfterID = *filter.After
+	}
+	if filter.Online != nil {
+		params.FilterOnline = true
+		params.Online = *filter.Online
+	}
+	if filter.SeenAfter != nil {
+		params.FilterSeenAfter = true
+		params.SeenAfter = *filter.SeenAfter
+	}
+	if filter.SeenBefore != nil {
+		params.FilterSeenBefore = true
+		params.SeenBefore = *filter.SeenBefore
+	}
+
+	objs, err := db.q.ListObjects(ctx, params)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list objects")
+	}
+
+	return objs, nil
+}
@@ -101,8 +101,10 @@ func startDatabase(tb testing.TB, zlog *zerolog.Logger) *Config {
 		Username:         connURL.User.Username(),
 		Password:         psw,
 		Name:             connURL.Path,
-		MigrationVersion: 3,
+		MigrationVersion: 7,
 		SSLmode:          "disable",
+		Retention:        30 * time.Second,
+		SweepInterval:    30 * time.Second,
 	}
 
 	return dbConf
@@ -136,7 +138,63 @@ func TestInsertObjectsOrUpdate(t *testing.T) {
 			}
 		}
 
-		insertedIDs, updatedIDs, err := database.InsertObjectsOrUpdate(ctx, onlineIDs, offlineIDs)
+		insertedIDs, updatedIDs, err := database.InsertObjectsOrUpdate(ctx, 0, onlineIDs, offlineIDs)
+		require.Nil(t, err, "failed to process objects")
+
+		assert.Equal(t, len(onlineIDs)+len(offlineIDs), len(insertedIDs), "length of inserted ids isn't equal to len of all ids")
+		assert.Equal(t, len(updatedIDs), 0, "length of updated ids isn't equal to 0")
+
+		// send same objects with flipped statuses, all of them should be updated
+		insertedIDs, updatedIDs, err = database.InsertObjectsOrUpdate(ctx, 0, offlineIDs, onlineIDs)
+		require.Nil(t, err, "failed to process objects")
+
+		assert.Equal(t, len(insertedIDs), 0, "length of inserted ids isn't equal to 0")
+		assert.Equal(t, len(onlineIDs)+len(offlineIDs), len(updatedIDs), "length of updated ids isn't equal to len of all ids")
+
+		// query database and get all objects
+		rows, err := database.sqlDB.QueryContext(ctx, `SELECT o_id, online, last_seen FROM bitburst."objects" WHERE o_id < 100;`)
+		require.Nil(t, err, "failed to select o_id, online, last_seen")
+		defer func() {
+			assert.Nil(t, rows.Close(), "failed to close result rows")
+		}()
+
+		type oidonline struct {
+			OID      int32     `db:"o_id"`
+			Online   bool      `db:"online"`
+			LastSeen time.Time `db:"last_seen"`
+		}
+		rs := make([]*oidonline, 0, 100)
+		for rows.Next() {
+			r := &oidonline{}
+			err := rows.Scan(&r.OID, &r.Online, &r.LastSeen)
+			require.Nil(t, err, "failed to scan result rows")
+			rs = append(rs, r)
+		}
+		require.Nil(t, rows.Err(), "failed to read result rows")
+		require.Len(t, rs, 100, "both online and offline objects should be stored")
+
+		// check if all ids were saved with their last status and fresh last_seen
+		for _, r := range rs {
+			assert.Equalf(t, r.OID%2 != 0, r.Online, "%d id has wrong online status", r.OID)
+			assert.WithinDurationf(t, time.Now(), r.LastSeen, 10*time.Second, "%d id has stale last_seen", r.OID)
+		}
+	})
+
+	t.Run("legacy upsert", func(t *testing.T) {
+		database.conf.LegacyUpsert = true
+		defer func() { database.conf.LegacyUpsert = false }()
+
+		onlineIDs := make([]int32, 0, 100)
+		offlineIDs := make([]int32, 0, 100)
+		for i := 100; i < 200; i++ {
+			if i%2 == 0 {
+				onlineIDs = append(onlineIDs, int32(i))
+			} else {
+				offlineIDs = append(offlineIDs, int32(i))
+			}
+		}
+
+		insertedIDs, updatedIDs, err := database.InsertObjectsOrUpdate(ctx, 0, onlineIDs, offlineIDs)
 		require.Nil(t, err, "failed to process objects")
 
 		assert.True(t, len(insertedIDs) == len(onlineIDs), "length of inserted ids isn't equal to len of online ids")
@@ -152,39 +210,23 @@ func TestInsertObjectsOrUpdate(t *testing.T) {
 			assert.Truef(t, ok, "%d id exists in inserted id slice and not in online id slice", id)
 		}
 
-		offlineIDsMap := make(map[int32]struct{}, 100)
-		for _, id := range offlineIDs {
-			offlineIDsMap[id] = struct{}{}
-		}
-		for _, id := range updatedIDs {
-			_, ok := offlineIDsMap[id]
-			assert.Truef(t, ok, "%d id exists in updated id slice and not in offline id slice", id)
-		}
-
 		// query database and get all objects
-		rows, err := database.sqlDB.QueryContext(ctx, `SELECT o_id, online FROM bitburst."objects";`)
+		rows, err := database.sqlDB.QueryContext(ctx, `SELECT o_id, online FROM bitburst."objects" WHERE o_id >= 100;`)
 		require.Nil(t, err, "failed to select o_id, online")
 		defer func() {
 			assert.Nil(t, rows.Close(), "failed to close result rows")
 		}()
 
-		type oidonline struct {
-			OID    int32 `db:"o_id"`
-			Online bool  `db:"online"`
-		}
-		rs := make([]*oidonline, 0, 100)
 		for rows.Next() {
-			r := &oidonline{}
-			err := rows.Scan(&r.OID, &r.Online)
-			require.Nil(t, err, "failed to scan result rows")
-			rs = append(rs, r)
+			var (
+				oid    int32
+				online bool
+			)
+			require.Nil(t, rows.Scan(&oid, &online), "failed to scan result rows")
+			// check if only online ids were saved in database
+			assert.Truef(t, online, "%d id has online false, when it should be true", oid)
 		}
 		require.Nil(t, rows.Err(), "failed to read result rows")
-
-		// check if all online ids were saved correct in database
-		for _, r := range rs {
-			assert.True(t, r.Online == true, "%d id has online false, when it should be true")
-		}
 	})
 }
 
@@ -216,31 +258,12 @@ func TestDeleteNotSeenObjects(t *testing.T) {
 			}
 		}
 
-		insertedIDs, updatedIDs, err := database.InsertObjectsOrUpdate(ctx, onlineIDs, offlineIDs)
+		insertedIDs, updatedIDs, err := database.InsertObjectsOrUpdate(ctx, 0, onlineIDs, offlineIDs)
 		require.Nil(t, err, "failed to process objects")
 
-		assert.True(t, len(insertedIDs) == len(onlineIDs), "length of inserted ids isn't equal to len of online ids")
+		assert.Equal(t, len(onlineIDs)+len(offlineIDs), len(insertedIDs), "length of inserted ids isn't equal to len of all ids")
 		assert.Equal(t, len(updatedIDs), 0, "length of updated ids isn't equal to 0")
 
-		// compare if two initial ids are equal to modified ids
-		onlineIDsMap := make(map[int32]struct{}, 100)
-		for _, id := range onlineIDs {
-			onlineIDsMap[id] = struct{}{}
-		}
-		for _, id := range insertedIDs {
-			_, ok := onlineIDsMap[id]
-			assert.Truef(t, ok, "%d id exists in inserted id slice and not in online id slice", id)
-		}
-
-		offlineIDsMap := make(map[int32]struct{}, 100)
-		for _, id := range offlineIDs {
-			offlineIDsMap[id] = struct{}{}
-		}
-		for _, id := range updatedIDs {
-			_, ok := offlineIDsMap[id]
-			assert.Truef(t, ok, "%d id exists in updated id slice and not in offline id slice", id)
-		}
-
 		// start object deleter and wait 31 seconds to check if objects got deleted or no
 		newCtx, cancel := context.WithCancel(ctx)
 		defer cancel()
@@ -262,6 +285,68 @@ func TestDeleteNotSeenObjects(t *testing.T) {
 	})
 }
 
+func TestGetObjectAndListObjects(t *testing.T) {
+	t.Parallel()
+
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.DebugLevel)
+
+	database, err := New(startDatabase(t, &zlog), &zlog)
+	require.Nil(t, err, "failed to establish connection")
+	t.Cleanup(func() {
+		assert.Nil(t, database.Close(), "failed to close connection")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	onlineIDs := []int32{0, 2, 4, 6, 8}
+	offlineIDs := []int32{1, 3, 5, 7, 9}
+	_, _, err = database.InsertObjectsOrUpdate(ctx, 0, onlineIDs, offlineIDs)
+	require.Nil(t, err, "failed to process objects")
+
+	t.Run("get existing object", func(t *testing.T) {
+		obj, err := database.GetObject(ctx, 2)
+		require.Nil(t, err, "failed to get object")
+		assert.Equal(t, int32(2), obj.OID, "got object with wrong id")
+		assert.True(t, obj.Online, "object should be online")
+		assert.True(t, obj.LastSeen.Valid, "object should have last_seen")
+	})
+
+	t.Run("get unknown object", func(t *testing.T) {
+		_, err := database.GetObject(ctx, 1000)
+		require.Equal(t, ErrObjectNotFound, err, "expected not found error")
+	})
+
+	t.Run("list with cursor", func(t *testing.T) {
+		after := int32(2)
+		objs, err := database.ListObjects(ctx, &ListObjectsFilter{After: &after, Limit: 2})
+		require.Nil(t, err, "failed to list objects")
+		require.Len(t, objs, 2, "got wrong page size")
+		assert.Equal(t, int32(3), objs[0].OID, "got wrong first object of a page")
+		assert.Equal(t, int32(4), objs[1].OID, "got wrong last object of a page")
+	})
+
+	t.Run("list online", func(t *testing.T) {
+		online := true
+		objs, err := database.ListObjects(ctx, &ListObjectsFilter{Online: &online, Limit: 100})
+		require.Nil(t, err, "failed to list objects")
+		require.Len(t, objs, len(onlineIDs), "got wrong amount of online objects")
+		for _, obj := range objs {
+			assert.Truef(t, obj.Online, "%d id has online false, when it should be true", obj.OID)
+		}
+	})
+
+	t.Run("list seen in the future", func(t *testing.T) {
+		seenAfter := time.Now().Add(time.Hour)
+		objs, err := database.ListObjects(ctx, &ListObjectsFilter{SeenAfter: &seenAfter, Limit: 100})
+		require.Nil(t, err, "failed to list objects")
+		assert.Empty(t, objs, "no objects should be seen in the future")
+	})
+}
+
 func BenchmarkInsertObjectsOrUpdate(b *testing.B) {
 	zlog := log.Output(zerolog.ConsoleWriter{
 		Out:        io.Discard,
@@ -307,7 +392,7 @@ func BenchmarkInsertObjectsOrUpdate(b *testing.B) {
 			}
 		}
 
-		_, _, err := database.InsertObjectsOrUpdate(ctx, onlineIDs, offlineIDs)
+		_, _, err := database.InsertObjectsOrUpdate(ctx, 0, onlineIDs, offlineIDs)
 		require.Nil(b, err, "failed to insert or update objects")
 	})
 }
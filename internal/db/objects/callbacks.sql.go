@@ -0,0 +1,98 @@
+// Code generated by sqlc. DO NOT EDIT.
+// source: callbacks.sql
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const deleteProcessedCallbacks = `-- name: DeleteProcessedCallbacks :execrows
+DELETE
+FROM
+	bitburst."callbacks"
+WHERE
+	processed_at < CURRENT_TIMESTAMP - $1::BIGINT * INTERVAL '1 millisecond'
+`
+
+func (q *Queries) DeleteProcessedCallbacks(ctx context.Context, retentionMs int64) (int64, error) {
+	result, err := q.exec(ctx, q.deleteProcessedCallbacksStmt, deleteProcessedCallbacks, retentionMs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getUnprocessedCallbacks = `-- name: GetUnprocessedCallbacks :many
+SELECT
+	c_id, object_ids, received_at
+FROM
+	bitburst."callbacks"
+WHERE
+	processed_at IS NULL
+	AND received_at <= CURRENT_TIMESTAMP - $1::BIGINT * INTERVAL '1 millisecond'
+ORDER BY c_id
+LIMIT $2::INT
+`
+
+type GetUnprocessedCallbacksParams struct {
+	MinAgeMs int64 `json:"min_age_ms"`
+	MaxRows  int32 `json:"max_rows"`
+}
+
+type GetUnprocessedCallbacksRow struct {
+	CID        int64     `json:"c_id"`
+	ObjectIds  []int32   `json:"object_ids"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+func (q *Queries) GetUnprocessedCallbacks(ctx context.Context, arg GetUnprocessedCallbacksParams) ([]GetUnprocessedCallbacksRow, error) {
+	rows, err := q.query(ctx, q.getUnprocessedCallbacksStmt, getUnprocessedCallbacks, arg.MinAgeMs, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnprocessedCallbacksRow
+	for rows.Next() {
+		var i GetUnprocessedCallbacksRow
+		if err := rows.Scan(&i.CID, pq.Array(&i.ObjectIds), &i.ReceivedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertCallback = `-- name: InsertCallback :one
+INSERT INTO bitburst."callbacks" ( object_ids )
+VALUES ( $1::INT[] )
+RETURNING c_id
+`
+
+func (q *Queries) InsertCallback(ctx context.Context, dollar_1 []int32) (int64, error) {
+	row := q.queryRow(ctx, q.insertCallbackStmt, insertCallback, pq.Array(dollar_1))
+	var c_id int64
+	err := row.Scan(&c_id)
+	return c_id, err
+}
+
+const markCallbackProcessed = `-- name: MarkCallbackProcessed :exec
+UPDATE bitburst."callbacks"
+	SET processed_at = CURRENT_TIMESTAMP
+WHERE
+	c_id = $1
+`
+
+func (q *Queries) MarkCallbackProcessed(ctx context.Context, cID int64) error {
+	_, err := q.exec(ctx, q.markCallbackProcessedStmt, markCallbackProcessed, cID)
+	return err
+}
@@ -22,35 +22,211 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.deleteExpiredIdempotencyKeyStmt, err = db.PrepareContext(ctx, deleteExpiredIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteExpiredIdempotencyKey: %w", err)
+	}
+	if q.deleteExpiredIdempotencyKeysStmt, err = db.PrepareContext(ctx, deleteExpiredIdempotencyKeys); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteExpiredIdempotencyKeys: %w", err)
+	}
 	if q.deleteNotSeenObjectsStmt, err = db.PrepareContext(ctx, deleteNotSeenObjects); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteNotSeenObjects: %w", err)
 	}
+	if q.deleteOldStatusHistoryStmt, err = db.PrepareContext(ctx, deleteOldStatusHistory); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteOldStatusHistory: %w", err)
+	}
+	if q.deleteProcessedCallbacksStmt, err = db.PrepareContext(ctx, deleteProcessedCallbacks); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteProcessedCallbacks: %w", err)
+	}
+	if q.deleteWebhookSubscriptionStmt, err = db.PrepareContext(ctx, deleteWebhookSubscription); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteWebhookSubscription: %w", err)
+	}
+	if q.getIdempotencyKeyStmt, err = db.PrepareContext(ctx, getIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query GetIdempotencyKey: %w", err)
+	}
+	if q.getObjectStmt, err = db.PrepareContext(ctx, getObject); err != nil {
+		return nil, fmt.Errorf("error preparing query GetObject: %w", err)
+	}
+	if q.getObjectTimelineStmt, err = db.PrepareContext(ctx, getObjectTimeline); err != nil {
+		return nil, fmt.Errorf("error preparing query GetObjectTimeline: %w", err)
+	}
+	if q.getObjectsStatusForUpdateStmt, err = db.PrepareContext(ctx, getObjectsStatusForUpdate); err != nil {
+		return nil, fmt.Errorf("error preparing query GetObjectsStatusForUpdate: %w", err)
+	}
+	if q.getStatusHistorySinceStmt, err = db.PrepareContext(ctx, getStatusHistorySince); err != nil {
+		return nil, fmt.Errorf("error preparing query GetStatusHistorySince: %w", err)
+	}
+	if q.getStatusesBeforeStmt, err = db.PrepareContext(ctx, getStatusesBefore); err != nil {
+		return nil, fmt.Errorf("error preparing query GetStatusesBefore: %w", err)
+	}
+	if q.getUnprocessedCallbacksStmt, err = db.PrepareContext(ctx, getUnprocessedCallbacks); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUnprocessedCallbacks: %w", err)
+	}
+	if q.insertCallbackStmt, err = db.PrepareContext(ctx, insertCallback); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertCallback: %w", err)
+	}
+	if q.insertIdempotencyKeyStmt, err = db.PrepareContext(ctx, insertIdempotencyKey); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertIdempotencyKey: %w", err)
+	}
 	if q.insertObjectsOrUpdateStmt, err = db.PrepareContext(ctx, insertObjectsOrUpdate); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertObjectsOrUpdate: %w", err)
 	}
+	if q.insertStatusHistoryStmt, err = db.PrepareContext(ctx, insertStatusHistory); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertStatusHistory: %w", err)
+	}
+	if q.insertWebhookDeadLetterStmt, err = db.PrepareContext(ctx, insertWebhookDeadLetter); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertWebhookDeadLetter: %w", err)
+	}
+	if q.insertWebhookSubscriptionStmt, err = db.PrepareContext(ctx, insertWebhookSubscription); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertWebhookSubscription: %w", err)
+	}
+	if q.listObjectsStmt, err = db.PrepareContext(ctx, listObjects); err != nil {
+		return nil, fmt.Errorf("error preparing query ListObjects: %w", err)
+	}
+	if q.listWebhookDeadLettersStmt, err = db.PrepareContext(ctx, listWebhookDeadLetters); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhookDeadLetters: %w", err)
+	}
+	if q.listWebhookSubscriptionsStmt, err = db.PrepareContext(ctx, listWebhookSubscriptions); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhookSubscriptions: %w", err)
+	}
+	if q.markCallbackProcessedStmt, err = db.PrepareContext(ctx, markCallbackProcessed); err != nil {
+		return nil, fmt.Errorf("error preparing query MarkCallbackProcessed: %w", err)
+	}
 	if q.updateObjectsStmt, err = db.PrepareContext(ctx, updateObjects); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateObjects: %w", err)
 	}
+	if q.upsertObjectsStmt, err = db.PrepareContext(ctx, upsertObjects); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertObjects: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.deleteExpiredIdempotencyKeyStmt != nil {
+		if cerr := q.deleteExpiredIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteExpiredIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.deleteExpiredIdempotencyKeysStmt != nil {
+		if cerr := q.deleteExpiredIdempotencyKeysStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteExpiredIdempotencyKeysStmt: %w", cerr)
+		}
+	}
 	if q.deleteNotSeenObjectsStmt != nil {
 		if cerr := q.deleteNotSeenObjectsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteNotSeenObjectsStmt: %w", cerr)
 		}
 	}
+	if q.deleteOldStatusHistoryStmt != nil {
+		if cerr := q.deleteOldStatusHistoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteOldStatusHistoryStmt: %w", cerr)
+		}
+	}
+	if q.deleteProcessedCallbacksStmt != nil {
+		if cerr := q.deleteProcessedCallbacksStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteProcessedCallbacksStmt: %w", cerr)
+		}
+	}
+	if q.deleteWebhookSubscriptionStmt != nil {
+		if cerr := q.deleteWebhookSubscriptionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteWebhookSubscriptionStmt: %w", cerr)
+		}
+	}
+	if q.getIdempotencyKeyStmt != nil {
+		if cerr := q.getIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getIdempotencyKeyStmt: %w", cerr)
+		}
+	}
+	if q.getObjectStmt != nil {
+		if cerr := q.getObjectStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getObjectStmt: %w", cerr)
+		}
+	}
+	if q.getObjectTimelineStmt != nil {
+		if cerr := q.getObjectTimelineStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getObjectTimelineStmt: %w", cerr)
+		}
+	}
+	if q.getObjectsStatusForUpdateStmt != nil {
+		if cerr := q.getObjectsStatusForUpdateStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getObjectsStatusForUpdateStmt: %w", cerr)
+		}
+	}
+	if q.getStatusHistorySinceStmt != nil {
+		if cerr := q.getStatusHistorySinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getStatusHistorySinceStmt: %w", cerr)
+		}
+	}
+	if q.getStatusesBeforeStmt != nil {
+		if cerr := q.getStatusesBeforeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getStatusesBeforeStmt: %w", cerr)
+		}
+	}
+	if q.getUnprocessedCallbacksStmt != nil {
+		if cerr := q.getUnprocessedCallbacksStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUnprocessedCallbacksStmt: %w", cerr)
+		}
+	}
+	if q.insertCallbackStmt != nil {
+		if cerr := q.insertCallbackStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertCallbackStmt: %w", cerr)
+		}
+	}
+	if q.insertIdempotencyKeyStmt != nil {
+		if cerr := q.insertIdempotencyKeyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertIdempotencyKeyStmt: %w", cerr)
+		}
+	}
 	if q.insertObjectsOrUpdateStmt != nil {
 		if cerr := q.insertObjectsOrUpdateStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertObjectsOrUpdateStmt: %w", cerr)
 		}
 	}
+	if q.insertStatusHistoryStmt != nil {
+		if cerr := q.insertStatusHistoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertStatusHistoryStmt: %w", cerr)
+		}
+	}
+	if q.insertWebhookDeadLetterStmt != nil {
+		if cerr := q.insertWebhookDeadLetterStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertWebhookDeadLetterStmt: %w", cerr)
+		}
+	}
+	if q.insertWebhookSubscriptionStmt != nil {
+		if cerr := q.insertWebhookSubscriptionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertWebhookSubscriptionStmt: %w", cerr)
+		}
+	}
+	if q.listObjectsStmt != nil {
+		if cerr := q.listObjectsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listObjectsStmt: %w", cerr)
+		}
+	}
+	if q.listWebhookDeadLettersStmt != nil {
+		if cerr := q.listWebhookDeadLettersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhookDeadLettersStmt: %w", cerr)
+		}
+	}
+	if q.listWebhookSubscriptionsStmt != nil {
+		if cerr := q.listWebhookSubscriptionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhookSubscriptionsStmt: %w", cerr)
+		}
+	}
+	if q.markCallbackProcessedStmt != nil {
+		if cerr := q.markCallbackProcessedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing markCallbackProcessedStmt: %w", cerr)
+		}
+	}
 	if q.updateObjectsStmt != nil {
 		if cerr := q.updateObjectsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateObjectsStmt: %w", cerr)
 		}
 	}
+	if q.upsertObjectsStmt != nil {
+		if cerr := q.upsertObjectsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertObjectsStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -88,19 +264,63 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                        DBTX
-	tx                        *sql.Tx
-	deleteNotSeenObjectsStmt  *sql.Stmt
-	insertObjectsOrUpdateStmt *sql.Stmt
-	updateObjectsStmt         *sql.Stmt
+	db                               DBTX
+	tx                               *sql.Tx
+	deleteExpiredIdempotencyKeyStmt  *sql.Stmt
+	deleteExpiredIdempotencyKeysStmt *sql.Stmt
+	deleteNotSeenObjectsStmt         *sql.Stmt
+	deleteOldStatusHistoryStmt       *sql.Stmt
+	deleteProcessedCallbacksStmt     *sql.Stmt
+	deleteWebhookSubscriptionStmt    *sql.Stmt
+	getIdempotencyKeyStmt            *sql.Stmt
+	getObjectStmt                    *sql.Stmt
+	getObjectTimelineStmt            *sql.Stmt
+	getObjectsStatusForUpdateStmt    *sql.Stmt
+	getStatusHistorySinceStmt        *sql.Stmt
+	getStatusesBeforeStmt            *sql.Stmt
+	getUnprocessedCallbacksStmt      *sql.Stmt
+	insertCallbackStmt               *sql.Stmt
+	insertIdempotencyKeyStmt         *sql.Stmt
+	insertObjectsOrUpdateStmt        *sql.Stmt
+	insertStatusHistoryStmt          *sql.Stmt
+	insertWebhookDeadLetterStmt      *sql.Stmt
+	insertWebhookSubscriptionStmt    *sql.Stmt
+	listObjectsStmt                  *sql.Stmt
+	listWebhookDeadLettersStmt       *sql.Stmt
+	listWebhookSubscriptionsStmt     *sql.Stmt
+	markCallbackProcessedStmt        *sql.Stmt
+	updateObjectsStmt                *sql.Stmt
+	upsertObjectsStmt                *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                        tx,
-		tx:                        tx,
-		deleteNotSeenObjectsStmt:  q.deleteNotSeenObjectsStmt,
-		insertObjectsOrUpdateStmt: q.insertObjectsOrUpdateStmt,
-		updateObjectsStmt:         q.updateObjectsStmt,
+		db:                               tx,
+		tx:                               tx,
+		deleteExpiredIdempotencyKeyStmt:  q.deleteExpiredIdempotencyKeyStmt,
+		deleteExpiredIdempotencyKeysStmt: q.deleteExpiredIdempotencyKeysStmt,
+		deleteNotSeenObjectsStmt:         q.deleteNotSeenObjectsStmt,
+		deleteOldStatusHistoryStmt:       q.deleteOldStatusHistoryStmt,
+		deleteProcessedCallbacksStmt:     q.deleteProcessedCallbacksStmt,
+		deleteWebhookSubscriptionStmt:    q.deleteWebhookSubscriptionStmt,
+		getIdempotencyKeyStmt:            q.getIdempotencyKeyStmt,
+		getObjectStmt:                    q.getObjectStmt,
+		getObjectTimelineStmt:            q.getObjectTimelineStmt,
+		getObjectsStatusForUpdateStmt:    q.getObjectsStatusForUpdateStmt,
+		getStatusHistorySinceStmt:        q.getStatusHistorySinceStmt,
+		getStatusesBeforeStmt:            q.getStatusesBeforeStmt,
+		getUnprocessedCallbacksStmt:      q.getUnprocessedCallbacksStmt,
+		insertCallbackStmt:               q.insertCallbackStmt,
+		insertIdempotencyKeyStmt:         q.insertIdempotencyKeyStmt,
+		insertObjectsOrUpdateStmt:        q.insertObjectsOrUpdateStmt,
+		insertStatusHistoryStmt:          q.insertStatusHistoryStmt,
+		insertWebhookDeadLetterStmt:      q.insertWebhookDeadLetterStmt,
+		insertWebhookSubscriptionStmt:    q.insertWebhookSubscriptionStmt,
+		listObjectsStmt:                  q.listObjectsStmt,
+		listWebhookDeadLettersStmt:       q.listWebhookDeadLettersStmt,
+		listWebhookSubscriptionsStmt:     q.listWebhookSubscriptionsStmt,
+		markCallbackProcessedStmt:        q.markCallbackProcessedStmt,
+		updateObjectsStmt:                q.updateObjectsStmt,
+		upsertObjectsStmt:                q.upsertObjectsStmt,
 	}
 }
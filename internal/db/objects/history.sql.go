@@ -0,0 +1,238 @@
+// Code generated by sqlc. DO NOT EDIT.
+// source: history.sql
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const deleteOldStatusHistory = `-- name: DeleteOldStatusHistory :execrows
+DELETE
+FROM
+	bitburst."object_status_history" AS h
+WHERE
+	h.observed_at < $1::TIMESTAMPTZ
+	AND (
+		NOT EXISTS ( SELECT 1 FROM bitburst."objects" AS o WHERE o.o_id = h.o_id )
+		OR EXISTS (
+			SELECT 1
+			FROM bitburst."object_status_history" AS n
+			WHERE
+				n.o_id = h.o_id
+				AND n.observed_at < $1::TIMESTAMPTZ
+				AND ( n.observed_at > h.observed_at OR ( n.observed_at = h.observed_at AND n.h_id > h.h_id ) )
+		)
+	)
+`
+
+// the latest change before the cutoff is kept for objects that still exist, so their status at the start of a window is known
+func (q *Queries) DeleteOldStatusHistory(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.exec(ctx, q.deleteOldStatusHistoryStmt, deleteOldStatusHistory, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getObjectTimeline = `-- name: GetObjectTimeline :many
+SELECT
+	h_id, o_id, old_online, new_online, observed_at, callback_id
+FROM
+	bitburst."object_status_history"
+WHERE
+	o_id = $1::INT
+	AND observed_at >= $2::TIMESTAMPTZ
+ORDER BY observed_at, h_id
+LIMIT $3::INT
+`
+
+type GetObjectTimelineParams struct {
+	OID     int32     `json:"o_id"`
+	Since   time.Time `json:"since"`
+	MaxRows int32     `json:"max_rows"`
+}
+
+func (q *Queries) GetObjectTimeline(ctx context.Context, arg GetObjectTimelineParams) ([]BitburstObjectStatusHistory, error) {
+	rows, err := q.query(ctx, q.getObjectTimelineStmt, getObjectTimeline, arg.OID, arg.Since, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BitburstObjectStatusHistory
+	for rows.Next() {
+		var i BitburstObjectStatusHistory
+		if err := rows.Scan(
+			&i.HID,
+			&i.OID,
+			&i.OldOnline,
+			&i.NewOnline,
+			&i.ObservedAt,
+			&i.CallbackID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getObjectsStatusForUpdate = `-- name: GetObjectsStatusForUpdate :many
+SELECT
+	o_id, online
+FROM
+	bitburst."objects"
+WHERE
+	o_id = ANY($1::INT[])
+ORDER BY o_id -- lock rows in the same order, so concurrent transactions don't deadlock
+FOR UPDATE
+`
+
+type GetObjectsStatusForUpdateRow struct {
+	OID    int32 `json:"o_id"`
+	Online bool  `json:"online"`
+}
+
+func (q *Queries) GetObjectsStatusForUpdate(ctx context.Context, dollar_1 []int32) ([]GetObjectsStatusForUpdateRow, error) {
+	rows, err := q.query(ctx, q.getObjectsStatusForUpdateStmt, getObjectsStatusForUpdate, pq.Array(dollar_1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetObjectsStatusForUpdateRow
+	for rows.Next() {
+		var i GetObjectsStatusForUpdateRow
+		if err := rows.Scan(&i.OID, &i.Online); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStatusHistorySince = `-- name: GetStatusHistorySince :many
+SELECT
+	o_id, new_online, observed_at
+FROM
+	bitburst."object_status_history"
+WHERE
+	observed_at >= $1::TIMESTAMPTZ
+ORDER BY o_id, observed_at, h_id
+`
+
+type GetStatusHistorySinceRow struct {
+	OID        int32     `json:"o_id"`
+	NewOnline  bool      `json:"new_online"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+func (q *Queries) GetStatusHistorySince(ctx context.Context, since time.Time) ([]GetStatusHistorySinceRow, error) {
+	rows, err := q.query(ctx, q.getStatusHistorySinceStmt, getStatusHistorySince, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStatusHistorySinceRow
+	for rows.Next() {
+		var i GetStatusHistorySinceRow
+		if err := rows.Scan(&i.OID, &i.NewOnline, &i.ObservedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStatusesBefore = `-- name: GetStatusesBefore :many
+SELECT DISTINCT ON ( o_id )
+	o_id, new_online
+FROM
+	bitburst."object_status_history"
+WHERE
+	observed_at < $1::TIMESTAMPTZ
+ORDER BY o_id, observed_at DESC, h_id DESC
+`
+
+type GetStatusesBeforeRow struct {
+	OID       int32 `json:"o_id"`
+	NewOnline bool  `json:"new_online"`
+}
+
+func (q *Queries) GetStatusesBefore(ctx context.Context, before time.Time) ([]GetStatusesBeforeRow, error) {
+	rows, err := q.query(ctx, q.getStatusesBeforeStmt, getStatusesBefore, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStatusesBeforeRow
+	for rows.Next() {
+		var i GetStatusesBeforeRow
+		if err := rows.Scan(&i.OID, &i.NewOnline); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertStatusHistory = `-- name: InsertStatusHistory :exec
+INSERT INTO bitburst."object_status_history" ( o_id, old_online, new_online, callback_id )
+SELECT
+	t.o_id,
+	CASE WHEN t.is_new THEN NULL ELSE t.old_online END,
+	t.new_online,
+	NULLIF($1::BIGINT, 0)
+FROM (
+	SELECT
+		UNNEST($2::INT[]) AS o_id,
+		UNNEST($3::BOOLEAN[]) AS is_new,
+		UNNEST($4::BOOLEAN[]) AS old_online,
+		UNNEST($5::BOOLEAN[]) AS new_online
+) AS t
+`
+
+type InsertStatusHistoryParams struct {
+	CallbackID int64   `json:"callback_id"`
+	Ids        []int32 `json:"ids"`
+	IsNew      []bool  `json:"is_new"`
+	OldOnline  []bool  `json:"old_online"`
+	NewOnline  []bool  `json:"new_online"`
+}
+
+func (q *Queries) InsertStatusHistory(ctx context.Context, arg InsertStatusHistoryParams) error {
+	_, err := q.exec(ctx, q.insertStatusHistoryStmt, insertStatusHistory,
+		arg.CallbackID,
+		pq.Array(arg.Ids),
+		pq.Array(arg.IsNew),
+		pq.Array(arg.OldOnline),
+		pq.Array(arg.NewOnline),
+	)
+	return err
+}
@@ -0,0 +1,86 @@
+// Code generated by sqlc. DO NOT EDIT.
+// source: idempotency.sql
+
+package objects
+
+import (
+	"context"
+)
+
+const deleteExpiredIdempotencyKey = `-- name: DeleteExpiredIdempotencyKey :exec
+DELETE
+FROM
+	bitburst."idempotency_keys"
+WHERE
+	key = $1
+	AND expires_at <= CURRENT_TIMESTAMP
+`
+
+func (q *Queries) DeleteExpiredIdempotencyKey(ctx context.Context, key string) error {
+	_, err := q.exec(ctx, q.deleteExpiredIdempotencyKeyStmt, deleteExpiredIdempotencyKey, key)
+	return err
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE
+FROM
+	bitburst."idempotency_keys"
+WHERE
+	expires_at <= CURRENT_TIMESTAMP
+`
+
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := q.exec(ctx, q.deleteExpiredIdempotencyKeysStmt, deleteExpiredIdempotencyKeys)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT
+	fingerprint, callback_id
+FROM
+	bitburst."idempotency_keys"
+WHERE
+	key = $1
+	AND expires_at > CURRENT_TIMESTAMP
+`
+
+type GetIdempotencyKeyRow struct {
+	Fingerprint string `json:"fingerprint"`
+	CallbackID  int64  `json:"callback_id"`
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, key string) (GetIdempotencyKeyRow, error) {
+	row := q.queryRow(ctx, q.getIdempotencyKeyStmt, getIdempotencyKey, key)
+	var i GetIdempotencyKeyRow
+	err := row.Scan(&i.Fingerprint, &i.CallbackID)
+	return i, err
+}
+
+const insertIdempotencyKey = `-- name: InsertIdempotencyKey :execrows
+INSERT INTO bitburst."idempotency_keys" ( key, fingerprint, callback_id, expires_at )
+VALUES ( $1, $2, $3, CURRENT_TIMESTAMP + $4::BIGINT * INTERVAL '1 millisecond' )
+ON CONFLICT ( key ) DO NOTHING
+`
+
+type InsertIdempotencyKeyParams struct {
+	Key         string `json:"key"`
+	Fingerprint string `json:"fingerprint"`
+	CallbackID  int64  `json:"callback_id"`
+	TtlMs       int64  `json:"ttl_ms"`
+}
+
+func (q *Queries) InsertIdempotencyKey(ctx context.Context, arg InsertIdempotencyKeyParams) (int64, error) {
+	result, err := q.exec(ctx, q.insertIdempotencyKeyStmt, insertIdempotencyKey,
+		arg.Key,
+		arg.Fingerprint,
+		arg.CallbackID,
+		arg.TtlMs,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -3,11 +3,56 @@
 package objects
 
 import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
 	"gopkg.in/guregu/null.v4/zero"
 )
 
+type BitburstCallback struct {
+	CID         int64     `json:"c_id"`
+	ObjectIds   []int32   `json:"object_ids"`
+	ReceivedAt  time.Time `json:"received_at"`
+	ProcessedAt zero.Time `json:"processed_at"`
+}
+
+type BitburstIdempotencyKey struct {
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`
+	CallbackID  int64     `json:"callback_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
 type BitburstObject struct {
 	OID      int32     `json:"o_id"`
 	Online   bool      `json:"online"`
 	LastSeen zero.Time `json:"last_seen"`
 }
+
+type BitburstObjectStatusHistory struct {
+	HID        int64         `json:"h_id"`
+	OID        int32         `json:"o_id"`
+	OldOnline  sql.NullBool  `json:"old_online"`
+	NewOnline  bool          `json:"new_online"`
+	ObservedAt time.Time     `json:"observed_at"`
+	CallbackID sql.NullInt64 `json:"callback_id"`
+}
+
+type BitburstWebhookDeadLetter struct {
+	DID       int64           `json:"d_id"`
+	WID       int64           `json:"w_id"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int32           `json:"attempts"`
+	LastError string          `json:"last_error"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+type BitburstWebhookSubscription struct {
+	WID       int64     `json:"w_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
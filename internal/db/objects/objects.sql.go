@@ -5,6 +5,7 @@ package objects
 
 import (
 	"context"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -14,22 +15,27 @@ DELETE
 FROM
 	bitburst."objects"
 WHERE
-	last_seen < CURRENT_TIMESTAMP - INTERVAL '30 seconds' RETURNING o_id
+	last_seen < CURRENT_TIMESTAMP - $1::BIGINT * INTERVAL '1 millisecond' RETURNING o_id, online
 `
 
-func (q *Queries) DeleteNotSeenObjects(ctx context.Context) ([]int32, error) {
-	rows, err := q.query(ctx, q.deleteNotSeenObjectsStmt, deleteNotSeenObjects)
+type DeleteNotSeenObjectsRow struct {
+	OID    int32 `json:"o_id"`
+	Online bool  `json:"online"`
+}
+
+func (q *Queries) DeleteNotSeenObjects(ctx context.Context, retentionMs int64) ([]DeleteNotSeenObjectsRow, error) {
+	rows, err := q.query(ctx, q.deleteNotSeenObjectsStmt, deleteNotSeenObjects, retentionMs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []int32
+	var items []DeleteNotSeenObjectsRow
 	for rows.Next() {
-		var o_id int32
-		if err := rows.Scan(&o_id); err != nil {
+		var i DeleteNotSeenObjectsRow
+		if err := rows.Scan(&i.OID, &i.Online); err != nil {
 			return nil, err
 		}
-		items = append(items, o_id)
+		items = append(items, i)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -40,11 +46,28 @@ func (q *Queries) DeleteNotSeenObjects(ctx context.Context) ([]int32, error) {
 	return items, nil
 }
 
+const getObject = `-- name: GetObject :one
+SELECT
+	o_id, online, last_seen
+FROM
+	bitburst."objects"
+WHERE
+	o_id = $1
+`
+
+func (q *Queries) GetObject(ctx context.Context, oID int32) (BitburstObject, error) {
+	row := q.queryRow(ctx, q.getObjectStmt, getObject, oID)
+	var i BitburstObject
+	err := row.Scan(&i.OID, &i.Online, &i.LastSeen)
+	return i, err
+}
+
 const insertObjectsOrUpdate = `-- name: InsertObjectsOrUpdate :many
 INSERT INTO bitburst."objects" ( o_id )
 VALUES ( UNNEST($1::INT[]) ) ON CONFLICT ( o_id ) DO
 UPDATE
-	SET last_seen = CURRENT_TIMESTAMP
+	SET last_seen = CURRENT_TIMESTAMP,
+		online = true
 RETURNING o_id
 `
 
@@ -71,6 +94,63 @@ func (q *Queries) InsertObjectsOrUpdate(ctx context.Context, dollar_1 []int32) (
 	return items, nil
 }
 
+const listObjects = `-- name: ListObjects :many
+SELECT
+	o_id, online, last_seen
+FROM
+	bitburst."objects"
+WHERE
+	o_id > $1::INT
+	AND ( NOT $2::BOOLEAN OR online = $3::BOOLEAN )
+	AND ( NOT $4::BOOLEAN OR last_seen >= $5::TIMESTAMPTZ )
+	AND ( NOT $6::BOOLEAN OR last_seen < $7::TIMESTAMPTZ )
+ORDER BY o_id
+LIMIT $8::INT
+`
+
+type ListObjectsParams struct {
+	AfterID          int32     `json:"after_id"`
+	FilterOnline     bool      `json:"filter_online"`
+	Online           bool      `json:"online"`
+	FilterSeenAfter  bool      `json:"filter_seen_after"`
+	SeenAfter        time.Time `json:"seen_after"`
+	FilterSeenBefore bool      `json:"filter_seen_before"`
+	SeenBefore       time.Time `json:"seen_before"`
+	MaxRows          int32     `json:"max_rows"`
+}
+
+func (q *Queries) ListObjects(ctx context.Context, arg ListObjectsParams) ([]BitburstObject, error) {
+	rows, err := q.query(ctx, q.listObjectsStmt, listObjects,
+		arg.AfterID,
+		arg.FilterOnline,
+		arg.Online,
+		arg.FilterSeenAfter,
+		arg.SeenAfter,
+		arg.FilterSeenBefore,
+		arg.SeenBefore,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BitburstObject
+	for rows.Next() {
+		var i BitburstObject
+		if err := rows.Scan(&i.OID, &i.Online, &i.LastSeen); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateObjects = `-- name: UpdateObjects :many
 UPDATE bitburst."objects"
 	SET online = false
@@ -101,3 +181,47 @@ func (q *Queries) UpdateObjects(ctx context.Context, dollar_1 []int32) ([]int32,
 	}
 	return items, nil
 }
+
+const upsertObjects = `-- name: UpsertObjects :many
+INSERT INTO bitburst."objects" ( o_id, online, last_seen )
+SELECT
+	UNNEST($1::INT[]), UNNEST($2::BOOLEAN[]), CURRENT_TIMESTAMP
+ON CONFLICT ( o_id ) DO
+UPDATE
+	SET online = EXCLUDED.online,
+		last_seen = EXCLUDED.last_seen
+RETURNING o_id, ( xmax = 0 )::BOOLEAN AS inserted
+`
+
+type UpsertObjectsParams struct {
+	Ids    []int32 `json:"ids"`
+	Online []bool  `json:"online"`
+}
+
+type UpsertObjectsRow struct {
+	OID      int32 `json:"o_id"`
+	Inserted bool  `json:"inserted"`
+}
+
+func (q *Queries) UpsertObjects(ctx context.Context, arg UpsertObjectsParams) ([]UpsertObjectsRow, error) {
+	rows, err := q.query(ctx, q.upsertObjectsStmt, upsertObjects, pq.Array(arg.Ids), pq.Array(arg.Online))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UpsertObjectsRow
+	for rows.Next() {
+		var i UpsertObjectsRow
+		if err := rows.Scan(&i.OID, &i.Inserted); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -4,12 +4,35 @@ package objects
 
 import (
 	"context"
+	"time"
 )
 
 type Querier interface {
-	DeleteNotSeenObjects(ctx context.Context) ([]int32, error)
+	DeleteExpiredIdempotencyKey(ctx context.Context, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	DeleteNotSeenObjects(ctx context.Context, retentionMs int64) ([]DeleteNotSeenObjectsRow, error)
+	DeleteOldStatusHistory(ctx context.Context, before time.Time) (int64, error)
+	DeleteProcessedCallbacks(ctx context.Context, retentionMs int64) (int64, error)
+	DeleteWebhookSubscription(ctx context.Context, wID int64) (int64, error)
+	GetIdempotencyKey(ctx context.Context, key string) (GetIdempotencyKeyRow, error)
+	GetObject(ctx context.Context, oID int32) (BitburstObject, error)
+	GetObjectTimeline(ctx context.Context, arg GetObjectTimelineParams) ([]BitburstObjectStatusHistory, error)
+	GetObjectsStatusForUpdate(ctx context.Context, dollar_1 []int32) ([]GetObjectsStatusForUpdateRow, error)
+	GetStatusHistorySince(ctx context.Context, since time.Time) ([]GetStatusHistorySinceRow, error)
+	GetStatusesBefore(ctx context.Context, before time.Time) ([]GetStatusesBeforeRow, error)
+	GetUnprocessedCallbacks(ctx context.Context, arg GetUnprocessedCallbacksParams) ([]GetUnprocessedCallbacksRow, error)
+	InsertCallback(ctx context.Context, dollar_1 []int32) (int64, error)
+	InsertIdempotencyKey(ctx context.Context, arg InsertIdempotencyKeyParams) (int64, error)
 	InsertObjectsOrUpdate(ctx context.Context, dollar_1 []int32) ([]int32, error)
+	InsertStatusHistory(ctx context.Context, arg InsertStatusHistoryParams) error
+	InsertWebhookDeadLetter(ctx context.Context, arg InsertWebhookDeadLetterParams) error
+	InsertWebhookSubscription(ctx context.Context, arg InsertWebhookSubscriptionParams) (BitburstWebhookSubscription, error)
+	ListObjects(ctx context.Context, arg ListObjectsParams) ([]BitburstObject, error)
+	ListWebhookDeadLetters(ctx context.Context, arg ListWebhookDeadLettersParams) ([]BitburstWebhookDeadLetter, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]BitburstWebhookSubscription, error)
+	MarkCallbackProcessed(ctx context.Context, cID int64) error
 	UpdateObjects(ctx context.Context, dollar_1 []int32) ([]int32, error)
+	UpsertObjects(ctx context.Context, arg UpsertObjectsParams) ([]UpsertObjectsRow, error)
 }
 
 var _ Querier = (*Queries)(nil)
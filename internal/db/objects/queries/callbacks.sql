@@ -0,0 +1,28 @@
+-- name: InsertCallback :one
+INSERT INTO bitburst."callbacks" ( object_ids )
+VALUES ( $1::INT[] )
+RETURNING c_id;
+
+-- name: MarkCallbackProcessed :exec
+UPDATE bitburst."callbacks"
+	SET processed_at = CURRENT_TIMESTAMP
+WHERE
+	c_id = $1;
+
+-- name: GetUnprocessedCallbacks :many
+SELECT
+	c_id, object_ids, received_at
+FROM
+	bitburst."callbacks"
+WHERE
+	processed_at IS NULL
+	AND received_at <= CURRENT_TIMESTAMP - sqlc.arg(min_age_ms)::BIGINT * INTERVAL '1 millisecond'
+ORDER BY c_id
+LIMIT sqlc.arg(max_rows)::INT;
+
+-- name: DeleteProcessedCallbacks :execrows
+DELETE
+FROM
+	bitburst."callbacks"
+WHERE
+	processed_at < CURRENT_TIMESTAMP - sqlc.arg(retention_ms)::BIGINT * INTERVAL '1 millisecond';
@@ -0,0 +1,72 @@
+-- name: GetObjectsStatusForUpdate :many
+SELECT
+	o_id, online
+FROM
+	bitburst."objects"
+WHERE
+	o_id = ANY($1::INT[])
+ORDER BY o_id -- lock rows in the same order, so concurrent transactions don't deadlock
+FOR UPDATE;
+
+-- name: InsertStatusHistory :exec
+INSERT INTO bitburst."object_status_history" ( o_id, old_online, new_online, callback_id )
+SELECT
+	t.o_id,
+	CASE WHEN t.is_new THEN NULL ELSE t.old_online END,
+	t.new_online,
+	NULLIF(sqlc.arg(callback_id)::BIGINT, 0)
+FROM (
+	SELECT
+		UNNEST(sqlc.arg(ids)::INT[]) AS o_id,
+		UNNEST(sqlc.arg(is_new)::BOOLEAN[]) AS is_new,
+		UNNEST(sqlc.arg(old_online)::BOOLEAN[]) AS old_online,
+		UNNEST(sqlc.arg(new_online)::BOOLEAN[]) AS new_online
+) AS t;
+
+-- name: GetObjectTimeline :many
+SELECT
+	h_id, o_id, old_online, new_online, observed_at, callback_id
+FROM
+	bitburst."object_status_history"
+WHERE
+	o_id = sqlc.arg(o_id)::INT
+	AND observed_at >= sqlc.arg(since)::TIMESTAMPTZ
+ORDER BY observed_at, h_id
+LIMIT sqlc.arg(max_rows)::INT;
+
+-- name: GetStatusesBefore :many
+SELECT DISTINCT ON ( o_id )
+	o_id, new_online
+FROM
+	bitburst."object_status_history"
+WHERE
+	observed_at < sqlc.arg(before)::TIMESTAMPTZ
+ORDER BY o_id, observed_at DESC, h_id DESC;
+
+-- name: GetStatusHistorySince :many
+SELECT
+	o_id, new_online, observed_at
+FROM
+	bitburst."object_status_history"
+WHERE
+	observed_at >= sqlc.arg(since)::TIMESTAMPTZ
+ORDER BY o_id, observed_at, h_id;
+
+-- name: DeleteOldStatusHistory :execrows
+-- the latest change before the cutoff is kept for objects that still exist, so their status at the start of a window is known
+DELETE
+FROM
+	bitburst."object_status_history" AS h
+WHERE
+	h.observed_at < sqlc.arg(before)::TIMESTAMPTZ
+	AND (
+		NOT EXISTS ( SELECT 1 FROM bitburst."objects" AS o WHERE o.o_id = h.o_id )
+		OR EXISTS (
+			SELECT 1
+			FROM bitburst."object_status_history" AS n
+			WHERE
+				n.o_id = h.o_id
+				AND n.observed_at < sqlc.arg(before)::TIMESTAMPTZ
+				AND ( n.observed_at > h.observed_at OR ( n.observed_at = h.observed_at AND n.h_id > h.h_id ) )
+		)
+	);
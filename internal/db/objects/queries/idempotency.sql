@@ -0,0 +1,28 @@
+-- name: GetIdempotencyKey :one
+SELECT
+	fingerprint, callback_id
+FROM
+	bitburst."idempotency_keys"
+WHERE
+	key = $1
+	AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: InsertIdempotencyKey :execrows
+INSERT INTO bitburst."idempotency_keys" ( key, fingerprint, callback_id, expires_at )
+VALUES ( sqlc.arg(key), sqlc.arg(fingerprint), sqlc.arg(callback_id), CURRENT_TIMESTAMP + sqlc.arg(ttl_ms)::BIGINT * INTERVAL '1 millisecond' )
+ON CONFLICT ( key ) DO NOTHING;
+
+-- name: DeleteExpiredIdempotencyKey :exec
+DELETE
+FROM
+	bitburst."idempotency_keys"
+WHERE
+	key = $1
+	AND expires_at <= CURRENT_TIMESTAMP;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE
+FROM
+	bitburst."idempotency_keys"
+WHERE
+	expires_at <= CURRENT_TIMESTAMP;
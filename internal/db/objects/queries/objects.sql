@@ -6,6 +6,16 @@ UPDATE
 		online = true
 RETURNING o_id;
 
+-- name: UpsertObjects :many
+INSERT INTO bitburst."objects" ( o_id, online, last_seen )
+SELECT
+	UNNEST(sqlc.arg(ids)::INT[]), UNNEST(sqlc.arg(online)::BOOLEAN[]), CURRENT_TIMESTAMP
+ON CONFLICT ( o_id ) DO
+UPDATE
+	SET online = EXCLUDED.online,
+		last_seen = EXCLUDED.last_seen
+RETURNING o_id, ( xmax = 0 )::BOOLEAN AS inserted;
+
 -- name: UpdateObjects :many
 UPDATE bitburst."objects"
 	SET online = false
@@ -18,4 +28,25 @@ DELETE
 FROM
 	bitburst."objects"
 WHERE
-	last_seen < CURRENT_TIMESTAMP - INTERVAL '30 seconds' RETURNING o_id;
\ No newline at end of file
+	last_seen < CURRENT_TIMESTAMP - sqlc.arg(retention_ms)::BIGINT * INTERVAL '1 millisecond' RETURNING o_id, online;
+
+-- name: GetObject :one
+SELECT
+	o_id, online, last_seen
+FROM
+	bitburst."objects"
+WHERE
+	o_id = $1;
+
+-- name: ListObjects :many
+SELECT
+	o_id, online, last_seen
+FROM
+	bitburst."objects"
+WHERE
+	o_id > sqlc.arg(after_id)::INT
+	AND ( NOT sqlc.arg(filter_online)::BOOLEAN OR online = sqlc.arg(online)::BOOLEAN )
+	AND ( NOT sqlc.arg(filter_seen_after)::BOOLEAN OR last_seen >= sqlc.arg(seen_after)::TIMESTAMPTZ )
+	AND ( NOT sqlc.arg(filter_seen_before)::BOOLEAN OR last_seen < sqlc.arg(seen_before)::TIMESTAMPTZ )
+ORDER BY o_id
+LIMIT sqlc.arg(max_rows)::INT;
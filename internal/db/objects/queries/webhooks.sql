@@ -0,0 +1,32 @@
+-- name: InsertWebhookSubscription :one
+INSERT INTO bitburst."webhook_subscriptions" ( url, secret, events )
+VALUES ( sqlc.arg(url)::TEXT, sqlc.arg(secret)::TEXT, sqlc.arg(events)::TEXT[] )
+RETURNING w_id, url, secret, events, created_at;
+
+-- name: ListWebhookSubscriptions :many
+SELECT
+	w_id, url, secret, events, created_at
+FROM
+	bitburst."webhook_subscriptions"
+ORDER BY w_id;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE
+FROM
+	bitburst."webhook_subscriptions"
+WHERE
+	w_id = $1;
+
+-- name: InsertWebhookDeadLetter :exec
+INSERT INTO bitburst."webhook_dead_letters" ( w_id, payload, attempts, last_error )
+VALUES ( sqlc.arg(w_id)::BIGINT, sqlc.arg(payload)::JSONB, sqlc.arg(attempts)::INT, sqlc.arg(last_error)::TEXT );
+
+-- name: ListWebhookDeadLetters :many
+SELECT
+	d_id, w_id, payload, attempts, last_error, failed_at
+FROM
+	bitburst."webhook_dead_letters"
+WHERE
+	d_id > sqlc.arg(after_id)::BIGINT
+ORDER BY d_id
+LIMIT sqlc.arg(max_rows)::INT;
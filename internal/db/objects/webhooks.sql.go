@@ -0,0 +1,157 @@
+// Code generated by sqlc. DO NOT EDIT.
+// source: webhooks.sql
+
+package objects
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :execrows
+DELETE
+FROM
+	bitburst."webhook_subscriptions"
+WHERE
+	w_id = $1
+`
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, wID int64) (int64, error) {
+	result, err := q.exec(ctx, q.deleteWebhookSubscriptionStmt, deleteWebhookSubscription, wID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertWebhookDeadLetter = `-- name: InsertWebhookDeadLetter :exec
+INSERT INTO bitburst."webhook_dead_letters" ( w_id, payload, attempts, last_error )
+VALUES ( $1::BIGINT, $2::JSONB, $3::INT, $4::TEXT )
+`
+
+type InsertWebhookDeadLetterParams struct {
+	WID       int64           `json:"w_id"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int32           `json:"attempts"`
+	LastError string          `json:"last_error"`
+}
+
+func (q *Queries) InsertWebhookDeadLetter(ctx context.Context, arg InsertWebhookDeadLetterParams) error {
+	_, err := q.exec(ctx, q.insertWebhookDeadLetterStmt, insertWebhookDeadLetter,
+		arg.WID,
+		arg.Payload,
+		arg.Attempts,
+		arg.LastError,
+	)
+	return err
+}
+
+const insertWebhookSubscription = `-- name: InsertWebhookSubscription :one
+INSERT INTO bitburst."webhook_subscriptions" ( url, secret, events )
+VALUES ( $1::TEXT, $2::TEXT, $3::TEXT[] )
+RETURNING w_id, url, secret, events, created_at
+`
+
+type InsertWebhookSubscriptionParams struct {
+	Url    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+func (q *Queries) InsertWebhookSubscription(ctx context.Context, arg InsertWebhookSubscriptionParams) (BitburstWebhookSubscription, error) {
+	row := q.queryRow(ctx, q.insertWebhookSubscriptionStmt, insertWebhookSubscription, arg.Url, arg.Secret, pq.Array(arg.Events))
+	var i BitburstWebhookSubscription
+	err := row.Scan(
+		&i.WID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebhookDeadLetters = `-- name: ListWebhookDeadLetters :many
+SELECT
+	d_id, w_id, payload, attempts, last_error, failed_at
+FROM
+	bitburst."webhook_dead_letters"
+WHERE
+	d_id > $1::BIGINT
+ORDER BY d_id
+LIMIT $2::INT
+`
+
+type ListWebhookDeadLettersParams struct {
+	AfterID int64 `json:"after_id"`
+	MaxRows int32 `json:"max_rows"`
+}
+
+func (q *Queries) ListWebhookDeadLetters(ctx context.Context, arg ListWebhookDeadLettersParams) ([]BitburstWebhookDeadLetter, error) {
+	rows, err := q.query(ctx, q.listWebhookDeadLettersStmt, listWebhookDeadLetters, arg.AfterID, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BitburstWebhookDeadLetter
+	for rows.Next() {
+		var i BitburstWebhookDeadLetter
+		if err := rows.Scan(
+			&i.DID,
+			&i.WID,
+			&i.Payload,
+			&i.Attempts,
+			&i.LastError,
+			&i.FailedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT
+	w_id, url, secret, events, created_at
+FROM
+	bitburst."webhook_subscriptions"
+ORDER BY w_id
+`
+
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context) ([]BitburstWebhookSubscription, error) {
+	rows, err := q.query(ctx, q.listWebhookSubscriptionsStmt, listWebhookSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BitburstWebhookSubscription
+	for rows.Next() {
+		var i BitburstWebhookSubscription
+		if err := rows.Scan(
+			&i.WID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
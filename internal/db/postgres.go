@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql"
 	"net/url"
+	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 
@@ -23,8 +24,14 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 )
 
-// Config contains configuration needed for constructing Postgres database
+// Config contains configuration needed for constructing storage
 type Config struct {
+	// Driver is a storage backend: postgres, sqlite or memory
+	Driver string `mapstructure:"driver"`
+
+	// Path is a path of database file, it's only used by sqlite driver
+	Path string `mapstructure:"path"`
+
 	Host     string `mapstructure:"host"`
 	Port     string `mapstructure:"port"`
 	Username string `mapstructure:"username"`
@@ -33,6 +40,41 @@ type Config struct {
 
 	MigrationVersion int    `mapstructure:"migration_version"`
 	SSLmode          string `mapstructure:"sslmode"` // enable/disable
+
+	// Retention is how long objects are kept after they were last seen,
+	// SweepInterval is how often objects that weren't seen during retention are deleted
+	Retention     time.Duration `mapstructure:"retention"`
+	SweepInterval time.Duration `mapstructure:"sweep_interval"`
+
+	// HistoryRetention is how long status changes are kept in objects status history, they are pruned on sweeps.
+	// The latest change before it is kept for objects that still exist, so uptime of windows up to it is exact.
+	// 0 means history is kept forever
+	HistoryRetention time.Duration `mapstructure:"history_retention"`
+
+	// Redis is an optional cache of objects status in front of storage
+	Redis RedisConfig `mapstructure:"redis"`
+
+	// LegacyUpsert switches to old behaviour, when only online objects are inserted,
+	// and offline objects are only marked as offline without refreshing their last_seen
+	LegacyUpsert bool `mapstructure:"legacy_upsert"`
+}
+
+// validate checks if configuration values are sane
+func (conf *Config) validate() error {
+	if conf.Retention <= 0 {
+		return errors.New("retention must be positive")
+	}
+
+	if conf.SweepInterval <= 0 {
+		return errors.New("sweep interval must be positive")
+	}
+
+	// otherwise objects would live noticeably longer than retention window
+	if conf.SweepInterval > conf.Retention {
+		return errors.Errorf("sweep interval %s is longer than retention %s", conf.SweepInterval, conf.Retention)
+	}
+
+	return nil
 }
 
 // DB contains Postgres database dependencies
@@ -40,14 +82,24 @@ type DB struct {
 	sqlDB *sql.DB
 	q     *objects.Queries
 	logger *zerolog.Logger
+
+	// emitter notifies listeners about object events
+	emitter
+
+	conf *Config
 }
 
 // NewEnv initializez connection to Postgres database and injects dependencies to it, returns a structure for interacting with database.
 func New(conf *Config, logger *zerolog.Logger) (db *DB, err error) {
 	defer errors.Wrap(err, "db.NewEnv")
 
+	if err := conf.validate(); err != nil {
+		return nil, errors.WithMessage(err, "invalid configuration")
+	}
+
 	db = &DB{
 		logger: logger,
+		conf:   conf,
 	}
 
 	// constuct connection url in form of: postgresql://{username}:{password}@{host}:{port}/{database}?sslmode=true|false
@@ -137,6 +189,36 @@ func (db *DB) prepareStmts() (err error) {
 	return nil
 }
 
+// Ping checks if database is reachable
+func (db *DB) Ping(ctx context.Context) error {
+	return errors.WithMessage(db.sqlDB.PingContext(ctx), "failed to ping database")
+}
+
+// CheckMigrations checks if database schemas are migrated to configured version and last migration didn't fail,
+// it returns current migration version
+func (db *DB) CheckMigrations(ctx context.Context) (version int, err error) {
+	var dirty bool
+	err = db.sqlDB.QueryRowContext(ctx, `SELECT version, dirty FROM bitburst."schema_migrations" LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to get migration version")
+	}
+
+	return version, db.conf.checkMigrationVersion(version, dirty)
+}
+
+// checkMigrationVersion checks that migration to version succeeded and it's the configured one
+func (conf *Config) checkMigrationVersion(version int, dirty bool) error {
+	if dirty {
+		return errors.Errorf("migration to version %d failed and left database dirty", version)
+	}
+
+	if version != conf.MigrationVersion {
+		return errors.Errorf("database is migrated to version %d, expected version %d", version, conf.MigrationVersion)
+	}
+
+	return nil
+}
+
 // Close closes database connection
 func (db *DB) Close() error {
 	return errors.Wrap(db.sqlDB.Close(), "db.Env.Close")
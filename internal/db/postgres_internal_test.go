@@ -0,0 +1,45 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		input   *Config
+		wantErr bool
+	}{
+		"default": {
+			input: &Config{Retention: 30 * time.Second, SweepInterval: 30 * time.Second},
+		},
+		"sweep more often than retention": {
+			input: &Config{Retention: time.Minute, SweepInterval: 10 * time.Second},
+		},
+		"sweep interval longer than retention": {
+			input:   &Config{Retention: 30 * time.Second, SweepInterval: time.Minute},
+			wantErr: true,
+		},
+		"no retention": {
+			input:   &Config{SweepInterval: time.Minute},
+			wantErr: true,
+		},
+		"no sweep interval": {
+			input:   &Config{Retention: time.Minute},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.input.validate()
+			if tc.wantErr {
+				require.NotNil(t, err, "expected invalid configuration")
+			} else {
+				require.Nil(t, err, "expected valid configuration")
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"bitburst-assessment-task/internal/metrics"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// RedisConfig holds configuration of redis cache of objects status
+type RedisConfig struct {
+	// Address of redis server, cache is disabled when it's empty
+	Address  string `mapstructure:"address"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+
+	// KeyPrefix is prepended to all keys, so redis can be shared with other services
+	KeyPrefix string `mapstructure:"key_prefix"`
+}
+
+// defaultRedisKeyPrefix is used when key prefix isn't set in config
+const defaultRedisKeyPrefix = "bitburst:"
+
+// RedisCache is a write-through cache of objects status in front of storage.
+// Every stored object has a key with TTL equal to retention window, so keys expire at the same time
+// objects should be deleted, and expiry notifications of redis trigger sweeps of storage.
+// All other operations are passed to storage as they are.
+type RedisCache struct {
+	Storage
+
+	rdb *redis.Client
+
+	logger *zerolog.Logger
+	conf   *Config
+}
+
+// NewRedisCache connects to redis and puts it in front of storage
+func NewRedisCache(conf *Config, storage Storage, logger *zerolog.Logger) (*RedisCache, error) {
+	if conf.Redis.KeyPrefix == "" {
+		conf.Redis.KeyPrefix = defaultRedisKeyPrefix
+	}
+
+	c := &RedisCache{
+		Storage: storage,
+		rdb: redis.NewClient(&redis.Options{
+			Addr:     conf.Redis.Address,
+			Password: conf.Redis.Password,
+			DB:       conf.Redis.DB,
+		}),
+		conf: conf,
+	}
+
+	subLogger := logger.With().Str("component", "redis").Logger()
+	c.logger = &subLogger
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.rdb.Ping(ctx).Err(); err != nil {
+		_ = c.rdb.Close()
+		return nil, errors.WithMessage(err, "failed to ping redis")
+	}
+
+	// expiry notifications are off by default, sweeps still run every sweep interval if they can't be enabled,
+	// e.g. when CONFIG command is disabled by managed redis
+	if err := c.enableExpiryNotifications(ctx); err != nil {
+		c.logger.Warn().Err(err).Msg("failed to enable key expiry notifications, objects will only be deleted every sweep interval")
+	}
+
+	return c, nil
+}
+
+// enableExpiryNotifications turns on notifications about expired keys, keeping other configured notifications
+func (c *RedisCache) enableExpiryNotifications(ctx context.Context) error {
+	res, err := c.rdb.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+
+	var flags string
+	if len(res) == 2 {
+		flags, _ = res[1].(string)
+	}
+	if strings.Contains(flags, "E") && (strings.Contains(flags, "x") || strings.Contains(flags, "A")) {
+		return nil
+	}
+
+	return c.rdb.ConfigSet(ctx, "notify-keyspace-events", flags+"Ex").Err()
+}
+
+// objectKey returns key of object status
+func (c *RedisCache) objectKey(id int32) string {
+	return c.conf.Redis.KeyPrefix + "object:" + strconv.FormatInt(int64(id), 10)
+}
+
+// Ping checks if both storage and redis are reachable
+func (c *RedisCache) Ping(ctx context.Context) error {
+	if err := c.Storage.Ping(ctx); err != nil {
+		return err
+	}
+
+	return errors.WithMessage(c.rdb.Ping(ctx).Err(), "failed to ping redis")
+}
+
+// Close closes redis connection and storage
+func (c *RedisCache) Close() error {
+	rerr := c.rdb.Close()
+	if err := c.Storage.Close(); err != nil {
+		return err
+	}
+
+	return errors.WithMessage(rerr, "failed to close redis connection")
+}
+
+// InsertObjectsOrUpdate stores objects in storage and then writes their statuses to cache,
+// cache failures are only logged, as storage is the source of truth
+func (c *RedisCache) InsertObjectsOrUpdate(ctx context.Context, callbackID int64, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error) {
+	insertedIDs, updatedIDs, err = c.Storage.InsertObjectsOrUpdate(ctx, callbackID, onlineIDs, offlineIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	pipe := c.rdb.Pipeline()
+	set := func(id int32, online bool) {
+		value, err := json.Marshal(&objects.BitburstObject{OID: id, Online: online, LastSeen: zero.TimeFrom(now)})
+		if err != nil {
+			c.logger.Warn().Err(err).Int32("id", id).Msg("failed to encode object status")
+			pipe.Del(ctx, c.objectKey(id))
+			return
+		}
+		pipe.Set(ctx, c.objectKey(id), value, c.conf.Retention)
+	}
+
+	for _, id := range onlineIDs {
+		set(id, true)
+	}
+	for _, id := range offlineIDs {
+		if c.conf.LegacyUpsert {
+			// last_seen of offline objects isn't refreshed in legacy mode, so cached entry is dropped
+			// and next read takes it from storage
+			pipe.Del(ctx, c.objectKey(id))
+			continue
+		}
+		set(id, false)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.logger.Warn().Err(err).Msg("failed to write objects status to cache")
+	}
+
+	return insertedIDs, updatedIDs, nil
+}
+
+// GetObject returns object status from cache, falling back to storage on a miss
+func (c *RedisCache) GetObject(ctx context.Context, id int32) (*objects.BitburstObject, error) {
+	value, err := c.rdb.Get(ctx, c.objectKey(id)).Bytes()
+	if err == nil {
+		var obj objects.BitburstObject
+		if err = json.Unmarshal(value, &obj); err == nil {
+			metrics.StatusCacheRequests.WithLabelValues("hit").Inc()
+			return &obj, nil
+		}
+	}
+
+	if err == redis.Nil {
+		metrics.StatusCacheRequests.WithLabelValues("miss").Inc()
+	} else {
+		metrics.StatusCacheRequests.WithLabelValues("error").Inc()
+		c.logger.Warn().Err(err).Int32("id", id).Msg("failed to get object status from cache")
+	}
+
+	obj, err := c.Storage.GetObject(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// cache object till the moment it should be deleted, only if key is still absent,
+	// as a concurrent write-through could store a fresher status after it was read from storage
+	if ttl := c.conf.Retention - time.Since(obj.LastSeen.Time); obj.LastSeen.Valid && ttl > 0 {
+		value, err := json.Marshal(obj)
+		if err == nil {
+			err = c.rdb.SetNX(ctx, c.objectKey(id), value, ttl).Err()
+		}
+		if err != nil {
+			c.logger.Warn().Err(err).Int32("id", id).Msg("failed to write object status to cache")
+		}
+	}
+
+	return obj, nil
+}
+
+// DeleteNotSeenObjects deletes objects that weren't seen during retention window every sweep interval
+// and whenever cached object status expires, it's to run in background.
+// When a job is done on behalf of this function, context should be canceled.
+func (c *RedisCache) DeleteNotSeenObjects(ctx context.Context) {
+	expired := make(chan struct{}, 1)
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		c.watchExpiredKeys(ctx, expired)
+	}()
+
+	runSweeper(ctx, c.conf.SweepInterval, expired, c.SweepNotSeenObjects, c.logger)
+
+	// subscription must be closed before redis client is
+	<-watchDone
+}
+
+// watchExpiredKeys notifies expired channel when any object status key expires,
+// notifications are coalesced, as one sweep deletes all objects that expired by then
+func (c *RedisCache) watchExpiredKeys(ctx context.Context, expired chan<- struct{}) {
+	pubsub := c.rdb.Subscribe(ctx, "__keyevent@"+strconv.Itoa(c.conf.Redis.DB)+"__:expired")
+	defer func() {
+		if err := pubsub.Close(); err != nil {
+			c.logger.Warn().Err(err).Msg("failed to close expiry notifications subscription")
+		}
+	}()
+
+	keyPrefix := c.conf.Redis.KeyPrefix + "object:"
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !strings.HasPrefix(msg.Payload, keyPrefix) {
+				continue
+			}
+
+			select {
+			case expired <- struct{}{}:
+			default: // sweep is already scheduled
+			}
+		}
+	}
+}
+
+// SweepNotSeenObjects runs one sweep of storage and drops cached statuses of deleted objects
+func (c *RedisCache) SweepNotSeenObjects(ctx context.Context) ([]int32, error) {
+	deletedIDs, err := c.Storage.SweepNotSeenObjects(ctx)
+	if err != nil || len(deletedIDs) == 0 {
+		return deletedIDs, err
+	}
+
+	keys := make([]string, 0, len(deletedIDs))
+	for _, id := range deletedIDs {
+		keys = append(keys, c.objectKey(id))
+	}
+	if err := c.rdb.Del(ctx, keys...).Err(); err != nil {
+		c.logger.Warn().Err(err).Msg("failed to delete objects status from cache")
+	}
+
+	return deletedIDs, nil
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedisCache puts in-memory storage behind cache backed by in-process redis
+func newTestRedisCache(t *testing.T, conf *Config) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	zlog := zerolog.Nop()
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err, "failed to start redis")
+	t.Cleanup(mr.Close)
+
+	storage, err := NewMemory(conf, &zlog)
+	require.NoError(t, err)
+
+	conf.Redis.Address = mr.Addr()
+	c, err := NewRedisCache(conf, storage, &zlog)
+	require.NoError(t, err, "failed to connect to redis")
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close(), "failed to close cache")
+	})
+
+	return c, mr
+}
+
+func TestRedisCacheStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T, conf *Config) Storage {
+		c, _ := newTestRedisCache(t, conf)
+		return c
+	})
+}
+
+func TestRedisCacheWriteThrough(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		legacy         bool
+		wantOfflineKey bool
+	}{
+		"default": {
+			wantOfflineKey: true,
+		},
+		"legacy upsert": {
+			legacy: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, mr := newTestRedisCache(t, &Config{Retention: time.Minute, SweepInterval: time.Minute, LegacyUpsert: tc.legacy})
+
+			// object 2 has to exist, so it's updated in legacy mode as well
+			_, _, err := c.InsertObjectsOrUpdate(ctx, 0, []int32{1, 2}, nil)
+			require.NoError(t, err)
+			_, _, err = c.InsertObjectsOrUpdate(ctx, 0, []int32{1}, []int32{2})
+			require.NoError(t, err)
+
+			assert.True(t, mr.Exists("bitburst:object:1"))
+			assert.Equal(t, time.Minute, mr.TTL("bitburst:object:1"), "ttl must be equal to retention")
+			assert.Equal(t, tc.wantOfflineKey, mr.Exists("bitburst:object:2"))
+
+			// offline object is read from storage on a miss and cached again
+			obj, err := c.GetObject(ctx, 2)
+			require.NoError(t, err)
+			assert.False(t, obj.Online)
+			assert.True(t, mr.Exists("bitburst:object:2"))
+		})
+	}
+
+	t.Run("served from cache", func(t *testing.T) {
+		c, mr := newTestRedisCache(t, &Config{Retention: time.Minute, SweepInterval: time.Minute})
+
+		_, _, err := c.InsertObjectsOrUpdate(ctx, 0, []int32{1}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, mr.Set("bitburst:object:1", `{"o_id":1,"online":false}`))
+
+		obj, err := c.GetObject(ctx, 1)
+		require.NoError(t, err)
+		assert.False(t, obj.Online, "status should come from cache")
+
+		_, err = c.GetObject(ctx, 2)
+		assert.Equal(t, ErrObjectNotFound, err)
+	})
+}
+
+// racingStorage is a storage which status of object is written through cache right after it's read from storage
+type racingStorage struct {
+	Storage
+	cache *RedisCache
+}
+
+func (s *racingStorage) GetObject(ctx context.Context, id int32) (*objects.BitburstObject, error) {
+	obj, err := s.Storage.GetObject(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := s.cache.InsertObjectsOrUpdate(ctx, 0, nil, []int32{id}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func TestRedisCacheConcurrentFill(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestRedisCache(t, &Config{Retention: time.Minute, SweepInterval: time.Minute})
+
+	_, _, err := c.InsertObjectsOrUpdate(ctx, 0, []int32{1}, nil)
+	require.NoError(t, err)
+	mr.Del("bitburst:object:1")
+
+	// status read on a miss is stale by the time it's cached, so it mustn't overwrite the fresh one
+	c.Storage = &racingStorage{Storage: c.Storage, cache: c}
+	obj, err := c.GetObject(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, obj.Online)
+
+	value, err := mr.Get("bitburst:object:1")
+	require.NoError(t, err)
+	assert.Contains(t, value, `"online":false`)
+	assert.Equal(t, time.Minute, mr.TTL("bitburst:object:1"))
+}
+
+func TestRedisCacheExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := &Config{Retention: 100 * time.Millisecond, SweepInterval: 100 * time.Millisecond}
+	c, mr := newTestRedisCache(t, conf)
+
+	// sweep interval is made longer than retention after validation, so only expiry notification can trigger the sweep
+	conf.SweepInterval = time.Hour
+
+	expired := make(chan []Event, 1)
+	c.OnEvents(func(events []Event) {
+		if events[0].Type == EventObjectExpired {
+			expired <- events
+		}
+	})
+
+	_, _, err := c.InsertObjectsOrUpdate(ctx, 0, []int32{1}, nil)
+	require.NoError(t, err)
+
+	go c.DeleteNotSeenObjects(ctx)
+
+	// miniredis doesn't send keyspace notifications, so expiry is simulated
+	time.Sleep(150 * time.Millisecond)
+	mr.FastForward(time.Minute)
+	require.Eventually(t, func() bool {
+		return mr.Publish("__keyevent@0__:expired", "bitburst:object:1") > 0
+	}, time.Second, 10*time.Millisecond, "expiry notifications aren't watched")
+
+	select {
+	case events := <-expired:
+		require.Len(t, events, 1)
+		assert.Equal(t, int32(1), events[0].ObjectID)
+	case <-time.After(time.Second):
+		t.Fatal("object wasn't deleted after it's status expired")
+	}
+
+	_, err = c.GetObject(ctx, 1)
+	assert.Equal(t, ErrObjectNotFound, err)
+}
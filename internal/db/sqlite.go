@@ -0,0 +1,779 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"bitburst-assessment-task/internal/metrics"
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	sqlitemigrations "bitburst-assessment-task/internal/db/migrations/sqlite"
+
+	migrate "github.com/golang-migrate/migrate/v4"
+	bindata "github.com/golang-migrate/migrate/v4/source/go_bindata"
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gopkg.in/guregu/null.v4/zero"
+
+	// pure Go sqlite driver, so app can still be built with CGO_ENABLED=0
+	_ "modernc.org/sqlite"
+)
+
+// SQLite is a storage backed by embedded SQLite database file, it's intended for single node deployments.
+// Schema mirrors Postgres migrations, timestamps are stored as unix time in microseconds and arrays as json.
+type SQLite struct {
+	sqlDB *sql.DB
+
+	// emitter notifies listeners about object events
+	emitter
+
+	logger *zerolog.Logger
+	conf   *Config
+}
+
+// NewSQLite opens SQLite database file and migrates it's schemas, file is created if it doesn't exist
+func NewSQLite(conf *Config, logger *zerolog.Logger) (*SQLite, error) {
+	if err := conf.validate(); err != nil {
+		return nil, errors.WithMessage(err, "invalid configuration")
+	}
+	if conf.Path == "" {
+		return nil, errors.New("invalid configuration: path of sqlite database file must be set")
+	}
+
+	s := &SQLite{
+		logger: logger,
+		conf:   conf,
+	}
+
+	sqlDB, err := sql.Open("sqlite", conf.Path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to open database")
+	}
+	// sqlite allows only one writer at a time, so one connection avoids "database is locked" errors,
+	// it also keeps transactions serialized, so statuses read in transaction can't go stale
+	sqlDB.SetMaxOpenConns(1)
+	s.sqlDB = sqlDB
+
+	if err := s.sqlDB.Ping(); err != nil {
+		_ = s.sqlDB.Close()
+		return nil, errors.WithMessage(err, "failed to ping database")
+	}
+
+	if err := s.migrate(conf.MigrationVersion); err != nil {
+		_ = s.sqlDB.Close()
+		return nil, errors.WithMessage(err, "failed to migrate database schemas")
+	}
+
+	return s, nil
+}
+
+// migrate migrates database schemas to specified version
+func (s *SQLite) migrate(version int) error {
+	sourceInstance, err := bindata.WithInstance(bindata.Resource(sqlitemigrations.AssetNames(), sqlitemigrations.Asset))
+	if err != nil {
+		return errors.WithMessage(err, "failed to get migrations schemas")
+	}
+
+	targetInstance, err := newSQLiteMigrationDriver(s.sqlDB)
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithInstance("go-bindata", sourceInstance, "sqlite", targetInstance)
+	if err != nil {
+		return err
+	}
+
+	err = m.Migrate(uint(version))
+	if err != nil && err != migrate.ErrNoChange {
+		return errors.WithMessagef(err, "failed to migrate to version: %d", version)
+	}
+
+	return sourceInstance.Close()
+}
+
+// toMicros converts time to unix time in microseconds
+func toMicros(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+// fromMicros converts unix time in microseconds to time
+func fromMicros(us int64) time.Time {
+	return time.Unix(0, us*int64(time.Microsecond))
+}
+
+// Ping checks if database is reachable
+func (s *SQLite) Ping(ctx context.Context) error {
+	return errors.WithMessage(s.sqlDB.PingContext(ctx), "failed to ping database")
+}
+
+// CheckMigrations checks if database schemas are migrated to configured version and last migration didn't fail,
+// it returns current migration version
+func (s *SQLite) CheckMigrations(ctx context.Context) (version int, err error) {
+	var dirty bool
+	err = s.sqlDB.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to get migration version")
+	}
+
+	return version, s.conf.checkMigrationVersion(version, dirty)
+}
+
+// Close closes database file
+func (s *SQLite) Close() error {
+	return errors.WithMessage(s.sqlDB.Close(), "failed to close database")
+}
+
+// withTx runs fn in transaction, transaction is committed if fn succeeds and rolled back otherwise
+func (s *SQLite) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithMessage(err, "failed to begin transaction")
+	}
+
+	if err := fn(tx); err != nil {
+		if terr := tx.Rollback(); terr != nil {
+			s.logger.Warn().Err(terr).Msg("failed to rollback transaction")
+		}
+		return err
+	}
+
+	return errors.WithMessage(tx.Commit(), "failed to commit transaction")
+}
+
+// InsertObjectsOrUpdate behaves the same as DB.InsertObjectsOrUpdate
+func (s *SQLite) InsertObjectsOrUpdate(ctx context.Context, callbackID int64, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.DBQueryDuration.WithLabelValues("insert_objects_or_update").Observe(time.Since(start).Seconds())
+	}()
+
+	var ts []Transition
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		now := toMicros(time.Now())
+
+		allIDs := make([]int32, 0, len(onlineIDs)+len(offlineIDs))
+		allIDs = append(append(allIDs, onlineIDs...), offlineIDs...)
+		before, err := sqliteObjectsStatus(ctx, tx, allIDs)
+		if err != nil {
+			return err
+		}
+
+		upsert, err := tx.PrepareContext(ctx, `INSERT INTO objects ( o_id, online, last_seen ) VALUES ( ?, ?, ? )
+			ON CONFLICT ( o_id ) DO UPDATE SET online = excluded.online, last_seen = excluded.last_seen`)
+		if err != nil {
+			return errors.WithMessage(err, "failed to prepare upsert")
+		}
+		defer upsert.Close()
+
+		if s.conf.LegacyUpsert {
+			for _, id := range onlineIDs {
+				if _, err := upsert.ExecContext(ctx, id, true, now); err != nil {
+					return errors.WithMessage(err, "failed to insert/update objects")
+				}
+				insertedIDs = append(insertedIDs, id)
+			}
+			for _, id := range offlineIDs {
+				res, err := tx.ExecContext(ctx, `UPDATE objects SET online = FALSE WHERE o_id = ?`, id)
+				if err != nil {
+					return errors.WithMessage(err, "failed to update objects")
+				}
+				if n, _ := res.RowsAffected(); n > 0 {
+					updatedIDs = append(updatedIDs, id)
+				}
+			}
+		} else {
+			add := func(id int32, online bool) error {
+				if _, err := upsert.ExecContext(ctx, id, online, now); err != nil {
+					return errors.WithMessage(err, "failed to upsert objects")
+				}
+				if _, ok := before[id]; ok {
+					updatedIDs = append(updatedIDs, id)
+				} else {
+					insertedIDs = append(insertedIDs, id)
+				}
+				return nil
+			}
+			for _, id := range onlineIDs {
+				if err := add(id, true); err != nil {
+					return err
+				}
+			}
+			for _, id := range offlineIDs {
+				if err := add(id, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		ts = transitions(before, onlineIDs, offlineIDs, s.conf.LegacyUpsert)
+		for _, t := range ts {
+			_, err := tx.ExecContext(ctx, `INSERT INTO object_status_history ( o_id, old_online, new_online, observed_at, callback_id ) VALUES ( ?, ?, ?, ?, ? )`,
+				t.ObjectID,
+				sql.NullBool{Bool: t.OldOnline != nil && *t.OldOnline, Valid: t.OldOnline != nil},
+				t.NewOnline,
+				now,
+				sql.NullInt64{Int64: callbackID, Valid: callbackID != 0},
+			)
+			if err != nil {
+				return errors.WithMessage(err, "failed to insert status history")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metrics.DBObjects.WithLabelValues("inserted").Add(float64(len(insertedIDs)))
+	metrics.DBObjects.WithLabelValues("updated").Add(float64(len(updatedIDs)))
+
+	s.emit(transitionEvents(ts, time.Now()))
+
+	return insertedIDs, updatedIDs, nil
+}
+
+// sqliteObjectsStatus returns online statuses of stored objects with given ids
+func sqliteObjectsStatus(ctx context.Context, tx *sql.Tx, ids []int32) (map[int32]bool, error) {
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encode ids")
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT o_id, online FROM objects WHERE o_id IN ( SELECT value FROM json_each(?) )`, string(idsJSON))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get objects status")
+	}
+	defer rows.Close()
+
+	statuses := make(map[int32]bool, len(ids))
+	for rows.Next() {
+		var (
+			id     int32
+			online bool
+		)
+		if err := rows.Scan(&id, &online); err != nil {
+			return nil, errors.WithMessage(err, "failed to scan objects status")
+		}
+		statuses[id] = online
+	}
+
+	return statuses, errors.WithMessage(rows.Err(), "failed to get objects status")
+}
+
+// DeleteNotSeenObjects deletes objects that weren't seen during retention window every sweep interval,
+// it's to run in background. When a job is done on behalf of this function, context should be canceled.
+func (s *SQLite) DeleteNotSeenObjects(ctx context.Context) {
+	runSweeper(ctx, s.conf.SweepInterval, nil, s.SweepNotSeenObjects, s.logger)
+}
+
+// SweepNotSeenObjects runs one sweep of objects that weren't seen during retention window,
+// and returns ids of deleted objects
+func (s *SQLite) SweepNotSeenObjects(ctx context.Context) (deletedIDs []int32, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.DBQueryDuration.WithLabelValues("delete_not_seen_objects").Observe(time.Since(start).Seconds())
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+
+		rows, err := tx.QueryContext(ctx, `DELETE FROM objects WHERE last_seen < ? RETURNING o_id, online`, toMicros(now.Add(-s.conf.Retention)))
+		if err != nil {
+			return errors.WithMessage(err, "failed to delete not seen objects")
+		}
+		defer rows.Close()
+
+		deleted := make(map[int32]bool)
+		for rows.Next() {
+			var (
+				id     int32
+				online bool
+			)
+			if err := rows.Scan(&id, &online); err != nil {
+				return errors.WithMessage(err, "failed to scan deleted object id")
+			}
+			deletedIDs = append(deletedIDs, id)
+			deleted[id] = online
+		}
+		if err := rows.Err(); err != nil {
+			return errors.WithMessage(err, "failed to delete not seen objects")
+		}
+
+		// record that deleted objects went away, so they don't count as online in uptime anymore
+		for _, t := range expiredTransitions(deleted) {
+			_, err := tx.ExecContext(ctx, `INSERT INTO object_status_history ( o_id, old_online, new_online, observed_at, callback_id ) VALUES ( ?, ?, ?, ?, NULL )`,
+				t.ObjectID, *t.OldOnline, t.NewOnline, toMicros(now))
+			if err != nil {
+				return errors.WithMessage(err, "failed to insert status history")
+			}
+		}
+
+		if s.conf.HistoryRetention <= 0 {
+			return nil
+		}
+
+		// the latest change before the cutoff is kept for objects that still exist, so their status at the start of a window is known
+		before := toMicros(now.Add(-s.conf.HistoryRetention))
+		res, err := tx.ExecContext(ctx, `DELETE FROM object_status_history AS h
+			WHERE h.observed_at < ?
+				AND (
+					NOT EXISTS ( SELECT 1 FROM objects AS o WHERE o.o_id = h.o_id )
+					OR EXISTS (
+						SELECT 1 FROM object_status_history AS n
+						WHERE n.o_id = h.o_id AND n.observed_at < ?
+							AND ( n.observed_at > h.observed_at OR ( n.observed_at = h.observed_at AND n.h_id > h.h_id ) )
+					)
+				)`, before, before)
+		if err != nil {
+			return errors.WithMessage(err, "failed to prune status history")
+		}
+		if pruned, err := res.RowsAffected(); err == nil {
+			s.logger.Debug().Int64("count", pruned).Msg("pruned status history")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.SweepDeletedObjects.Observe(float64(len(deletedIDs)))
+	metrics.DBObjects.WithLabelValues("deleted").Add(float64(len(deletedIDs)))
+
+	s.emit(expiredEvents(deletedIDs, time.Now()))
+
+	return deletedIDs, nil
+}
+
+// scanSQLiteObject scans object row
+func scanSQLiteObject(scan func(dest ...interface{}) error) (objects.BitburstObject, error) {
+	var (
+		obj      objects.BitburstObject
+		lastSeen sql.NullInt64
+	)
+	if err := scan(&obj.OID, &obj.Online, &lastSeen); err != nil {
+		return obj, err
+	}
+	if lastSeen.Valid {
+		obj.LastSeen = zero.TimeFrom(fromMicros(lastSeen.Int64))
+	}
+
+	return obj, nil
+}
+
+// GetObject returns object by it's id, or ErrObjectNotFound if it doesn't exist
+func (s *SQLite) GetObject(ctx context.Context, id int32) (*objects.BitburstObject, error) {
+	obj, err := scanSQLiteObject(s.sqlDB.QueryRowContext(ctx, `SELECT o_id, online, last_seen FROM objects WHERE o_id = ?`, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, errors.WithMessagef(err, "failed to get object %d", id)
+	}
+
+	return &obj, nil
+}
+
+// ListObjects returns objects that match the filter ordered by id
+func (s *SQLite) ListObjects(ctx context.Context, filter *ListObjectsFilter) ([]objects.BitburstObject, error) {
+	var (
+		afterID                           int32 = math.MinInt32
+		online                            bool
+		seenAfter, seenBefore             int64
+		filterSeenAfter, filterSeenBefore bool
+	)
+	if filter.After != nil {
+		afterID = *filter.After
+	}
+	if filter.Online != nil {
+		online = *filter.Online
+	}
+	if filter.SeenAfter != nil {
+		filterSeenAfter, seenAfter = true, toMicros(*filter.SeenAfter)
+	}
+	if filter.SeenBefore != nil {
+		filterSeenBefore, seenBefore = true, toMicros(*filter.SeenBefore)
+	}
+
+	rows, err := s.sqlDB.QueryContext(ctx, `SELECT o_id, online, last_seen FROM objects
+		WHERE o_id > ?
+			AND ( NOT ? OR online = ? )
+			AND ( NOT ? OR last_seen >= ? )
+			AND ( NOT ? OR last_seen < ? )
+		ORDER BY o_id
+		LIMIT ?`,
+		afterID, filter.Online != nil, online, filterSeenAfter, seenAfter, filterSeenBefore, seenBefore, filter.Limit)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list objects")
+	}
+	defer rows.Close()
+
+	objs := make([]objects.BitburstObject, 0)
+	for rows.Next() {
+		obj, err := scanSQLiteObject(rows.Scan)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to scan object")
+		}
+		objs = append(objs, obj)
+	}
+
+	return objs, errors.WithMessage(rows.Err(), "failed to list objects")
+}
+
+// ObjectTimeline returns at most limit status changes of an object that were observed since given time, oldest first
+func (s *SQLite) ObjectTimeline(ctx context.Context, id int32, since time.Time, limit int32) ([]*StatusChange, error) {
+	rows, err := s.sqlDB.QueryContext(ctx, `SELECT h_id, o_id, old_online, new_online, observed_at, callback_id FROM object_status_history
+		WHERE o_id = ? AND observed_at >= ?
+		ORDER BY observed_at, h_id
+		LIMIT ?`, id, toMicros(since), limit)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to get timeline of object %d", id)
+	}
+	defer rows.Close()
+
+	changes := make([]*StatusChange, 0)
+	for rows.Next() {
+		var (
+			change     StatusChange
+			oldOnline  sql.NullBool
+			observedAt int64
+			callbackID sql.NullInt64
+		)
+		if err := rows.Scan(&change.ID, &change.ObjectID, &oldOnline, &change.NewOnline, &observedAt, &callbackID); err != nil {
+			return nil, errors.WithMessage(err, "failed to scan status change")
+		}
+		change.ObservedAt = fromMicros(observedAt)
+		if oldOnline.Valid {
+			change.OldOnline = &oldOnline.Bool
+		}
+		if callbackID.Valid {
+			change.CallbackID = &callbackID.Int64
+		}
+		changes = append(changes, &change)
+	}
+
+	return changes, errors.WithMessagef(rows.Err(), "failed to get timeline of object %d", id)
+}
+
+// Uptime returns uptime percentage of every object that has status history during the window ending now
+func (s *SQLite) Uptime(ctx context.Context, window time.Duration) ([]*Uptime, error) {
+	to := time.Now()
+	from := to.Add(-window)
+
+	before := make(map[int32]bool)
+	changes := make([]statusSample, 0)
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// sqlite doesn't have DISTINCT ON, so last status of every object is picked with a window function
+		rows, err := tx.QueryContext(ctx, `SELECT o_id, new_online FROM (
+				SELECT o_id, new_online, ROW_NUMBER() OVER ( PARTITION BY o_id ORDER BY observed_at DESC, h_id DESC ) AS rn
+				FROM object_status_history
+				WHERE observed_at < ?
+			) WHERE rn = 1`, toMicros(from))
+		if err != nil {
+			return errors.WithMessage(err, "failed to get statuses before window")
+		}
+		for rows.Next() {
+			var (
+				id     int32
+				online bool
+			)
+			if err := rows.Scan(&id, &online); err != nil {
+				rows.Close()
+				return errors.WithMessage(err, "failed to scan status")
+			}
+			before[id] = online
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return errors.WithMessage(err, "failed to get statuses before window")
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT o_id, new_online, observed_at FROM object_status_history
+			WHERE observed_at >= ?
+			ORDER BY o_id, observed_at, h_id`, toMicros(from))
+		if err != nil {
+			return errors.WithMessage(err, "failed to get status history")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				sample     statusSample
+				observedAt int64
+			)
+			if err := rows.Scan(&sample.objectID, &sample.online, &observedAt); err != nil {
+				return errors.WithMessage(err, "failed to scan status")
+			}
+			sample.at = fromMicros(observedAt)
+			changes = append(changes, sample)
+		}
+
+		return errors.WithMessage(rows.Err(), "failed to get status history")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return computeUptime(before, changes, from, to), nil
+}
+
+// SaveCallback persists received object ids in the callbacks inbox and returns id of the inbox entry
+func (s *SQLite) SaveCallback(ctx context.Context, objectIDs []int32) (int64, error) {
+	idsJSON, err := json.Marshal(objectIDs)
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to encode object ids")
+	}
+
+	res, err := s.sqlDB.ExecContext(ctx, `INSERT INTO callbacks ( object_ids, received_at ) VALUES ( ?, ? )`, string(idsJSON), toMicros(time.Now()))
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to insert callback")
+	}
+
+	id, err := res.LastInsertId()
+	return id, errors.WithMessage(err, "failed to get id of inserted callback")
+}
+
+// MarkCallbackProcessed marks inbox entry as done, so it won't be replayed again
+func (s *SQLite) MarkCallbackProcessed(ctx context.Context, id int64) error {
+	_, err := s.sqlDB.ExecContext(ctx, `UPDATE callbacks SET processed_at = ? WHERE c_id = ?`, toMicros(time.Now()), id)
+
+	return errors.WithMessagef(err, "failed to mark callback %d as processed", id)
+}
+
+// UnprocessedCallbacks returns at most limit inbox entries that weren't marked as done
+// and that were received at least minAge ago, oldest entries come first
+func (s *SQLite) UnprocessedCallbacks(ctx context.Context, minAge time.Duration, limit int32) ([]*Callback, error) {
+	rows, err := s.sqlDB.QueryContext(ctx, `SELECT c_id, object_ids, received_at FROM callbacks
+		WHERE processed_at IS NULL AND received_at <= ?
+		ORDER BY c_id
+		LIMIT ?`, toMicros(time.Now().Add(-minAge)), limit)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get unprocessed callbacks")
+	}
+	defer rows.Close()
+
+	callbacks := make([]*Callback, 0)
+	for rows.Next() {
+		var (
+			c          Callback
+			idsJSON    string
+			receivedAt int64
+		)
+		if err := rows.Scan(&c.ID, &idsJSON, &receivedAt); err != nil {
+			return nil, errors.WithMessage(err, "failed to scan callback")
+		}
+		if err := json.Unmarshal([]byte(idsJSON), &c.ObjectIDs); err != nil {
+			return nil, errors.WithMessagef(err, "failed to decode object ids of callback %d", c.ID)
+		}
+		c.ReceivedAt = fromMicros(receivedAt)
+		callbacks = append(callbacks, &c)
+	}
+
+	return callbacks, errors.WithMessage(rows.Err(), "failed to get unprocessed callbacks")
+}
+
+// DeleteProcessedCallbacks deletes inbox entries that were processed more than retention ago,
+// and returns amount of deleted entries
+func (s *SQLite) DeleteProcessedCallbacks(ctx context.Context, retention time.Duration) (int64, error) {
+	res, err := s.sqlDB.ExecContext(ctx, `DELETE FROM callbacks WHERE processed_at < ?`, toMicros(time.Now().Add(-retention)))
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to delete processed callbacks")
+	}
+
+	n, err := res.RowsAffected()
+	return n, errors.WithMessage(err, "failed to delete processed callbacks")
+}
+
+// SaveCallbackOnce behaves the same as DB.SaveCallbackOnce
+func (s *SQLite) SaveCallbackOnce(ctx context.Context, key *IdempotencyKey, objectIDs []int32) (id int64, duplicate bool, err error) {
+	idsJSON, err := json.Marshal(objectIDs)
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to encode object ids")
+	}
+
+	// connection is exclusive, so concurrent callbacks with the same key can't interleave
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+
+		// expired key can be used again
+		if _, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = ? AND expires_at <= ?`, key.Key, toMicros(now)); err != nil {
+			return errors.WithMessage(err, "failed to delete expired idempotency key")
+		}
+
+		var (
+			fingerprint string
+			callbackID  int64
+		)
+		err := tx.QueryRowContext(ctx, `SELECT fingerprint, callback_id FROM idempotency_keys WHERE key = ?`, key.Key).Scan(&fingerprint, &callbackID)
+		switch {
+		case err == nil:
+			id, duplicate, err = originalCallback(key, fingerprint, callbackID)
+			return err
+		case err != sql.ErrNoRows:
+			return errors.WithMessage(err, "failed to get idempotency key")
+		}
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO callbacks ( object_ids, received_at ) VALUES ( ?, ? )`, string(idsJSON), toMicros(now))
+		if err != nil {
+			return errors.WithMessage(err, "failed to insert callback")
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return errors.WithMessage(err, "failed to get id of inserted callback")
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO idempotency_keys ( key, fingerprint, callback_id, created_at, expires_at ) VALUES ( ?, ?, ?, ?, ? )`,
+			key.Key, key.Fingerprint, id, toMicros(now), toMicros(now.Add(key.TTL)))
+		return errors.WithMessage(err, "failed to insert idempotency key")
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, duplicate, nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes idempotency keys which TTL passed,
+// and returns amount of deleted keys
+func (s *SQLite) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	res, err := s.sqlDB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, toMicros(time.Now()))
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to delete expired idempotency keys")
+	}
+
+	n, err := res.RowsAffected()
+	return n, errors.WithMessage(err, "failed to delete expired idempotency keys")
+}
+
+// CreateWebhookSubscription stores a new webhook subscription
+func (s *SQLite) CreateWebhookSubscription(ctx context.Context, url, secret string, events []EventType) (*WebhookSubscription, error) {
+	sub := &WebhookSubscription{
+		URL:       url,
+		Secret:    secret,
+		Events:    append(make([]EventType, 0, len(events)), events...),
+		CreatedAt: fromMicros(toMicros(time.Now())),
+	}
+
+	eventsJSON, err := json.Marshal(sub.Events)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encode events")
+	}
+
+	res, err := s.sqlDB.ExecContext(ctx, `INSERT INTO webhook_subscriptions ( url, secret, events, created_at ) VALUES ( ?, ?, ?, ? )`,
+		url, secret, string(eventsJSON), toMicros(sub.CreatedAt))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to insert webhook subscription")
+	}
+
+	sub.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get id of inserted webhook subscription")
+	}
+
+	return sub, nil
+}
+
+// WebhookSubscriptions returns all webhook subscriptions
+func (s *SQLite) WebhookSubscriptions(ctx context.Context) ([]*WebhookSubscription, error) {
+	rows, err := s.sqlDB.QueryContext(ctx, `SELECT w_id, url, secret, events, created_at FROM webhook_subscriptions ORDER BY w_id`)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list webhook subscriptions")
+	}
+	defer rows.Close()
+
+	subs := make([]*WebhookSubscription, 0)
+	for rows.Next() {
+		var (
+			sub        WebhookSubscription
+			eventsJSON string
+			createdAt  int64
+		)
+		if err := rows.Scan(&sub.ID, &sub.URL, &sub.Secret, &eventsJSON, &createdAt); err != nil {
+			return nil, errors.WithMessage(err, "failed to scan webhook subscription")
+		}
+		if err := json.Unmarshal([]byte(eventsJSON), &sub.Events); err != nil {
+			return nil, errors.WithMessagef(err, "failed to decode events of webhook subscription %d", sub.ID)
+		}
+		sub.CreatedAt = fromMicros(createdAt)
+		subs = append(subs, &sub)
+	}
+
+	return subs, errors.WithMessage(rows.Err(), "failed to list webhook subscriptions")
+}
+
+// DeleteWebhookSubscription deletes webhook subscription with it's dead letters,
+// or returns ErrWebhookSubscriptionNotFound if it doesn't exist
+func (s *SQLite) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		// foreign keys aren't enforced by sqlite unless enabled for every connection, so cascade is done here
+		if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_dead_letters WHERE w_id = ?`, id); err != nil {
+			return errors.WithMessagef(err, "failed to delete dead letters of webhook subscription %d", id)
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE w_id = ?`, id)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to delete webhook subscription %d", id)
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return errors.WithMessagef(err, "failed to delete webhook subscription %d", id)
+		} else if n == 0 {
+			return ErrWebhookSubscriptionNotFound
+		}
+
+		return nil
+	})
+}
+
+// SaveWebhookDeadLetter stores a webhook delivery that failed after all retries
+func (s *SQLite) SaveWebhookDeadLetter(ctx context.Context, subscriptionID int64, payload []byte, attempts int, lastErr error) error {
+	res, err := s.sqlDB.ExecContext(ctx, `INSERT INTO webhook_dead_letters ( w_id, payload, attempts, last_error, failed_at )
+		SELECT ?, ?, ?, ?, ?
+		WHERE EXISTS ( SELECT 1 FROM webhook_subscriptions WHERE w_id = ? )`,
+		subscriptionID, string(payload), attempts, lastErr.Error(), toMicros(time.Now()), subscriptionID)
+	if err != nil {
+		return errors.WithMessage(err, "failed to insert webhook dead letter")
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.WithMessage(err, "failed to insert webhook dead letter")
+	} else if n == 0 {
+		return errors.WithMessage(ErrWebhookSubscriptionNotFound, "failed to insert webhook dead letter")
+	}
+
+	return nil
+}
+
+// WebhookDeadLetters returns at most limit dead letters with id greater than after, oldest first
+func (s *SQLite) WebhookDeadLetters(ctx context.Context, after int64, limit int32) ([]*WebhookDeadLetter, error) {
+	rows, err := s.sqlDB.QueryContext(ctx, `SELECT d_id, w_id, payload, attempts, last_error, failed_at FROM webhook_dead_letters
+		WHERE d_id > ?
+		ORDER BY d_id
+		LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list webhook dead letters")
+	}
+	defer rows.Close()
+
+	letters := make([]*WebhookDeadLetter, 0)
+	for rows.Next() {
+		var (
+			dl       WebhookDeadLetter
+			payload  string
+			failedAt int64
+		)
+		if err := rows.Scan(&dl.ID, &dl.SubscriptionID, &payload, &dl.Attempts, &dl.LastError, &failedAt); err != nil {
+			return nil, errors.WithMessage(err, "failed to scan webhook dead letter")
+		}
+		dl.Payload = []byte(payload)
+		dl.FailedAt = fromMicros(failedAt)
+		letters = append(letters, &dl)
+	}
+
+	return letters, errors.WithMessage(rows.Err(), "failed to list webhook dead letters")
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/pkg/errors"
+)
+
+// sqliteMigrationDriver lets golang-migrate apply migrations to SQLite database,
+// migrate v4.14 only ships a cgo based driver, so app would lose it's static build with it
+type sqliteMigrationDriver struct {
+	sqlDB *sql.DB
+}
+
+// newSQLiteMigrationDriver constructs migration driver and creates migrations table
+func newSQLiteMigrationDriver(sqlDB *sql.DB) (*sqliteMigrationDriver, error) {
+	_, err := sqlDB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations ( version INTEGER NOT NULL, dirty BOOLEAN NOT NULL )`)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create migrations table")
+	}
+
+	return &sqliteMigrationDriver{sqlDB: sqlDB}, nil
+}
+
+// Open isn't supported, driver is only constructed from an existing connection
+func (d *sqliteMigrationDriver) Open(url string) (database.Driver, error) {
+	return nil, errors.New("sqlite migration driver can't be opened from url")
+}
+
+// Close does nothing, connection is owned by storage
+func (d *sqliteMigrationDriver) Close() error {
+	return nil
+}
+
+// Lock does nothing, sqlite storage is used by a single process
+func (d *sqliteMigrationDriver) Lock() error {
+	return nil
+}
+
+// Unlock does nothing, sqlite storage is used by a single process
+func (d *sqliteMigrationDriver) Unlock() error {
+	return nil
+}
+
+// Run applies migration
+func (d *sqliteMigrationDriver) Run(migration io.Reader) error {
+	query, err := ioutil.ReadAll(migration)
+	if err != nil {
+		return errors.WithMessage(err, "failed to read migration")
+	}
+
+	if _, err := d.sqlDB.Exec(string(query)); err != nil {
+		return errors.WithMessage(err, "failed to run migration")
+	}
+
+	return nil
+}
+
+// SetVersion stores current migration version
+func (d *sqliteMigrationDriver) SetVersion(version int, dirty bool) (err error) {
+	tx, err := d.sqlDB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return errors.WithMessage(err, "failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM schema_migrations`); err != nil {
+		return errors.WithMessage(err, "failed to delete migration version")
+	}
+
+	// same as other golang-migrate drivers, nil version is only stored when it's dirty
+	if version >= 0 || (version == database.NilVersion && dirty) {
+		if _, err := tx.Exec(`INSERT INTO schema_migrations ( version, dirty ) VALUES ( ?, ? )`, version, dirty); err != nil {
+			return errors.WithMessage(err, "failed to insert migration version")
+		}
+	}
+
+	return errors.WithMessage(tx.Commit(), "failed to commit transaction")
+}
+
+// Version returns current migration version, or database.NilVersion if there were no migrations
+func (d *sqliteMigrationDriver) Version() (version int, dirty bool, err error) {
+	err = d.sqlDB.QueryRow(`SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return database.NilVersion, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.WithMessage(err, "failed to get migration version")
+	}
+
+	return version, dirty, nil
+}
+
+// Drop deletes all tables
+func (d *sqliteMigrationDriver) Drop() error {
+	rows, err := d.sqlDB.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return errors.WithMessage(err, "failed to list tables")
+	}
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return errors.WithMessage(err, "failed to scan table name")
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return errors.WithMessage(err, "failed to list tables")
+	}
+
+	for _, name := range tables {
+		if _, err := d.sqlDB.Exec(`DROP TABLE IF EXISTS "` + name + `"`); err != nil {
+			return errors.WithMessagef(err, "failed to drop table %s", name)
+		}
+	}
+
+	return nil
+}
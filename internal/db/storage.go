@@ -0,0 +1,89 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Storage holds objects with their status history, callbacks inbox and webhook subscriptions.
+// It's implemented by Postgres database, embedded SQLite database and in-memory storage.
+type Storage interface {
+	// Ping checks if storage is reachable
+	Ping(ctx context.Context) error
+
+	// CheckMigrations checks if storage schemas are migrated to configured version and returns current version,
+	// storages without schemas return 0
+	CheckMigrations(ctx context.Context) (int, error)
+
+	// Close releases resources held by storage
+	Close() error
+
+	// OnEvents registers a listener that is called with object events after they are committed to storage
+	OnEvents(listener func(events []Event))
+
+	// objects
+	InsertObjectsOrUpdate(ctx context.Context, callbackID int64, onlineIDs []int32, offlineIDs []int32) (insertedIDs []int32, updatedIDs []int32, err error)
+	SweepNotSeenObjects(ctx context.Context) (deletedIDs []int32, err error)
+
+	// DeleteNotSeenObjects sweeps objects until context is canceled, it returns when the sweep in progress is finished
+	DeleteNotSeenObjects(ctx context.Context)
+	GetObject(ctx context.Context, id int32) (*objects.BitburstObject, error)
+	ListObjects(ctx context.Context, filter *ListObjectsFilter) ([]objects.BitburstObject, error)
+
+	// objects status history
+	ObjectTimeline(ctx context.Context, id int32, since time.Time, limit int32) ([]*StatusChange, error)
+	Uptime(ctx context.Context, window time.Duration) ([]*Uptime, error)
+
+	// callbacks inbox
+	SaveCallback(ctx context.Context, objectIDs []int32) (int64, error)
+	MarkCallbackProcessed(ctx context.Context, id int64) error
+	UnprocessedCallbacks(ctx context.Context, minAge time.Duration, limit int32) ([]*Callback, error)
+	DeleteProcessedCallbacks(ctx context.Context, retention time.Duration) (int64, error)
+
+	// idempotency keys of callbacks
+	SaveCallbackOnce(ctx context.Context, key *IdempotencyKey, objectIDs []int32) (id int64, duplicate bool, err error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+
+	// webhooks
+	CreateWebhookSubscription(ctx context.Context, url, secret string, events []EventType) (*WebhookSubscription, error)
+	WebhookSubscriptions(ctx context.Context) ([]*WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id int64) error
+	SaveWebhookDeadLetter(ctx context.Context, subscriptionID int64, payload []byte, attempts int, lastErr error) error
+	WebhookDeadLetters(ctx context.Context, after int64, limit int32) ([]*WebhookDeadLetter, error)
+}
+
+var (
+	_ Storage = (*DB)(nil)
+	_ Storage = (*SQLite)(nil)
+	_ Storage = (*Memory)(nil)
+)
+
+// runSweeper calls sweep every interval and whenever trigger fires until context is canceled,
+// trigger can be nil. It returns after the sweep in progress is finished, so storage can be closed then.
+func runSweeper(ctx context.Context, interval time.Duration, trigger <-chan struct{}, sweep func(ctx context.Context) ([]int32, error), logger *zerolog.Logger) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	subLogger := logger.With().Str("func", "DeleteNotSeenObjects").Logger()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		case <-trigger:
+		}
+
+		// sweep isn't bound to ctx, so shutdown doesn't cut it off in the middle of transaction,
+		// it's limited by timeout of the sweep instead
+		deletedIDs, err := sweep(context.Background())
+		if err != nil {
+			subLogger.Warn().Err(err).Msg("failed to delete not seen objects")
+			continue
+		}
+
+		subLogger.Info().Ints32("ids", deletedIDs).Msg("successfully deleted objects from storage")
+	}
+}
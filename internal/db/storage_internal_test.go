@@ -0,0 +1,332 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ignoreReceivedAt ignores time callbacks were received at when callbacks are compared
+var ignoreReceivedAt = cmpopts.IgnoreFields(Callback{}, "ReceivedAt")
+
+// testStorage runs conformance tests against storage constructed by newStorage,
+// every implementation of Storage must pass them
+func testStorage(t *testing.T, newStorage func(t *testing.T, conf *Config) Storage) {
+	ctx := context.Background()
+
+	t.Run("objects", func(t *testing.T) {
+		s := newStorage(t, &Config{Retention: time.Second, SweepInterval: time.Second})
+
+		var events []Event
+		s.OnEvents(func(e []Event) { events = append(events, e...) })
+
+		inserted, updated, err := s.InsertObjectsOrUpdate(ctx, 0, []int32{1, 2}, []int32{3})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{1, 2, 3}, inserted)
+		assert.Empty(t, updated)
+
+		inserted, updated, err = s.InsertObjectsOrUpdate(ctx, 0, []int32{3}, []int32{1})
+		require.NoError(t, err)
+		assert.Empty(t, inserted)
+		assert.ElementsMatch(t, []int32{1, 3}, updated)
+
+		obj, err := s.GetObject(ctx, 1)
+		require.NoError(t, err)
+		assert.False(t, obj.Online)
+
+		_, err = s.GetObject(ctx, 4)
+		assert.Equal(t, ErrObjectNotFound, err)
+
+		online, after := true, int32(1)
+		objs, err := s.ListObjects(ctx, &ListObjectsFilter{Online: &online, After: &after, Limit: 10})
+		require.NoError(t, err)
+		ids := make([]int32, 0, len(objs))
+		for _, obj := range objs {
+			ids = append(ids, obj.OID)
+		}
+		assert.Equal(t, []int32{2, 3}, ids)
+
+		objs, err = s.ListObjects(ctx, &ListObjectsFilter{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, objs, 1)
+		assert.Equal(t, int32(1), objs[0].OID)
+
+		// only object 2 is seen after retention passes
+		time.Sleep(1100 * time.Millisecond)
+		_, _, err = s.InsertObjectsOrUpdate(ctx, 0, []int32{2}, nil)
+		require.NoError(t, err)
+
+		deleted, err := s.SweepNotSeenObjects(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{1, 3}, deleted)
+
+		got := make(map[EventType][]int32)
+		for _, e := range events {
+			got[e.Type] = append(got[e.Type], e.ObjectID)
+		}
+		want := map[EventType][]int32{
+			EventObjectOnline:  {1, 2, 3},
+			EventObjectOffline: {3, 1},
+			EventObjectExpired: {1, 3},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected events (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		s := newStorage(t, &Config{Retention: time.Minute, SweepInterval: time.Minute})
+
+		start := time.Now().Add(-time.Second)
+
+		_, _, err := s.InsertObjectsOrUpdate(ctx, 0, []int32{1}, nil)
+		require.NoError(t, err)
+		_, _, err = s.InsertObjectsOrUpdate(ctx, 0, []int32{1}, nil) // no change isn't recorded
+		require.NoError(t, err)
+
+		callbackID, err := s.SaveCallback(ctx, []int32{1})
+		require.NoError(t, err)
+		_, _, err = s.InsertObjectsOrUpdate(ctx, callbackID, nil, []int32{1})
+		require.NoError(t, err)
+
+		changes, err := s.ObjectTimeline(ctx, 1, start, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		assert.Nil(t, changes[0].OldOnline)
+		assert.True(t, changes[0].NewOnline)
+		assert.Nil(t, changes[0].CallbackID)
+		require.NotNil(t, changes[1].OldOnline)
+		assert.True(t, *changes[1].OldOnline)
+		assert.False(t, changes[1].NewOnline)
+		require.NotNil(t, changes[1].CallbackID)
+		assert.Equal(t, callbackID, *changes[1].CallbackID)
+
+		changes, err = s.ObjectTimeline(ctx, 1, start, 1)
+		require.NoError(t, err)
+		assert.Len(t, changes, 1)
+
+		uptimes, err := s.Uptime(ctx, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, uptimes, 1)
+		assert.Equal(t, int32(1), uptimes[0].ObjectID)
+	})
+
+	t.Run("history of expired objects", func(t *testing.T) {
+		s := newStorage(t, &Config{Retention: time.Second, SweepInterval: time.Second, HistoryRetention: time.Second})
+
+		start := time.Now().Add(-time.Second)
+
+		_, _, err := s.InsertObjectsOrUpdate(ctx, 0, []int32{2, 3}, nil)
+		require.NoError(t, err)
+		_, _, err = s.InsertObjectsOrUpdate(ctx, 0, nil, []int32{2})
+		require.NoError(t, err)
+
+		// only object 2 is seen after retention passes, changes recorded before are older than history retention
+		time.Sleep(1100 * time.Millisecond)
+		_, _, err = s.InsertObjectsOrUpdate(ctx, 0, []int32{2}, nil)
+		require.NoError(t, err)
+
+		deleted, err := s.SweepNotSeenObjects(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []int32{3}, deleted)
+
+		// expiry is recorded as a change to offline, and changes of deleted object before history retention are pruned
+		changes, err := s.ObjectTimeline(ctx, 3, start, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.NotNil(t, changes[0].OldOnline)
+		assert.True(t, *changes[0].OldOnline)
+		assert.False(t, changes[0].NewOnline)
+		assert.Nil(t, changes[0].CallbackID)
+
+		// the latest change before history retention is kept for existing object
+		changes, err = s.ObjectTimeline(ctx, 2, start, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		assert.False(t, changes[0].NewOnline)
+		assert.True(t, changes[1].NewOnline)
+
+		// expired object doesn't count as online anymore
+		time.Sleep(100 * time.Millisecond)
+		uptimes, err := s.Uptime(ctx, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, uptimes, 2)
+		assert.Equal(t, int32(3), uptimes[1].ObjectID)
+		assert.Less(t, uptimes[1].Percentage, float64(100))
+	})
+
+	t.Run("callbacks", func(t *testing.T) {
+		s := newStorage(t, &Config{Retention: time.Minute, SweepInterval: time.Minute})
+
+		first, err := s.SaveCallback(ctx, []int32{1, 2})
+		require.NoError(t, err)
+		second, err := s.SaveCallback(ctx, []int32{3})
+		require.NoError(t, err)
+
+		callbacks, err := s.UnprocessedCallbacks(ctx, 0, 10)
+		require.NoError(t, err)
+		want := []*Callback{{ID: first, ObjectIDs: []int32{1, 2}}, {ID: second, ObjectIDs: []int32{3}}}
+		if diff := cmp.Diff(want, callbacks, ignoreReceivedAt); diff != "" {
+			t.Errorf("unexpected callbacks (-want +got):\n%s", diff)
+		}
+		for _, c := range callbacks {
+			assert.WithinDuration(t, time.Now(), c.ReceivedAt, time.Minute, "callback must be returned with time it was received")
+		}
+
+		callbacks, err = s.UnprocessedCallbacks(ctx, time.Hour, 10)
+		require.NoError(t, err)
+		assert.Empty(t, callbacks, "callbacks younger than min age must not be returned")
+
+		require.NoError(t, s.MarkCallbackProcessed(ctx, first))
+		callbacks, err = s.UnprocessedCallbacks(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, callbacks, 1)
+		assert.Equal(t, second, callbacks[0].ID)
+
+		n, err := s.DeleteProcessedCallbacks(ctx, time.Hour)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		time.Sleep(10 * time.Millisecond)
+		n, err = s.DeleteProcessedCallbacks(ctx, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+
+	t.Run("idempotency keys", func(t *testing.T) {
+		s := newStorage(t, &Config{Retention: time.Minute, SweepInterval: time.Minute})
+
+		key := &IdempotencyKey{Key: "key", Fingerprint: "fingerprint", TTL: time.Hour}
+		id, duplicate, err := s.SaveCallbackOnce(ctx, key, []int32{1, 2})
+		require.NoError(t, err)
+		assert.False(t, duplicate)
+
+		dupID, duplicate, err := s.SaveCallbackOnce(ctx, key, []int32{1, 2})
+		require.NoError(t, err)
+		assert.True(t, duplicate)
+		assert.Equal(t, id, dupID, "duplicate must be acknowledged with id of the original callback")
+
+		_, _, err = s.SaveCallbackOnce(ctx, &IdempotencyKey{Key: "key", Fingerprint: "another", TTL: time.Hour}, []int32{3})
+		assert.Equal(t, ErrIdempotencyKeyReused, err)
+
+		callbacks, err := s.UnprocessedCallbacks(ctx, 0, 10)
+		require.NoError(t, err)
+		want := []*Callback{{ID: id, ObjectIDs: []int32{1, 2}}}
+		if diff := cmp.Diff(want, callbacks, ignoreReceivedAt); diff != "" {
+			t.Errorf("unexpected callbacks (-want +got):\n%s", diff)
+		}
+
+		// expired key can be used again
+		expiring := &IdempotencyKey{Key: "expiring", Fingerprint: "fingerprint", TTL: time.Millisecond}
+		first, _, err := s.SaveCallbackOnce(ctx, expiring, []int32{3})
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		second, duplicate, err := s.SaveCallbackOnce(ctx, expiring, []int32{3})
+		require.NoError(t, err)
+		assert.False(t, duplicate)
+		assert.NotEqual(t, first, second)
+
+		time.Sleep(10 * time.Millisecond)
+		n, err := s.DeleteExpiredIdempotencyKeys(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		s := newStorage(t, &Config{Retention: time.Minute, SweepInterval: time.Minute})
+
+		sub, err := s.CreateWebhookSubscription(ctx, "http://example.com", "secret", []EventType{EventObjectExpired})
+		require.NoError(t, err)
+		assert.Equal(t, "secret", sub.Secret)
+
+		subs, err := s.WebhookSubscriptions(ctx)
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		assert.Equal(t, sub.ID, subs[0].ID)
+		assert.Equal(t, []EventType{EventObjectExpired}, subs[0].Events)
+
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, s.SaveWebhookDeadLetter(ctx, sub.ID, []byte(`{"events":[]}`), i, errors.New("failed")))
+		}
+
+		letters, err := s.WebhookDeadLetters(ctx, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, letters, 2)
+		assert.Equal(t, int32(1), letters[0].Attempts)
+		assert.Equal(t, "failed", letters[0].LastError)
+
+		letters, err = s.WebhookDeadLetters(ctx, letters[1].ID, 2)
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+		assert.Equal(t, int32(3), letters[0].Attempts)
+
+		require.NoError(t, s.DeleteWebhookSubscription(ctx, sub.ID))
+		assert.Equal(t, ErrWebhookSubscriptionNotFound, s.DeleteWebhookSubscription(ctx, sub.ID))
+
+		letters, err = s.WebhookDeadLetters(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, letters, "dead letters must be deleted with subscription")
+	})
+}
+
+func TestMemoryStorage(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	testStorage(t, func(t *testing.T, conf *Config) Storage {
+		s, err := NewMemory(conf, &zlog)
+		require.NoError(t, err)
+
+		return s
+	})
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	testStorage(t, func(t *testing.T, conf *Config) Storage {
+		conf.Path = filepath.Join(t.TempDir(), "bitburst.db")
+		conf.MigrationVersion = 7
+
+		s, err := NewSQLite(conf, &zlog)
+		require.NoError(t, err, "failed to open database")
+		t.Cleanup(func() {
+			assert.NoError(t, s.Close(), "failed to close database")
+		})
+
+		version, err := s.CheckMigrations(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 7, version)
+
+		return s
+	})
+}
+
+func TestPostgresStorage(t *testing.T) {
+	zlog := log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: time.Stamp,
+	}).With().Logger().Level(zerolog.InfoLevel)
+
+	testStorage(t, func(t *testing.T, conf *Config) Storage {
+		dbConf := startDatabase(t, &zlog)
+		dbConf.Retention, dbConf.SweepInterval, dbConf.HistoryRetention = conf.Retention, conf.SweepInterval, conf.HistoryRetention
+
+		s, err := New(dbConf, &zlog)
+		require.NoError(t, err, "failed to establish connection")
+		t.Cleanup(func() {
+			assert.NoError(t, s.Close(), "failed to close connection")
+		})
+
+		return s
+	})
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"bitburst-assessment-task/internal/db/objects"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWebhookSubscriptionNotFound is returned when requested webhook subscription doesn't exist in database
+var ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// WebhookSubscription is a subscription of downstream service to object events
+type WebhookSubscription struct {
+	ID  int64  `json:"w_id"`
+	URL string `json:"url"`
+
+	// Secret is used to sign payloads, it's only revealed on creation
+	Secret string `json:"-"`
+
+	// Events are types of events subscription receives, empty means all events
+	Events    []EventType `json:"events"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Wants checks if subscription receives events of given type
+func (s *WebhookSubscription) Wants(t EventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDeadLetter is a webhook delivery that failed after all retries
+type WebhookDeadLetter struct {
+	ID             int64           `json:"d_id"`
+	SubscriptionID int64           `json:"w_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int32           `json:"attempts"`
+	LastError      string          `json:"last_error"`
+	FailedAt       time.Time       `json:"failed_at"`
+}
+
+// newWebhookSubscription converts database row to webhook subscription
+func newWebhookSubscription(row objects.BitburstWebhookSubscription) *WebhookSubscription {
+	s := &WebhookSubscription{
+		ID:        row.WID,
+		URL:       row.Url,
+		Secret:    row.Secret,
+		Events:    make([]EventType, 0, len(row.Events)),
+		CreatedAt: row.CreatedAt,
+	}
+	for _, e := range row.Events {
+		s.Events = append(s.Events, EventType(e))
+	}
+
+	return s
+}
+
+// CreateWebhookSubscription stores a new webhook subscription
+func (db *DB) CreateWebhookSubscription(ctx context.Context, url, secret string, events []EventType) (*WebhookSubscription, error) {
+	eventNames := make([]string, 0, len(events))
+	for _, e := range events {
+		eventNames = append(eventNames, string(e))
+	}
+
+	row, err := db.q.InsertWebhookSubscription(ctx, objects.InsertWebhookSubscriptionParams{
+		Url:    url,
+		Secret: secret,
+		Events: eventNames,
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to insert webhook subscription")
+	}
+
+	return newWebhookSubscription(row), nil
+}
+
+// WebhookSubscriptions returns all webhook subscriptions
+func (db *DB) WebhookSubscriptions(ctx context.Context) ([]*WebhookSubscription, error) {
+	rows, err := db.q.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list webhook subscriptions")
+	}
+
+	subs := make([]*WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subs = append(subs, newWebhookSubscription(row))
+	}
+
+	return subs, nil
+}
+
+// DeleteWebhookSubscription deletes webhook subscription with it's dead letters,
+// or returns ErrWebhookSubscriptionNotFound if it doesn't exist
+func (db *DB) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	n, err := db.q.DeleteWebhookSubscription(ctx, id)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to delete webhook subscription %d", id)
+	}
+
+	if n == 0 {
+		return ErrWebhookSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// SaveWebhookDeadLetter stores a webhook delivery that failed after all retries
+func (db *DB) SaveWebhookDeadLetter(ctx context.Context, subscriptionID int64, payload []byte, attempts int, lastErr error) error {
+	err := db.q.InsertWebhookDeadLetter(ctx, objects.InsertWebhookDeadLetterParams{
+		WID:       subscriptionID,
+		Payload:   payload,
+		Attempts:  int32(attempts),
+		LastError: lastErr.Error(),
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to insert webhook dead letter")
+	}
+
+	return nil
+}
+
+// WebhookDeadLetters returns at most limit dead letters with id greater than after, oldest first
+func (db *DB) WebhookDeadLetters(ctx context.Context, after int64, limit int32) ([]*WebhookDeadLetter, error) {
+	rows, err := db.q.ListWebhookDeadLetters(ctx, objects.ListWebhookDeadLettersParams{
+		AfterID: after,
+		MaxRows: limit,
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to list webhook dead letters")
+	}
+
+	letters := make([]*WebhookDeadLetter, 0, len(rows))
+	for _, row := range rows {
+		letters = append(letters, &WebhookDeadLetter{
+			ID:             row.DID,
+			SubscriptionID: row.WID,
+			Payload:        row.Payload,
+			Attempts:       row.Attempts,
+			LastError:      row.LastError,
+			FailedAt:       row.FailedAt,
+		})
+	}
+
+	return letters, nil
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "bitburst"
+
+// callbacks metrics
+var (
+	CallbacksReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callbacks_received_total",
+		Help:      "Amount of callbacks accepted on /callback route.",
+	})
+
+	CallbacksDuplicate = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callbacks_duplicate_total",
+		Help:      "Amount of callbacks that were acknowledged without processing, because callback with the same idempotency key was already received.",
+	})
+
+	CallbacksExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callbacks_expired_total",
+		Help:      "Amount of callbacks that were replayed from inbox after retention of objects passed, so they were dropped without processing.",
+	})
+
+	CallbacksUnauthorized = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callbacks_unauthorized_total",
+		Help:      "Amount of callbacks that were rejected, because their signature is missing, stale or invalid.",
+	})
+
+	CallbackObjectIDs = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "callback_object_ids",
+		Help:      "Amount of object ids received in one callback.",
+		Buckets:   []float64{0, 10, 25, 50, 100, 150, 200, 500, 1000},
+	})
+
+	CallbackDuplicateIDs = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callback_duplicate_ids_removed_total",
+		Help:      "Amount of duplicate object ids that were removed from callbacks before lookups.",
+	})
+
+	DeferredLookups = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "deferred_lookups",
+		Help:      "Amount of object ids queued to be looked up again, because their status couldn't be fetched in time.",
+	})
+
+	DeferredLookupsResolved = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deferred_lookups_resolved_total",
+		Help:      "Amount of deferred object ids which status was fetched and stored.",
+	})
+
+	DeferredLookupsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deferred_lookups_dropped_total",
+		Help:      "Amount of deferred object ids that were dropped, partitioned by reason: full, expired or invalid.",
+	}, []string{"reason"})
+)
+
+// webhooks metrics
+var (
+	WebhookEventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_dropped_total",
+		Help:      "Amount of events that were saved as webhook dead letters without delivery attempts, partitioned by reason: queue_full, shutdown or subscriptions (failed to get subscriptions after retries).",
+	}, []string{"reason"})
+
+	WebhookEventsLost = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_lost_total",
+		Help:      "Amount of events that were neither delivered to webhooks nor saved as dead letters.",
+	})
+)
+
+// tester service lookups metrics
+var (
+	LookupDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lookup_duration_seconds",
+		Help:      "Duration of object status lookups in tester service including retries, partitioned by outcome.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 4, 5, 10},
+	}, []string{"outcome"})
+
+	ClientCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_cache_requests_total",
+		Help:      "Amount of object status lookups served by in-process cache of client, partitioned by result: hit or miss.",
+	}, []string{"result"})
+
+	BreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "client_breaker_state",
+		Help:      "State of circuit breaker around tester service, 1 for the current state and 0 for the others.",
+	}, []string{"state"})
+)
+
+// database metrics
+var (
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of database operations including transaction, partitioned by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	DBObjects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_objects_total",
+		Help:      "Amount of objects that were inserted, updated or deleted in database.",
+	}, []string{"action"})
+
+	SweepDeletedObjects = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sweep_deleted_objects",
+		Help:      "Amount of objects deleted by one sweep of not seen objects.",
+		Buckets:   []float64{0, 1, 10, 25, 50, 100, 200, 500},
+	})
+)
+
+// auth metrics
+var RequestsUnauthorized = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "requests_unauthorized_total",
+	Help:      "Amount of requests to read and admin routes that were rejected, partitioned by reason: unauthenticated or forbidden.",
+}, []string{"reason"})
+
+// tls metrics
+var TLSReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "tls_reloads_total",
+	Help:      "Amount of reloads of tls certificate files after they changed, partitioned by result: success or failure.",
+}, []string{"result"})
+
+// redis status cache metrics
+var StatusCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "status_cache_requests_total",
+	Help:      "Amount of object status reads served by redis cache, partitioned by result: hit, miss or error.",
+}, []string{"result"})
@@ -0,0 +1,376 @@
+package server
+
+import (
+	"bitburst-assessment-task/internal/metrics"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// scopes that routes require, admin scope grants all of them
+const (
+	ScopeObjectsRead = "objects:read"
+	ScopeAdmin       = "admin"
+)
+
+// apiKeyHeader is a request header with static API key
+const apiKeyHeader = "X-API-Key"
+
+// AuthConfig holds settings of authentication of read and admin routes,
+// server doesn't start if neither API keys nor JWKS file are set, unless authentication is disabled explicitly
+type AuthConfig struct {
+	// Disabled serves read and admin routes without authentication
+	Disabled bool `mapstructure:"disabled"`
+
+	// APIKeys are static keys that are passed in X-API-Key header
+	APIKeys []APIKey `mapstructure:"api_keys"`
+
+	// JWKSPath is a JSON Web Key Set file with public keys that bearer tokens are verified with,
+	// file is read again when it changes, so keys can be rotated without restart
+	JWKSPath string `mapstructure:"jwks_path"`
+
+	// Issuer and Audience are required in claims of bearer tokens if they are set
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+}
+
+// APIKey is a static key with scopes it grants
+type APIKey struct {
+	// Name identifies key in logs
+	Name   string   `mapstructure:"name"`
+	Key    string   `mapstructure:"key"`
+	Scopes []string `mapstructure:"scopes"`
+}
+
+var (
+	errNoCredentials      = errors.New("neither api keys nor jwks file are set for read and admin routes, set auth.disabled to serve them without authentication")
+	errMissingCredentials = errors.New("missing credentials")
+	errInvalidAPIKey      = errors.New("invalid api key")
+	errInvalidToken       = errors.New("invalid bearer token")
+)
+
+// principal is an authenticated caller
+type principal struct {
+	subject string
+	scopes  map[string]struct{}
+}
+
+// has checks if principal is granted the scope
+func (p *principal) has(scope string) bool {
+	if _, ok := p.scopes[ScopeAdmin]; ok {
+		return true
+	}
+	_, ok := p.scopes[scope]
+	return ok
+}
+
+func newPrincipal(subject string, scopes []string) *principal {
+	p := &principal{subject: subject, scopes: make(map[string]struct{}, len(scopes))}
+	for _, s := range scopes {
+		p.scopes[s] = struct{}{}
+	}
+	return p
+}
+
+// tokenClaims are claims of bearer token, scopes are space separated like in OAuth 2.0
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+// jwks holds public keys of JSON Web Key Set file by their ids
+type jwks struct {
+	keys    map[string]interface{}
+	modTime time.Time
+}
+
+// authenticator authenticates requests with API keys and bearer tokens, nil authenticator is disabled
+type authenticator struct {
+	conf AuthConfig
+
+	mu   sync.Mutex
+	jwks *jwks
+}
+
+// newAuthenticator constructs an authenticator, if authentication is disabled it returns nil.
+// Authenticator without credentials rejects all requests.
+func newAuthenticator(conf AuthConfig) *authenticator {
+	if conf.Disabled {
+		return nil
+	}
+
+	return &authenticator{conf: conf}
+}
+
+// validate checks that credentials are configured unless authentication is disabled
+func (conf *AuthConfig) validate() error {
+	if !conf.Disabled && len(conf.APIKeys) == 0 && conf.JWKSPath == "" {
+		return errNoCredentials
+	}
+
+	return nil
+}
+
+// authenticate returns principal that request is made by, API key is checked first
+func (a *authenticator) authenticate(r *http.Request) (*principal, error) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("bearer ") && strings.EqualFold(auth[:len("bearer ")], "bearer ") {
+		return a.authenticateToken(strings.TrimSpace(auth[len("bearer "):]))
+	}
+
+	return nil, errMissingCredentials
+}
+
+// authenticateAPIKey looks up static API key, all keys are compared, so time of the check doesn't tell which one matched
+func (a *authenticator) authenticateAPIKey(key string) (*principal, error) {
+	var found *APIKey
+	for i := range a.conf.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(a.conf.APIKeys[i].Key), []byte(key)) == 1 {
+			found = &a.conf.APIKeys[i]
+		}
+	}
+	if found == nil {
+		return nil, errInvalidAPIKey
+	}
+
+	return newPrincipal("api_key:"+found.Name, found.Scopes), nil
+}
+
+// authenticateToken verifies bearer token signature with keys of JWKS file and checks its claims
+func (a *authenticator) authenticateToken(raw string) (*principal, error) {
+	if a.conf.JWKSPath == "" {
+		return nil, errInvalidToken
+	}
+
+	claims := &tokenClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}))
+	if _, err := parser.ParseWithClaims(raw, claims, a.keyFunc); err != nil {
+		return nil, errors.WithMessage(errInvalidToken, err.Error())
+	}
+
+	// expiration is checked by parser only if it's set, token that never expires isn't accepted
+	if claims.ExpiresAt == nil {
+		return nil, errors.WithMessage(errInvalidToken, "token doesn't have expiration time")
+	}
+
+	if a.conf.Issuer != "" && !claims.VerifyIssuer(a.conf.Issuer, true) {
+		return nil, errors.WithMessage(errInvalidToken, "unexpected issuer")
+	}
+	if a.conf.Audience != "" && !claims.VerifyAudience(a.conf.Audience, true) {
+		return nil, errors.WithMessage(errInvalidToken, "unexpected audience")
+	}
+
+	return newPrincipal(claims.Subject, strings.Fields(claims.Scope)), nil
+}
+
+// keyFunc returns public key that token is signed with, token without key id can be used if set has only one key
+func (a *authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	set, err := a.loadJWKS()
+	if err != nil {
+		log.Logger.Err(err).Msg("failed to load jwks")
+		return nil, errors.New("keys aren't available")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := set.keys[kid]
+	if !ok && kid == "" && len(set.keys) == 1 {
+		for _, k := range set.keys {
+			key, ok = k, true
+		}
+	}
+	if !ok {
+		return nil, errors.Errorf("unknown key id %q", kid)
+	}
+
+	// algorithm must match type of the key, so public key can't be used as hmac secret
+	switch key.(type) {
+	case *rsa.PublicKey:
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return key, nil
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+			return key, nil
+		}
+	}
+
+	return nil, errors.Errorf("algorithm %s doesn't match key %q", token.Method.Alg(), kid)
+}
+
+// loadJWKS returns keys of JWKS file, file is parsed again if it was modified since the last load
+func (a *authenticator) loadJWKS() (*jwks, error) {
+	info, err := os.Stat(a.conf.JWKSPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to stat jwks file")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.jwks != nil && a.jwks.modTime.Equal(info.ModTime()) {
+		return a.jwks, nil
+	}
+
+	data, err := ioutil.ReadFile(a.conf.JWKSPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read jwks file")
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	a.jwks = &jwks{keys: keys, modTime: info.ModTime()}
+	log.Logger.Info().Int("keys", len(keys)).Msg("loaded jwks")
+
+	return a.jwks, nil
+}
+
+// jsonWebKey is a public key of JSON Web Key Set, only RSA and EC keys are supported
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA key
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC key
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS parses public keys of JSON Web Key Set, keys that aren't for signatures are skipped
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode jwks")
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid key %q", k.Kid)
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// publicKey decodes public key from its parameters
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeKeyParam(k.N)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid modulus")
+		}
+		e, err := decodeKeyParam(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeKeyParam(k.X)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid x coordinate")
+		}
+		y, err := decodeKeyParam(k.Y)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid y coordinate")
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point isn't on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, errors.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeKeyParam decodes base64url encoded big integer
+func decodeKeyParam(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// requireScope wraps the handler, so it's called only for requests authenticated with the scope,
+// when authentication is disabled handler is called for all requests
+func (srv *Server) requireScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// authentication is disabled explicitly
+		if srv.auth == nil {
+			handler(rw, r)
+			return
+		}
+
+		p, err := srv.auth.authenticate(r)
+		if err != nil {
+			log.Logger.Warn().Err(err).Str("path", r.URL.Path).Msg("failed to authenticate request")
+			metrics.RequestsUnauthorized.WithLabelValues("unauthenticated").Inc()
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="bitburst"`)
+			writeJSONError(rw, http.StatusUnauthorized, errors.Cause(err).Error())
+			return
+		}
+
+		if !p.has(scope) {
+			log.Logger.Warn().Str("subject", p.subject).Str("scope", scope).Str("path", r.URL.Path).Msg("request isn't authorized")
+			metrics.RequestsUnauthorized.WithLabelValues("forbidden").Inc()
+			writeJSONError(rw, http.StatusForbidden, "missing scope "+scope)
+			return
+		}
+
+		handler(rw, r)
+	}
+}
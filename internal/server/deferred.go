@@ -0,0 +1,348 @@
+package server
+
+import (
+	"bitburst-assessment-task/internal/client"
+	"bitburst-assessment-task/internal/metrics"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// DeferredConfig holds settings of deferred lookups queue, ids which status couldn't be fetched in time
+// while processing a callback are put to the queue and looked up again in background
+type DeferredConfig struct {
+	// Size is a max amount of queued ids, ids that don't fit are dropped, 0 disables the queue
+	Size int `mapstructure:"size"`
+
+	// MaxAge is how long id is looked up again before it's dropped
+	MaxAge time.Duration `mapstructure:"max_age"`
+
+	// Interval is how often queued ids are looked up
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Path is a file where queue is persisted, so it survives restarts, queue is kept only in memory if it's empty
+	Path string `mapstructure:"path"`
+}
+
+// defaultDeferredInterval is used when interval of deferred lookups isn't set in config
+const defaultDeferredInterval = 10 * time.Second
+
+// deferredLookup is an id which status couldn't be fetched in time
+type deferredLookup struct {
+	ID         int32     `json:"id"`
+	CallbackID int64     `json:"callback_id"`
+	DeferredAt time.Time `json:"deferred_at"`
+}
+
+// deferredQueue is a set of deferred lookups, every id is queued once
+type deferredQueue struct {
+	size int
+
+	mu      sync.Mutex
+	lookups map[int32]*deferredLookup
+
+	// dirty is set when queue changed since it was persisted last time
+	dirty bool
+}
+
+func newDeferredQueue(size int) *deferredQueue {
+	return &deferredQueue{size: size, lookups: make(map[int32]*deferredLookup)}
+}
+
+// add queues ids of callback, ids that are already queued keep their original age,
+// it returns an amount of ids that didn't fit in the queue
+func (q *deferredQueue) add(callbackID int64, ids []int32, now time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := 0
+	for _, id := range ids {
+		if _, ok := q.lookups[id]; ok {
+			continue
+		}
+		if len(q.lookups) >= q.size {
+			dropped++
+			continue
+		}
+
+		q.lookups[id] = &deferredLookup{ID: id, CallbackID: callbackID, DeferredAt: now}
+		q.dirty = true
+	}
+	metrics.DeferredLookups.Set(float64(len(q.lookups)))
+
+	return dropped
+}
+
+// remove removes ids from the queue
+func (q *deferredQueue) remove(ids []int32) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := q.lookups[id]; ok {
+			delete(q.lookups, id)
+			q.dirty = true
+		}
+	}
+	metrics.DeferredLookups.Set(float64(len(q.lookups)))
+}
+
+// expire removes ids that were deferred more than maxAge ago, and returns them
+func (q *deferredQueue) expire(maxAge time.Duration, now time.Time) []int32 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var expired []int32
+	for id, l := range q.lookups {
+		if now.Sub(l.DeferredAt) > maxAge {
+			delete(q.lookups, id)
+			expired = append(expired, id)
+		}
+	}
+	if len(expired) > 0 {
+		q.dirty = true
+	}
+	metrics.DeferredLookups.Set(float64(len(q.lookups)))
+
+	return expired
+}
+
+// list returns copies of all queued lookups
+func (q *deferredQueue) list() []deferredLookup {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	lookups := make([]deferredLookup, 0, len(q.lookups))
+	for _, l := range q.lookups {
+		lookups = append(lookups, *l)
+	}
+
+	return lookups
+}
+
+// load adds lookups persisted in the file to the queue, missing file is not an error
+func (q *deferredQueue) load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithMessage(err, "failed to read deferred lookups file")
+	}
+
+	var lookups []*deferredLookup
+	if err := json.Unmarshal(data, &lookups); err != nil {
+		return errors.WithMessage(err, "failed to decode deferred lookups file")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, l := range lookups {
+		if _, ok := q.lookups[l.ID]; ok || len(q.lookups) >= q.size {
+			continue
+		}
+		q.lookups[l.ID] = l
+	}
+	metrics.DeferredLookups.Set(float64(len(q.lookups)))
+
+	return nil
+}
+
+// save persists the queue to the file if it changed
+func (q *deferredQueue) save(path string) error {
+	q.mu.Lock()
+	if !q.dirty {
+		q.mu.Unlock()
+		return nil
+	}
+	lookups := make([]*deferredLookup, 0, len(q.lookups))
+	for _, l := range q.lookups {
+		lookups = append(lookups, l)
+	}
+	data, err := json.Marshal(lookups)
+	q.dirty = false
+	q.mu.Unlock()
+
+	if err != nil {
+		err = errors.WithMessage(err, "failed to encode deferred lookups")
+	}
+
+	if err == nil {
+		err = writeFileAtomically(path, data)
+	}
+	if err != nil {
+		// persist it on the next save
+		q.mu.Lock()
+		q.dirty = true
+		q.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// writeFileAtomically replaces the file with data, so file isn't corrupted if process crashes in the middle of writing
+func writeFileAtomically(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.WithMessage(err, "failed to create deferred lookups file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.WithMessage(err, "failed to write deferred lookups file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithMessage(err, "failed to write deferred lookups file")
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.WithMessage(err, "failed to replace deferred lookups file")
+	}
+
+	return nil
+}
+
+// deferLookups queues ids of callback which status couldn't be fetched in time
+func (srv *Server) deferLookups(callbackID int64, ids []int32) {
+	if srv.deferred == nil || len(ids) == 0 {
+		return
+	}
+
+	if dropped := srv.deferred.add(callbackID, ids, time.Now()); dropped > 0 {
+		metrics.DeferredLookupsDropped.WithLabelValues("full").Add(float64(dropped))
+		log.Logger.Warn().Int64("callback_id", callbackID).Int("count", dropped).Msg("deferred lookups queue is full, dropping ids")
+	}
+}
+
+// ProcessDeferredLookups periodically looks up ids which status couldn't be fetched in time,
+// until status is fetched or ids are too old, it's to run in background.
+// When a job is done on behalf of this function, context should be canceled,
+// it returns after lookups in progress are finished and the queue is persisted.
+func (srv *Server) ProcessDeferredLookups(ctx context.Context) {
+	if srv.deferred == nil {
+		return
+	}
+
+	if srv.conf.Deferred.Path != "" {
+		if err := srv.deferred.load(srv.conf.Deferred.Path); err != nil {
+			log.Logger.Warn().Err(err).Msg("failed to load deferred lookups")
+		}
+	}
+
+	tick := time.NewTicker(srv.conf.Deferred.Interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if srv.conf.Deferred.Path == "" {
+				if n := len(srv.deferred.list()); n > 0 {
+					log.Logger.Warn().Int("count", n).Msg("deferred lookups aren't persisted, they are dropped")
+				}
+				return
+			}
+			srv.saveDeferredLookups()
+			return
+		case <-tick.C:
+			srv.processDeferredLookups()
+			srv.saveDeferredLookups()
+		}
+	}
+}
+
+// processDeferredLookups looks up queued ids once and stores fetched statuses in database,
+// it isn't bound to context of the job, so shutdown doesn't cut it off in the middle of processing
+func (srv *Server) processDeferredLookups() {
+	if expired := srv.deferred.expire(srv.conf.Deferred.MaxAge, time.Now()); len(expired) > 0 {
+		metrics.DeferredLookupsDropped.WithLabelValues("expired").Add(float64(len(expired)))
+		log.Logger.Warn().Ints32("ids", expired).Msg("failed to get status of deferred objects in time, dropping them")
+	}
+
+	lookups := srv.deferred.list()
+	if len(lookups) == 0 {
+		return
+	}
+
+	// tester service is considered down, ids are looked up when it recovers
+	if srv.cli.Breaker().State == client.BreakerOpen {
+		return
+	}
+
+	ids := make([]int32, len(lookups))
+	for i, l := range lookups {
+		ids[i] = l.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), srv.conf.ProcessingTimeout)
+	defer cancel()
+
+	results := srv.cli.Do(ctx, ids)
+
+	// group statuses by callbacks, so history of status changes is attributed to callbacks that reported objects
+	type callbackStatuses struct {
+		onlineIDs, offlineIDs []int32
+	}
+	byCallback := make(map[int64]*callbackStatuses)
+	var invalidIDs []int32
+	for i, res := range results {
+		switch {
+		case res.Err == nil:
+			cs, ok := byCallback[lookups[i].CallbackID]
+			if !ok {
+				cs = &callbackStatuses{}
+				byCallback[lookups[i].CallbackID] = cs
+			}
+			if res.Status.Online {
+				cs.onlineIDs = append(cs.onlineIDs, res.ID)
+			} else {
+				cs.offlineIDs = append(cs.offlineIDs, res.ID)
+			}
+		case !client.IsTemporary(res.Err):
+			log.Logger.Error().Err(res.Err).Int32("id", res.ID).Msg("received invalid status of deferred object, dropping it")
+			invalidIDs = append(invalidIDs, res.ID)
+		}
+	}
+
+	if len(invalidIDs) > 0 {
+		srv.deferred.remove(invalidIDs)
+		metrics.DeferredLookupsDropped.WithLabelValues("invalid").Add(float64(len(invalidIDs)))
+	}
+
+	// use a fresh context, so fetched statuses are stored even if lookups consumed the whole timeout
+	storeCtx, storeCancel := context.WithTimeout(context.Background(), srv.conf.ProcessingTimeout)
+	defer storeCancel()
+
+	for callbackID, cs := range byCallback {
+		if _, _, err := srv.database.InsertObjectsOrUpdate(storeCtx, callbackID, cs.onlineIDs, cs.offlineIDs); err != nil {
+			log.Logger.Err(err).Int64("callback_id", callbackID).Msg("failed to process deferred objects in database")
+			continue
+		}
+
+		srv.deferred.remove(cs.onlineIDs)
+		srv.deferred.remove(cs.offlineIDs)
+		metrics.DeferredLookupsResolved.Add(float64(len(cs.onlineIDs) + len(cs.offlineIDs)))
+		log.Logger.Info().Int64("callback_id", callbackID).Ints32("online_ids", cs.onlineIDs).Ints32("offline_ids", cs.offlineIDs).
+			Msg("succeeded to process deferred objects in database")
+	}
+}
+
+// saveDeferredLookups persists deferred lookups queue if persistence is enabled
+func (srv *Server) saveDeferredLookups() {
+	if srv.conf.Deferred.Path == "" {
+		return
+	}
+
+	if err := srv.deferred.save(srv.conf.Deferred.Path); err != nil {
+		log.Logger.Warn().Err(err).Msg("failed to persist deferred lookups")
+	}
+}
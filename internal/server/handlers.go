@@ -1,17 +1,84 @@
 package server
 
 import (
-	"context"
+	"bitburst-assessment-task/internal/db"
+	"bitburst-assessment-task/internal/metrics"
+	"bitburst-assessment-task/internal/signature"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
 
 	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
 
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// idempotencyKeyHeader is a request header with idempotency key of callback,
+	// retries of callback with the same key are acknowledged without processing them again
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader is set on response to a callback that was already received
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength is a max length of idempotency key
+	maxIdempotencyKeyLength = 255
+)
+
 type callbackReqBody struct {
 	ObjectIDs []int32 `json:"object_ids"`
+
+	// CallbackID is an idempotency key of callback, it's an alternative to Idempotency-Key header
+	CallbackID string `json:"callback_id,omitempty"`
+}
+
+// idempotencyKey returns idempotency key of callback from header or body, empty key means callback has no key
+func (body *callbackReqBody) idempotencyKey(r *http.Request) (string, error) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key != "" && body.CallbackID != "" && key != body.CallbackID {
+		return "", errors.Errorf("%s header and callback_id field don't match", idempotencyKeyHeader)
+	}
+	if key == "" {
+		key = body.CallbackID
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return "", errors.Errorf("idempotency key is longer than %d characters", maxIdempotencyKeyLength)
+	}
+
+	return key, nil
+}
+
+// fingerprint returns a hash of object ids, so reuse of idempotency key with another payload is detected
+func (body *callbackReqBody) fingerprint() string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(body.ObjectIDs)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// validate checks if callback has sane object ids, maxObjectIDs <= 0 means there is no limit
+func (body *callbackReqBody) validate(maxObjectIDs int) error {
+	if body.ObjectIDs == nil {
+		return errors.New("object_ids field is required")
+	}
+
+	if maxObjectIDs > 0 && len(body.ObjectIDs) > maxObjectIDs {
+		return errors.Errorf("too many object ids: %d, max allowed: %d", len(body.ObjectIDs), maxObjectIDs)
+	}
+
+	for _, id := range body.ObjectIDs {
+		if id < 0 {
+			return errors.Errorf("object id can't be negative: %d", id)
+		}
+	}
+
+	return nil
 }
 
 // handleCallback handles all requests coming on /callback route
@@ -19,56 +86,107 @@ func (srv *Server) handleCallback(rw http.ResponseWriter, r *http.Request) {
 	log.Logger.Info().Msg("received request")
 	defer log.Logger.Info().Msg("finished request")
 
-	// unmarshal request body
-	var body callbackReqBody
-	err := json.NewDecoder(r.Body).Decode(&body)
-	if err != nil {
-		log.Logger.Err(err).Msg("failed to decode request body")
-		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
 	}
 
-	// do the job in background, so we won't keep busy the client
-	// and miss any callback
-	go func() {
-		log.Logger.Debug().Ints32("object_ids", body.ObjectIDs).Msg("request body")
-
-		// process only unique ids, so we don't send same id twice or thrice to server, for example if would receive 1,000,000 ids and 1/3 of them would be duplicates, then we would send 333,333 useless requests and waste time
-		uniqueIDs := make(map[int32]struct{})
-		ids := make([]int32, 0, len(body.ObjectIDs))
-		for _, id := range body.ObjectIDs {
-			_, ok := uniqueIDs[id]
-			if !ok {
-				uniqueIDs[id] = struct{}{}
-				ids = append(ids, id)
-			}
-		}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeJSONError(rw, http.StatusUnsupportedMediaType, "content type must be application/json")
+		return
+	}
+
+	if srv.conf.MaxBodySize > 0 {
+		r.Body = http.MaxBytesReader(rw, r.Body, srv.conf.MaxBodySize)
+	}
 
-		// timeout can be increased if server responds longer than 4 seconds
-		ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-		defer cancel()
-
-		// send request to tester service and get online statuses for objects
-		objStatuses := srv.cli.Do(ctx, ids)
-
-		onlineIDs := make([]int32, 0, len(ids))
-		offlineIDs := make([]int32, 0, len(ids))
-		for _, obj := range objStatuses {
-			if obj.Online {
-				onlineIDs = append(onlineIDs, obj.ID)
-			} else {
-				offlineIDs = append(offlineIDs, obj.ID)
-			}
+	// signature is computed over raw body, so it's read before unmarshalling
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Logger.Warn().Err(err).Msg("failed to read request body")
+
+		// http.MaxBytesReader doesn't have a typed error in older go versions
+		if strings.Contains(err.Error(), "request body too large") {
+			writeJSONError(rw, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", srv.conf.MaxBodySize))
+			return
 		}
+		writeJSONError(rw, http.StatusBadRequest, "malformed request body")
+		return
+	}
 
-		// insert/update and delete objects
-		insertedIDs, updatedIDs, err := srv.database.InsertObjectsOrUpdate(ctx, onlineIDs, offlineIDs)
+	if len(srv.callbackSecrets) > 0 {
+		err := signature.Verify(srv.callbackSecrets, r.Header.Get(signature.TimestampHeader), r.Header.Get(signature.SignatureHeader),
+			raw, time.Now(), srv.conf.CallbackSignatureTolerance)
 		if err != nil {
-			log.Logger.Err(err).Msg("failed to process objects in database")
+			log.Logger.Warn().Err(err).Msg("received callback with invalid signature")
+			metrics.CallbacksUnauthorized.Inc()
+			writeJSONError(rw, http.StatusUnauthorized, err.Error())
 			return
 		}
+	}
+
+	// unmarshal request body
+	var body callbackReqBody
+	if err := json.Unmarshal(raw, &body); err != nil {
+		log.Logger.Warn().Err(err).Msg("failed to decode request body")
+		writeJSONError(rw, http.StatusBadRequest, "malformed request body")
+		return
+	}
+
+	if err := body.validate(srv.conf.MaxObjectIDs); err != nil {
+		log.Logger.Warn().Err(err).Msg("received invalid request body")
+		writeJSONError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		log.Logger.Info().Ints32("inserted_ids", insertedIDs).Ints32("updated_ids", updatedIDs).Msg("succeeded to process objects in database")
-	}()
+	key, err := body.idempotencyKey(r)
+	if err != nil {
+		writeJSONError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// persist callback in the inbox before acknowledging it, so it isn't lost if process crashes
+	// or database goes down in the middle of processing
+	var (
+		id        int64
+		duplicate bool
+	)
+	if key != "" {
+		id, duplicate, err = srv.database.SaveCallbackOnce(r.Context(), &db.IdempotencyKey{
+			Key:         key,
+			Fingerprint: body.fingerprint(),
+			TTL:         srv.conf.IdempotencyKeyTTL,
+		}, body.ObjectIDs)
+	} else {
+		id, err = srv.database.SaveCallback(r.Context(), body.ObjectIDs)
+	}
+	if err != nil {
+		if err == db.ErrIdempotencyKeyReused {
+			writeJSONError(rw, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		log.Logger.Err(err).Msg("failed to save callback in inbox")
+		writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	// callback was already received, so it's acknowledged the same way without processing it again
+	if duplicate {
+		log.Logger.Info().Int64("callback_id", id).Str("idempotency_key", key).Msg("received duplicate callback")
+		metrics.CallbacksDuplicate.Inc()
+		rw.Header().Set(idempotentReplayedHeader, "true")
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+
+	metrics.CallbacksReceived.Inc()
+	metrics.CallbackObjectIDs.Observe(float64(len(body.ObjectIDs)))
+
+	// do the job in background, so we won't keep busy the client
+	// and miss any callback
+	srv.enqueueCallback(&db.Callback{ID: id, ObjectIDs: body.ObjectIDs, ReceivedAt: time.Now()})
 
 	// notify tester_service that we received objects successfully
 	rw.WriteHeader(http.StatusOK)
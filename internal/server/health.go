@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// readinessCheckTimeout is a max time for checking all dependencies of the server
+const readinessCheckTimeout = 2 * time.Second
+
+// checkRespBody is a result of dependency check, errors are only logged,
+// so unauthenticated probes don't expose database and tester service addresses
+type checkRespBody struct {
+	Status  string `json:"status"`
+	Version *int   `json:"version,omitempty"`
+}
+
+type readinessRespBody struct {
+	Status string                    `json:"status"`
+	Checks map[string]*checkRespBody `json:"checks"`
+}
+
+// handleHealth handles all requests coming on /healthz route, it only tells that process is alive
+func (srv *Server) handleHealth(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, &checkRespBody{Status: "ok"})
+}
+
+// handleReadiness handles all requests coming on /readyz route,
+// it checks database connectivity, migration version and reachability of tester service.
+// Server is reported as not ready while it's being closed, so traffic drains before shutdown.
+func (srv *Server) handleReadiness(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+	defer cancel()
+
+	status, resp := srv.checkReadiness(ctx)
+	writeJSON(rw, status, resp)
+}
+
+// checkReadiness checks all dependencies of the server and returns response status code with results of checks
+func (srv *Server) checkReadiness(ctx context.Context) (int, *readinessRespBody) {
+	resp := &readinessRespBody{
+		Status: "ready",
+		Checks: make(map[string]*checkRespBody, 3),
+	}
+
+	if atomic.LoadInt32(&srv.ready) == 0 {
+		resp.Checks["server"] = newCheckRespBody("server", errors.New("server is shutting down"))
+	}
+
+	resp.Checks["database"] = newCheckRespBody("database", srv.database.Ping(ctx))
+
+	version, err := srv.database.CheckMigrations(ctx)
+	resp.Checks["migrations"] = newCheckRespBody("migrations", err)
+	if version != 0 {
+		resp.Checks["migrations"].Version = &version
+	}
+
+	resp.Checks["tester_service"] = newCheckRespBody("tester_service", srv.cli.Ping(ctx))
+
+	status := http.StatusOK
+	for _, check := range resp.Checks {
+		if check.Status != "ok" {
+			resp.Status = "not ready"
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	return status, resp
+}
+
+// newCheckRespBody constructs result of dependency check and logs the error if check failed
+func newCheckRespBody(name string, err error) *checkRespBody {
+	if err != nil {
+		log.Logger.Warn().Err(err).Str("check", name).Msg("readiness check failed")
+		return &checkRespBody{Status: "failed"}
+	}
+
+	return &checkRespBody{Status: "ok"}
+}
+
+// handleBreaker handles all requests coming on /client/breaker route,
+// it returns state of circuit breaker around tester service
+func (srv *Server) handleBreaker(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, srv.cli.Breaker())
+}
+
+// handleClientCache handles all requests coming on /client/cache route,
+// it returns statistics of status cache of tester service client
+func (srv *Server) handleClientCache(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, srv.cli.CacheStats())
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bitburst-assessment-task/internal/client"
+	"bitburst-assessment-task/internal/db"
+	"bitburst-assessment-task/internal/metrics"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// replayBatchSize is a max amount of inbox entries that are fetched from database during one replay
+const replayBatchSize = 100
+
+// ProcessCallbacks drains the callbacks inbox, it's to run in background.
+// On start it replays callbacks that were accepted but not processed before a crash or shutdown,
+// and then periodically replays callbacks that didn't fit in the queue or failed to be processed.
+// When a job is done on behalf of this function, context should be canceled.
+func (srv *Server) ProcessCallbacks(ctx context.Context) {
+	srv.replayCallbacks(ctx, 0)
+
+	tick := time.NewTicker(srv.conf.InboxReplayInterval)
+	for {
+		select {
+		case <-ctx.Done():
+			tick.Stop()
+			return
+		case c := <-srv.inbox:
+			srv.goProcessCallback(c)
+		case <-tick.C:
+			// only replay callbacks older than min age,
+			// so ones that were just accepted and are about to be queued aren't picked up
+			srv.replayCallbacks(ctx, srv.conf.InboxReplayMinAge)
+
+			if n, err := srv.database.DeleteProcessedCallbacks(ctx, srv.conf.InboxRetention); err != nil {
+				log.Logger.Warn().Err(err).Msg("failed to clean up callbacks inbox")
+			} else {
+				log.Logger.Debug().Int64("count", n).Msg("cleaned up callbacks inbox")
+			}
+
+			if n, err := srv.database.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+				log.Logger.Warn().Err(err).Msg("failed to clean up idempotency keys")
+			} else {
+				log.Logger.Debug().Int64("count", n).Msg("cleaned up idempotency keys")
+			}
+		}
+	}
+}
+
+// enqueueCallback queues an accepted callback for processing,
+// if queue is full callback stays in the inbox and will be replayed later
+func (srv *Server) enqueueCallback(c *db.Callback) {
+	if !srv.trackCallback(c.ID) {
+		return
+	}
+
+	select {
+	case srv.inbox <- c:
+	default:
+		srv.untrackCallback(c.ID)
+		log.Logger.Warn().Int64("callback_id", c.ID).Msg("callbacks queue is full, callback will be replayed from inbox")
+	}
+}
+
+// replayCallbacks starts processing of unprocessed inbox entries that were received at least minAge ago
+func (srv *Server) replayCallbacks(ctx context.Context, minAge time.Duration) {
+	callbacks, err := srv.database.UnprocessedCallbacks(ctx, minAge, replayBatchSize)
+	if err != nil {
+		log.Logger.Warn().Err(err).Msg("failed to replay callbacks inbox")
+		return
+	}
+
+	for _, c := range callbacks {
+		// callback can be already in the queue or in the middle of processing
+		if !srv.trackCallback(c.ID) {
+			continue
+		}
+
+		log.Logger.Info().Int64("callback_id", c.ID).Msg("replaying callback from inbox")
+		srv.goProcessCallback(c)
+	}
+}
+
+// goProcessCallback processes callback in a separate goroutine which server waits for on close,
+// once server is closing callback isn't processed and stays in the inbox
+func (srv *Server) goProcessCallback(c *db.Callback) {
+	srv.backgroundMu.Lock()
+	defer srv.backgroundMu.Unlock()
+
+	if srv.closing {
+		srv.untrackCallback(c.ID)
+		return
+	}
+
+	srv.background.Add(1)
+	go func() {
+		defer srv.background.Done()
+
+		srv.processCallback(c)
+	}()
+}
+
+// processCallback gets online statuses of callback objects, stores them in database and marks inbox entry as done,
+// if something fails entry is left in the inbox and will be replayed later
+func (srv *Server) processCallback(c *db.Callback) {
+	defer srv.untrackCallback(c.ID)
+
+	logger := log.Logger.With().Int64("callback_id", c.ID).Logger()
+	logger.Debug().Ints32("object_ids", c.ObjectIDs).Msg("request body")
+
+	// objects of callback received before retention window may be already deleted for not being seen,
+	// so processing it now would bring them back as if they were seen just now
+	if age := time.Since(c.ReceivedAt); srv.conf.InboxMaxReplayAge > 0 && !c.ReceivedAt.IsZero() && age > srv.conf.InboxMaxReplayAge {
+		logger.Warn().Dur("age", age).Ints32("object_ids", c.ObjectIDs).Msg("callback is too old to be processed, dropping it")
+		metrics.CallbacksExpired.Inc()
+
+		ctx, cancel := context.WithTimeout(context.Background(), srv.conf.ProcessingTimeout)
+		defer cancel()
+		if err := srv.database.MarkCallbackProcessed(ctx, c.ID); err != nil {
+			logger.Warn().Err(err).Msg("failed to mark callback as processed, it will be replayed")
+		}
+		return
+	}
+
+	// process only unique ids, so we don't send same id twice or thrice to server, for example if would receive 1,000,000 ids and 1/3 of them would be duplicates, then we would send 333,333 useless requests and waste time
+	uniqueIDs := make(map[int32]struct{})
+	ids := make([]int32, 0, len(c.ObjectIDs))
+	for _, id := range c.ObjectIDs {
+		_, ok := uniqueIDs[id]
+		if !ok {
+			uniqueIDs[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	metrics.CallbackDuplicateIDs.Add(float64(len(c.ObjectIDs) - len(ids)))
+
+	// don't look up objects while tester service is considered down, callback is replayed when it recovers
+	if srv.cli.Breaker().State == client.BreakerOpen {
+		logger.Warn().Msg("tester service circuit is open, callback will be replayed from inbox")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), srv.conf.ProcessingTimeout)
+	defer cancel()
+
+	// send request to tester service and get online statuses for objects
+	results := srv.cli.Do(ctx, ids)
+
+	onlineIDs := make([]int32, 0, len(ids))
+	offlineIDs := make([]int32, 0, len(ids))
+	deferredIDs := make([]int32, 0)
+	circuitOpen := false
+	for _, res := range results {
+		switch {
+		case errors.Is(res.Err, client.ErrCircuitOpen):
+			// circuit opened in the middle of processing, object is looked up when callback is replayed
+			circuitOpen = true
+		case res.Err == nil && res.Status.Online:
+			onlineIDs = append(onlineIDs, res.ID)
+		case res.Err == nil:
+			offlineIDs = append(offlineIDs, res.ID)
+		case client.IsTemporary(res.Err):
+			// status is unknown, so object is left as it is in database and is looked up again later
+			logger.Warn().Err(res.Err).Int32("id", res.ID).Msg("failed to get object status, deferring it")
+			deferredIDs = append(deferredIDs, res.ID)
+		default:
+			// tester service responded with something we can't trust, so don't persist anything for the object
+			logger.Error().Err(res.Err).Int32("id", res.ID).Msg("received invalid object status, skipping it")
+		}
+	}
+
+	// insert/update and delete objects, use a fresh context, so statuses that were fetched aren't lost
+	// and deferred ids are queued even if lookups consumed the whole timeout
+	storeCtx, storeCancel := context.WithTimeout(context.Background(), srv.conf.ProcessingTimeout)
+	defer storeCancel()
+
+	insertedIDs, updatedIDs, err := srv.database.InsertObjectsOrUpdate(storeCtx, c.ID, onlineIDs, offlineIDs)
+	if err != nil {
+		logger.Err(err).Msg("failed to process objects in database")
+		return
+	}
+
+	logger.Info().Ints32("inserted_ids", insertedIDs).Ints32("updated_ids", updatedIDs).Msg("succeeded to process objects in database")
+
+	if srv.deferred != nil {
+		// fresh statuses are stored, so there is no need to look them up again
+		srv.deferred.remove(onlineIDs)
+		srv.deferred.remove(offlineIDs)
+	}
+	srv.deferLookups(c.ID, deferredIDs)
+
+	if circuitOpen {
+		logger.Warn().Msg("tester service circuit opened during lookups, callback will be replayed from inbox")
+		return
+	}
+
+	// use a fresh context, so callback isn't replayed only because lookups consumed the whole timeout
+	markCtx, markCancel := context.WithTimeout(context.Background(), srv.conf.ProcessingTimeout)
+	defer markCancel()
+
+	if err := srv.database.MarkCallbackProcessed(markCtx, c.ID); err != nil {
+		logger.Warn().Err(err).Msg("failed to mark callback as processed, it will be replayed")
+	}
+}
+
+// trackCallback marks callback as being processed, returns false if it's already tracked
+func (srv *Server) trackCallback(id int64) bool {
+	srv.pendingMu.Lock()
+	defer srv.pendingMu.Unlock()
+
+	if _, ok := srv.pending[id]; ok {
+		return false
+	}
+	srv.pending[id] = struct{}{}
+
+	return true
+}
+
+// untrackCallback removes callback from the set of callbacks being processed
+func (srv *Server) untrackCallback(id int64) {
+	srv.pendingMu.Lock()
+	defer srv.pendingMu.Unlock()
+
+	delete(srv.pending, id)
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bitburst-assessment-task/internal/db"
+	"bitburst-assessment-task/internal/db/objects"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultObjectsLimit is a page size of /objects route when limit isn't supplied
+	defaultObjectsLimit = 100
+
+	// maxObjectsLimit is a max page size of /objects route
+	maxObjectsLimit = 1000
+
+	// defaultHistoryPeriod is how far /objects/:id/history route looks back when since isn't supplied
+	defaultHistoryPeriod = 24 * time.Hour
+
+	// defaultUptimeWindow is a window of /uptime route when it isn't supplied
+	defaultUptimeWindow = time.Hour
+)
+
+type historyRespBody struct {
+	History []*db.StatusChange `json:"history"`
+}
+
+type uptimeRespBody struct {
+	Window  string       `json:"window"`
+	Objects []*db.Uptime `json:"objects"`
+}
+
+type objectsRespBody struct {
+	Objects []objects.BitburstObject `json:"objects"`
+
+	// NextCursor should be passed as cursor query param to get the next page, it's omitted on the last page
+	NextCursor *int32 `json:"next_cursor,omitempty"`
+}
+
+// handleObjects handles all requests coming on /objects route,
+// supported query params are online, seen_after, seen_before (RFC3339), cursor and limit
+func (srv *Server) handleObjects(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	filter, err := parseListObjectsFilter(r)
+	if err != nil {
+		writeJSONError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// fetch one extra object to know if there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	objs, err := srv.database.ListObjects(r.Context(), filter)
+	if err != nil {
+		log.Logger.Err(err).Msg("failed to list objects")
+		writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	resp := &objectsRespBody{Objects: objs}
+	if resp.Objects == nil {
+		resp.Objects = []objects.BitburstObject{}
+	}
+	if len(objs) > int(limit) {
+		resp.Objects = objs[:limit]
+		resp.NextCursor = &resp.Objects[limit-1].OID
+	}
+
+	writeJSON(rw, http.StatusOK, resp)
+}
+
+// handleObject handles all requests coming on /objects/:id and /objects/:id/history routes
+func (srv *Server) handleObject(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	idRaw, subPath := strings.TrimPrefix(r.URL.Path, "/objects/"), ""
+	if i := strings.IndexByte(idRaw, '/'); i >= 0 {
+		idRaw, subPath = idRaw[:i], idRaw[i+1:]
+	}
+
+	id, err := strconv.ParseInt(idRaw, 10, 32)
+	if err != nil {
+		writeJSONError(rw, http.StatusBadRequest, "invalid object id")
+		return
+	}
+
+	switch subPath {
+	case "":
+	case "history":
+		srv.handleObjectHistory(rw, r, int32(id))
+		return
+	default:
+		writeJSONError(rw, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+
+	obj, err := srv.database.GetObject(r.Context(), int32(id))
+	if err != nil {
+		if err == db.ErrObjectNotFound {
+			writeJSONError(rw, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Logger.Err(err).Msg("failed to get object")
+		writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, obj)
+}
+
+// handleObjectHistory handles requests on /objects/:id/history route,
+// supported query params are since (RFC3339, 24 hours ago by default) and limit
+func (srv *Server) handleObjectHistory(rw http.ResponseWriter, r *http.Request, id int32) {
+	query := r.URL.Query()
+
+	since := time.Now().Add(-defaultHistoryPeriod)
+	if v := query.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSONError(rw, http.StatusBadRequest, errInvalidQueryParam("since").Error())
+			return
+		}
+		since = t
+	}
+
+	limit := int64(defaultObjectsLimit)
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.ParseInt(v, 10, 32)
+		if err != nil || limit <= 0 || limit > maxObjectsLimit {
+			writeJSONError(rw, http.StatusBadRequest, errInvalidQueryParam("limit").Error())
+			return
+		}
+	}
+
+	changes, err := srv.database.ObjectTimeline(r.Context(), id, since, int32(limit))
+	if err != nil {
+		log.Logger.Err(err).Msg("failed to get object timeline")
+		writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, &historyRespBody{History: changes})
+}
+
+// handleUptime handles all requests coming on /uptime route,
+// it returns uptime percentage of every object during the window (1 hour by default)
+func (srv *Server) handleUptime(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	window := defaultUptimeWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		var err error
+		window, err = time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			writeJSONError(rw, http.StatusBadRequest, errInvalidQueryParam("window").Error())
+			return
+		}
+	}
+
+	uptimes, err := srv.database.Uptime(r.Context(), window)
+	if err != nil {
+		log.Logger.Err(err).Msg("failed to get uptime of objects")
+		writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, &uptimeRespBody{Window: window.String(), Objects: uptimes})
+}
+
+// parseListObjectsFilter constructs objects filter from request query params
+func parseListObjectsFilter(r *http.Request) (*db.ListObjectsFilter, error) {
+	query := r.URL.Query()
+	filter := &db.ListObjectsFilter{Limit: defaultObjectsLimit}
+
+	if v := query.Get("online"); v != "" {
+		online, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errInvalidQueryParam("online")
+		}
+		filter.Online = &online
+	}
+
+	if v := query.Get("seen_after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errInvalidQueryParam("seen_after")
+		}
+		filter.SeenAfter = &t
+	}
+
+	if v := query.Get("seen_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errInvalidQueryParam("seen_before")
+		}
+		filter.SeenBefore = &t
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		after, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, errInvalidQueryParam("cursor")
+		}
+		cursor := int32(after)
+		filter.After = &cursor
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || limit <= 0 || limit > maxObjectsLimit {
+			return nil, errInvalidQueryParam("limit")
+		}
+		filter.Limit = int32(limit)
+	}
+
+	return filter, nil
+}
+
+// errInvalidQueryParam returns an error about invalid value of query param
+func errInvalidQueryParam(name string) error {
+	return errors.Errorf("invalid value of query param: %s", name)
+}
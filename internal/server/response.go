@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+
+	json "github.com/json-iterator/go"
+
+	"github.com/rs/zerolog/log"
+)
+
+// errorRespBody is a json document that is sent to clients on errors
+type errorRespBody struct {
+	Error string `json:"error"`
+}
+
+// writeJSON encodes v as json and writes it to response with given status code
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Logger.Warn().Err(err).Msg("failed to encode response body")
+	}
+}
+
+// writeJSONError writes json error document to response with given status code
+func writeJSONError(rw http.ResponseWriter, status int, msg string) {
+	writeJSON(rw, status, &errorRespBody{Error: msg})
+}
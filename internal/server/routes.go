@@ -1,12 +1,32 @@
 package server
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
 
 // newMux constructs new server multiplexer and registers all routes to it
 func (srv *Server) newMux() *http.ServeMux {
 	mux := http.NewServeMux()
 
+	// callbacks are authenticated with signatures, probes and metrics are public
 	mux.HandleFunc("/callback", srv.handleCallback)
 
+	mux.HandleFunc("/objects", srv.requireScope(ScopeObjectsRead, srv.handleObjects))
+	mux.HandleFunc("/objects/", srv.requireScope(ScopeObjectsRead, srv.handleObject))
+	mux.HandleFunc("/uptime", srv.requireScope(ScopeObjectsRead, srv.handleUptime))
+
+	mux.HandleFunc("/webhooks", srv.requireScope(ScopeAdmin, srv.handleWebhooks))
+	mux.HandleFunc("/webhooks/", srv.requireScope(ScopeAdmin, srv.handleWebhook))
+
+	mux.HandleFunc("/client/breaker", srv.requireScope(ScopeAdmin, srv.handleBreaker))
+	mux.HandleFunc("/client/cache", srv.requireScope(ScopeAdmin, srv.handleClientCache))
+
+	mux.Handle("/metrics", promhttp.Handler())
+
+	mux.HandleFunc("/healthz", srv.handleHealth)
+	mux.HandleFunc("/readyz", srv.handleReadiness)
+
 	return mux
 }
@@ -5,9 +5,12 @@ import (
 	"bitburst-assessment-task/internal/db"
 	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
 // Config holds configuration for server that is needed
@@ -17,19 +20,107 @@ type Config struct {
 	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	// ReadinessDrainDelay is how long server keeps serving after it's reported as not ready on close,
+	// so orchestrator has time to stop sending traffic to it
+	ReadinessDrainDelay time.Duration `mapstructure:"readiness_drain_delay"`
+
+	// limits of /callback requests, 0 means there is no limit
+	MaxBodySize  int64 `mapstructure:"max_body_size"`
+	MaxObjectIDs int   `mapstructure:"max_object_ids"`
+
+	// callbacks inbox settings: size of in-memory queue of accepted callbacks,
+	// how often unprocessed callbacks are replayed and how long processed ones are kept
+	InboxQueueSize      int           `mapstructure:"inbox_queue_size"`
+	InboxReplayInterval time.Duration `mapstructure:"inbox_replay_interval"`
+	InboxRetention      time.Duration `mapstructure:"inbox_retention"`
+
+	// InboxReplayMinAge is a min age of unprocessed entry that is replayed periodically,
+	// so callbacks that were just accepted and are about to be queued aren't picked up
+	InboxReplayMinAge time.Duration `mapstructure:"inbox_replay_min_age"`
+
+	// InboxMaxReplayAge is a max age of inbox entry that is processed, older entries are marked as processed
+	// without looking up their objects, so they don't bring back objects that were already deleted for not being seen.
+	// It should be the same as retention of objects and longer than InboxReplayMinAge plus InboxReplayInterval,
+	// otherwise failed callbacks are too old once they are replayed. 0 means there is no limit
+	InboxMaxReplayAge time.Duration `mapstructure:"inbox_max_replay_age"`
+
+	// IdempotencyKeyTTL is how long idempotency keys of callbacks are remembered,
+	// callback retried with the same key during this period isn't processed again
+	IdempotencyKeyTTL time.Duration `mapstructure:"idempotency_key_ttl"`
+
+	// CallbackSecrets are shared secrets that callbacks are signed with, callback signed with any of them is accepted,
+	// so a new secret can be added before the old one is removed. Signatures aren't verified if it's empty
+	CallbackSecrets []string `mapstructure:"callback_secrets"`
+
+	// CallbackSignatureTolerance is a max difference between signature timestamp and server time,
+	// older callbacks are rejected, so they can't be replayed
+	CallbackSignatureTolerance time.Duration `mapstructure:"callback_signature_tolerance"`
+
+	// ProcessingTimeout is a max time of looking up objects of one callback, storing them has the same timeout separately,
+	// it must cover all attempts of a lookup with backoffs between them, default timeout is used if it's 0
+	ProcessingTimeout time.Duration `mapstructure:"processing_timeout"`
+
+	Deferred DeferredConfig `mapstructure:"deferred"`
+
+	Auth AuthConfig `mapstructure:"auth"`
+
+	TLS TLSConfig `mapstructure:"tls"`
 }
 
+const (
+	// defaultInboxReplayInterval is used when replay interval isn't set in config
+	defaultInboxReplayInterval = 10 * time.Second
+
+	// defaultInboxReplayMinAge is used when min age of replayed callbacks isn't set in config
+	defaultInboxReplayMinAge = 5 * time.Second
+
+	// defaultProcessingTimeout is used when callback processing timeout isn't set in config,
+	// it covers 3 attempts of 5s with default backoffs between them
+	defaultProcessingTimeout = 16 * time.Second
+
+	// defaultCallbackSignatureTolerance is used when tolerance of callback signature timestamp isn't set in config
+	defaultCallbackSignatureTolerance = 5 * time.Minute
+
+	// defaultIdempotencyKeyTTL is used when TTL of idempotency keys isn't set in config
+	defaultIdempotencyKeyTTL = 24 * time.Hour
+)
+
 // Server is a struct that holds http.Server and other dependencies of the app.
 type Server struct {
 	httpServer *http.Server
-	database   *db.DB
+	database   db.Storage
 	cli        *client.Client
 
+	// inbox is a queue of accepted callbacks waiting to be processed,
+	// pending holds ids of callbacks that are queued or being processed
+	inbox     chan *db.Callback
+	pendingMu sync.Mutex
+	pending   map[int64]struct{}
+
+	// callbackSecrets are secrets that callbacks signatures are verified with, it's empty if verification is disabled
+	callbackSecrets [][]byte
+
+	// auth authenticates requests to read and admin routes, it's nil if authentication is disabled
+	auth *authenticator
+
+	// deferred is a queue of ids which status couldn't be fetched in time, it's nil if queue is disabled
+	deferred *deferredQueue
+
+	// ready is 1 when server can accept traffic, it's reset to 0 on close
+	ready int32
+
+	// background tracks goroutines processing callbacks, so they are finished before server is closed,
+	// closing is set when server stops starting new ones
+	backgroundMu sync.Mutex
+	background   sync.WaitGroup
+	closing      bool
+
 	conf *Config
 }
 
 // New constructs new server instance.
-func New(conf *Config, database *db.DB, cli *client.Client) *Server {
+func New(conf *Config, database db.Storage, cli *client.Client) *Server {
 	srv := &Server{}
 
 	srv.httpServer = &http.Server{
@@ -41,28 +132,149 @@ func New(conf *Config, database *db.DB, cli *client.Client) *Server {
 
 	srv.database = database
 	srv.cli = cli
+
+	if conf.InboxReplayInterval <= 0 {
+		conf.InboxReplayInterval = defaultInboxReplayInterval
+	}
+	if conf.InboxReplayMinAge <= 0 {
+		conf.InboxReplayMinAge = defaultInboxReplayMinAge
+	}
+	if conf.ProcessingTimeout <= 0 {
+		conf.ProcessingTimeout = defaultProcessingTimeout
+	}
+	if conf.IdempotencyKeyTTL <= 0 {
+		conf.IdempotencyKeyTTL = defaultIdempotencyKeyTTL
+	}
+	if conf.CallbackSignatureTolerance <= 0 {
+		conf.CallbackSignatureTolerance = defaultCallbackSignatureTolerance
+	}
+	for _, secret := range conf.CallbackSecrets {
+		if secret != "" {
+			srv.callbackSecrets = append(srv.callbackSecrets, []byte(secret))
+		}
+	}
+	srv.auth = newAuthenticator(conf.Auth)
+	srv.inbox = make(chan *db.Callback, conf.InboxQueueSize)
+	srv.pending = make(map[int64]struct{})
+
+	if conf.Deferred.Size > 0 {
+		if conf.Deferred.Interval <= 0 {
+			conf.Deferred.Interval = defaultDeferredInterval
+		}
+		srv.deferred = newDeferredQueue(conf.Deferred.Size)
+	}
+
 	srv.conf = conf
 
+	atomic.StoreInt32(&srv.ready, 1)
+
 	return srv
 }
 
-// Start spins up a server in a separate goroutine and serves incoming requests.
+// Start spins up a server in a separate goroutine and serves incoming requests,
+// it serves https if certificate is set in config, certificate is reloaded when its files change.
+// Server doesn't start if read and admin routes have no credentials and authentication isn't disabled explicitly,
+// or if failed callbacks would be too old to be processed once they are replayed,
+// or if processing timeout doesn't leave time for retries of lookups.
 // Start is blocking function, so run it in a separate goroutine or it will block execution of your code.
 func (srv *Server) Start(errChan chan<- error) {
-	if err := srv.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := srv.validateConfig(); err != nil {
+		errChan <- err
+		return
+	}
+
+	tlsConf, err := srv.newTLSConfig()
+	if err != nil {
+		errChan <- errors.WithMessage(err, "failed to configure tls")
+		return
+	}
+
+	if tlsConf == nil {
+		err = srv.httpServer.ListenAndServe()
+	} else {
+		srv.httpServer.TLSConfig = tlsConf
+		err = srv.httpServer.ListenAndServeTLS("", "")
+	}
+	if err != nil && err != http.ErrServerClosed {
 		errChan <- errors.WithMessage(err, "failed to listen and serve")
 	}
 }
 
-// Close closes the server.
-func (srv *Server) Close() error {
-	ctx, cancel := context.WithTimeout(context.Background(), srv.conf.ShutdownTimeout)
-	defer cancel()
+// validateConfig checks that settings of server don't contradict each other and settings of tester service client
+func (srv *Server) validateConfig() error {
+	if err := srv.conf.validate(); err != nil {
+		return err
+	}
+
+	// otherwise retries of lookups never happen, as processing of callback times out first
+	if srv.cli != nil && srv.conf.ProcessingTimeout < srv.cli.LookupTimeout() {
+		return errors.Errorf("processing timeout %s is shorter than %s that lookup takes with all retries", srv.conf.ProcessingTimeout, srv.cli.LookupTimeout())
+	}
+
+	return nil
+}
+
+// validate checks that settings don't contradict each other
+func (conf *Config) validate() error {
+	if err := conf.Auth.validate(); err != nil {
+		return errors.WithMessage(err, "failed to configure authentication")
+	}
+
+	// callback that failed to be processed is replayed at most min age plus replay interval after it was received,
+	// so it must be processed then rather than dropped as too old
+	if conf.InboxMaxReplayAge > 0 && conf.InboxReplayMinAge+conf.InboxReplayInterval >= conf.InboxMaxReplayAge {
+		return errors.Errorf("inbox replay min age %s plus replay interval %s must be shorter than max replay age %s",
+			conf.InboxReplayMinAge, conf.InboxReplayInterval, conf.InboxMaxReplayAge)
+	}
+
+	return nil
+}
+
+// Close closes the server until context is done, context deadline should be the deadline of the whole shutdown.
+// Server is reported as not ready first, and keeps serving requests for readiness drain delay.
+// Then it stops accepting requests and waits for callbacks being processed,
+// callbacks that aren't processed by then stay in the inbox and are replayed after restart.
+func (srv *Server) Close(ctx context.Context) error {
+	atomic.StoreInt32(&srv.ready, 0)
+
+	drain := time.NewTimer(srv.conf.ReadinessDrainDelay)
+	select {
+	case <-drain.C:
+	case <-ctx.Done():
+		drain.Stop()
+	}
 
 	// gracefully shutdown the server
 	if err := srv.httpServer.Shutdown(ctx); err != nil {
+		srv.backgroundMu.Lock()
+		srv.closing = true
+		srv.backgroundMu.Unlock()
 		return errors.WithMessage(err, "failed to shutdown server gracefully")
 	}
 
+	// don't start processing of new callbacks, and wait for ones in progress
+	srv.backgroundMu.Lock()
+	srv.closing = true
+	srv.backgroundMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		srv.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		srv.pendingMu.Lock()
+		n := len(srv.pending)
+		srv.pendingMu.Unlock()
+		return errors.Errorf("%d callbacks weren't processed before shutdown timeout, they will be replayed from inbox", n)
+	}
+
+	if n := len(srv.inbox); n > 0 {
+		log.Logger.Info().Int("count", n).Msg("callbacks left in queue will be replayed from inbox")
+	}
+
 	return nil
 }
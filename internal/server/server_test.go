@@ -1,11 +1,38 @@
 package server
 
 import (
+	"bitburst-assessment-task/internal/client"
+	"bitburst-assessment-task/internal/db"
+	"bitburst-assessment-task/internal/signature"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
 	"github.com/google/go-cmp/cmp"
@@ -63,6 +90,7 @@ func TestServerStartAndClose(t *testing.T) {
 				srv: &Server{
 					conf: &Config{
 						ShutdownTimeout: 2 * time.Second,
+						Auth:            AuthConfig{Disabled: true},
 					},
 					httpServer: &http.Server{
 						Addr: "0.0.0.0:9090",
@@ -86,10 +114,1120 @@ func TestServerStartAndClose(t *testing.T) {
 
 			select {
 			case <-ctx.Done():
-				require.Nil(t, tc.input.srv.Close())
+				closeCtx, closeCancel := context.WithTimeout(context.Background(), tc.input.srv.conf.ShutdownTimeout)
+				defer closeCancel()
+				require.Nil(t, tc.input.srv.Close(closeCtx))
 			case err := <-tc.input.errChan:
 				require.Fail(t, err.Error(), "failed to start server")
 			}
 		})
 	}
 }
+
+func TestParseListObjectsFilter(t *testing.T) {
+	online := true
+	seenAfter := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	cursor := int32(42)
+
+	tests := map[string]struct {
+		query   string
+		want    *db.ListObjectsFilter
+		wantErr bool
+	}{
+		"default": {
+			query: "",
+			want:  &db.ListObjectsFilter{Limit: defaultObjectsLimit},
+		},
+		"all filters": {
+			query: "online=true&seen_after=2021-05-01T10:00:00Z&cursor=42&limit=10",
+			want: &db.ListObjectsFilter{
+				Online:    &online,
+				SeenAfter: &seenAfter,
+				After:     &cursor,
+				Limit:     10,
+			},
+		},
+		"invalid online": {
+			query:   "online=maybe",
+			wantErr: true,
+		},
+		"invalid seen_before": {
+			query:   "seen_before=yesterday",
+			wantErr: true,
+		},
+		"limit too big": {
+			query:   "limit=100000",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/objects?"+tc.query, nil)
+
+			got, err := parseListObjectsFilter(r)
+			if tc.wantErr {
+				require.NotNil(t, err, "expected error for invalid query")
+				return
+			}
+			require.Nil(t, err, "failed to parse query")
+
+			diff := cmp.Diff(tc.want, got)
+			require.Empty(t, diff, "failed to get same filter")
+		})
+	}
+}
+
+func TestHandleCallbackValidation(t *testing.T) {
+	srv := New(&Config{MaxBodySize: 64, MaxObjectIDs: 3}, nil, nil)
+
+	tests := map[string]struct {
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		"wrong method": {
+			method:      http.MethodGet,
+			contentType: "application/json",
+			wantStatus:  http.StatusMethodNotAllowed,
+		},
+		"wrong content type": {
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			body:        `{"object_ids":[1,2]}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		"malformed body": {
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"object_ids":[1,2`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		"body too large": {
+			method:      http.MethodPost,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"object_ids":[1,2,3],"padding":"` + strings.Repeat("a", 64) + `"}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		"missing object ids": {
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		"too many object ids": {
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"object_ids":[1,2,3,4]}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		"negative object id": {
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"object_ids":[1,-2]}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/callback", strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			rw := httptest.NewRecorder()
+
+			srv.handleCallback(rw, r)
+
+			require.Equal(t, tc.wantStatus, rw.Code, "got unexpected status code")
+			require.Equal(t, "application/json", rw.Header().Get("Content-Type"), "error response should be json")
+		})
+	}
+}
+
+func TestHandleCallbackAccepted(t *testing.T) {
+	zlog := zerolog.Nop()
+	database, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	srv := New(&Config{InboxQueueSize: 1}, database, nil)
+
+	r := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(`{"object_ids":[1,2,3]}`))
+	r.Header.Set("Content-Type", "application/json")
+	rw := httptest.NewRecorder()
+
+	srv.handleCallback(rw, r)
+
+	require.Equal(t, http.StatusOK, rw.Code, "got unexpected status code")
+
+	// callback must be stored in inbox before it's acknowledged
+	callbacks, err := database.UnprocessedCallbacks(context.Background(), 0, 10)
+	require.NoError(t, err)
+	require.Len(t, callbacks, 1)
+	require.Equal(t, []int32{1, 2, 3}, callbacks[0].ObjectIDs)
+
+	require.Len(t, srv.inbox, 1, "callback should be queued for processing")
+}
+
+// unreachableStorage is a storage which ping fails with an error that has connection details
+type unreachableStorage struct {
+	db.Storage
+}
+
+func (s *unreachableStorage) Ping(ctx context.Context) error {
+	return errors.New("failed to connect to `host=db.internal user=postgres database=postgres`")
+}
+
+func TestHandleReadiness(t *testing.T) {
+	zlog := zerolog.Nop()
+	memory, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tester.Close()
+
+	cli := client.New(&client.Config{TesterServiceAddress: tester.URL}, &zlog)
+	srv := New(&Config{}, &unreachableStorage{Storage: memory}, cli)
+
+	status, resp := srv.checkReadiness(context.Background())
+	require.Equal(t, http.StatusServiceUnavailable, status, "got unexpected status code")
+
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"status":"not ready","checks":{"database":{"status":"failed"},"migrations":{"status":"ok"},"tester_service":{"status":"ok"}}}`,
+		string(body))
+}
+
+func TestHandleClientCache(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1,"online":true}`))
+	}))
+	defer tester.Close()
+
+	cli := client.New(&client.Config{TesterServiceAddress: tester.URL, Cache: client.CacheConfig{TTL: time.Minute}}, &zlog)
+	_ = cli.Do(context.Background(), []int32{1})
+	_ = cli.Do(context.Background(), []int32{1})
+
+	srv := New(&Config{Auth: AuthConfig{Disabled: true}}, nil, cli)
+
+	rw := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/client/cache", nil))
+	require.Equal(t, http.StatusOK, rw.Code, "got unexpected status code")
+
+	var stats client.CacheStats
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &stats))
+	require.Equal(t, client.CacheStats{Hits: 1, Misses: 1, Size: 1}, stats)
+}
+
+func TestHandleObject(t *testing.T) {
+	zlog := zerolog.Nop()
+	database, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	_, _, err = database.InsertObjectsOrUpdate(context.Background(), 0, []int32{1}, []int32{2})
+	require.NoError(t, err)
+
+	srv := New(&Config{Auth: AuthConfig{Disabled: true}}, database, nil)
+
+	tests := map[string]struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		"online object": {
+			path:       "/objects/1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"online":true`,
+		},
+		"offline object": {
+			path:       "/objects/2",
+			wantStatus: http.StatusOK,
+			wantBody:   `"online":false`,
+		},
+		"unknown object": {
+			path:       "/objects/3",
+			wantStatus: http.StatusNotFound,
+		},
+		"invalid id": {
+			path:       "/objects/abc",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+
+			srv.httpServer.Handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			require.Equal(t, tc.wantStatus, rw.Code, "got unexpected status code")
+			require.Contains(t, rw.Body.String(), tc.wantBody)
+		})
+	}
+}
+
+func TestProcessCallbackCircuitOpen(t *testing.T) {
+	zlog := zerolog.Nop()
+	database, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	var requests int32
+	tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer tester.Close()
+
+	cli := client.New(&client.Config{
+		TesterServiceAddress: tester.URL,
+		Retry:                client.RetryConfig{MaxAttempts: 1},
+		Breaker:              client.BreakerConfig{FailureRate: 0.5, MinRequests: 1, Window: time.Minute, OpenDuration: time.Hour},
+	}, &zlog)
+
+	// open the breaker
+	_ = cli.Do(context.Background(), []int32{1})
+	require.Equal(t, client.BreakerOpen, cli.Breaker().State)
+
+	srv := New(&Config{}, database, cli)
+
+	id, err := database.SaveCallback(context.Background(), []int32{1, 2, 3})
+	require.NoError(t, err)
+
+	srv.processCallback(&db.Callback{ID: id, ObjectIDs: []int32{1, 2, 3}})
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests), "objects were looked up while circuit is open")
+
+	callbacks, err := database.UnprocessedCallbacks(context.Background(), 0, 10)
+	require.NoError(t, err)
+	require.Len(t, callbacks, 1, "callback should stay in the inbox while circuit is open")
+}
+
+// agedInbox is a storage which inbox entries with given ids look like they were received age ago
+type agedInbox struct {
+	db.Storage
+	ids map[int64]struct{}
+	age time.Duration
+}
+
+func (s *agedInbox) UnprocessedCallbacks(ctx context.Context, minAge time.Duration, limit int32) ([]*db.Callback, error) {
+	callbacks, err := s.Storage.UnprocessedCallbacks(ctx, 0, limit)
+	aged := callbacks[:0]
+	for _, c := range callbacks {
+		if _, ok := s.ids[c.ID]; ok {
+			c.ReceivedAt = c.ReceivedAt.Add(-s.age)
+		}
+		if time.Since(c.ReceivedAt) >= minAge {
+			aged = append(aged, c)
+		}
+	}
+	return aged, err
+}
+
+func TestReplayOldCallbacks(t *testing.T) {
+	zlog := zerolog.Nop()
+	memory, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	var requests int32
+	tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/objects/"))
+		require.NoError(t, err)
+		w.Write([]byte(fmt.Sprintf(`{"id":%d,"online":true}`, id)))
+	}))
+	defer tester.Close()
+
+	// callback received before the outage is older than retention of objects
+	oldID, err := memory.SaveCallback(context.Background(), []int32{1, 2})
+	require.NoError(t, err)
+	_, err = memory.SaveCallback(context.Background(), []int32{3})
+	require.NoError(t, err)
+	database := &agedInbox{Storage: memory, ids: map[int64]struct{}{oldID: {}}, age: time.Hour}
+
+	cli := client.New(&client.Config{TesterServiceAddress: tester.URL, Retry: client.RetryConfig{MaxAttempts: 1}}, &zlog)
+	srv := New(&Config{InboxMaxReplayAge: time.Minute}, database, cli)
+
+	srv.replayCallbacks(context.Background(), 0)
+	srv.background.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests), "objects of old callback were looked up")
+
+	_, err = memory.GetObject(context.Background(), 1)
+	require.Equal(t, db.ErrObjectNotFound, err, "old callback brought back an object")
+	_, err = memory.GetObject(context.Background(), 3)
+	require.NoError(t, err)
+
+	callbacks, err := memory.UnprocessedCallbacks(context.Background(), 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, callbacks, "old callback must be marked as processed, so it isn't replayed again")
+}
+
+func TestReplayWithDefaultConfig(t *testing.T) {
+	zlog := zerolog.Nop()
+	memory, err := db.NewMemory(&db.Config{Retention: time.Second * 30, SweepInterval: time.Second * 30}, &zlog)
+	require.NoError(t, err)
+
+	tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/objects/"))
+		require.NoError(t, err)
+		w.Write([]byte(fmt.Sprintf(`{"id":%d,"online":true}`, id)))
+	}))
+	defer tester.Close()
+
+	// max replay age defaults to database retention
+	cli := client.New(&client.Config{TesterServiceAddress: tester.URL, Retry: client.RetryConfig{MaxAttempts: 1}}, &zlog)
+	conf := &Config{InboxMaxReplayAge: time.Second * 30, Auth: AuthConfig{Disabled: true}}
+	srv := New(conf, nil, cli)
+	require.NoError(t, conf.validate())
+
+	// callback that didn't fit into the queue is picked up by the latest tick after it was received
+	id, err := memory.SaveCallback(context.Background(), []int32{1})
+	require.NoError(t, err)
+	srv.database = &agedInbox{Storage: memory, ids: map[int64]struct{}{id: {}}, age: conf.InboxReplayMinAge + conf.InboxReplayInterval - time.Second}
+
+	srv.replayCallbacks(context.Background(), conf.InboxReplayMinAge)
+	srv.background.Wait()
+
+	_, err = memory.GetObject(context.Background(), 1)
+	require.NoError(t, err, "replayed callback wasn't processed")
+
+	callbacks, err := memory.UnprocessedCallbacks(context.Background(), 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, callbacks)
+
+	// replay interval as long as max replay age would drop callbacks instead of replaying them
+	conf.InboxReplayInterval = conf.InboxMaxReplayAge
+	require.Error(t, conf.validate())
+}
+
+func TestValidateProcessingTimeout(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	// default retry policy of client
+	cli := client.New(&client.Config{
+		Retry: client.RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond * 100, MaxBackoff: time.Second},
+	}, &zlog)
+
+	srv := New(&Config{Auth: AuthConfig{Disabled: true}}, nil, cli)
+	require.NoError(t, srv.validateConfig(), "default processing timeout doesn't cover retries of lookups")
+
+	// the first attempt would use the whole processing timeout
+	srv = New(&Config{ProcessingTimeout: time.Second * 5, Auth: AuthConfig{Disabled: true}}, nil, cli)
+	errChan := make(chan error, 1)
+	srv.Start(errChan)
+	require.Error(t, <-errChan)
+}
+
+func TestProcessDeferredLookups(t *testing.T) {
+	zlog := zerolog.Nop()
+	database, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	// tester service is slow for the first lookups of objects
+	var slow int32 = 1
+	tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&slow) == 1 {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/objects/"))
+		require.NoError(t, err)
+		w.Write([]byte(fmt.Sprintf(`{"id":%d,"online":%v}`, id, id%2 == 0)))
+	}))
+	defer tester.Close()
+
+	cli := client.New(&client.Config{TesterServiceAddress: tester.URL, Retry: client.RetryConfig{MaxAttempts: 1}}, &zlog)
+	srv := New(&Config{Deferred: DeferredConfig{Size: 2, MaxAge: time.Minute}}, database, cli)
+
+	id, err := database.SaveCallback(context.Background(), []int32{1, 2, 3})
+	require.NoError(t, err)
+
+	srv.processCallback(&db.Callback{ID: id, ObjectIDs: []int32{1, 2, 3}})
+
+	// callback is done, objects that don't fit in the queue are dropped
+	callbacks, err := database.UnprocessedCallbacks(context.Background(), 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, callbacks)
+	require.Len(t, srv.deferred.list(), 2)
+
+	srv.processDeferredLookups()
+	require.Len(t, srv.deferred.list(), 2, "ids were dropped while tester service is slow")
+
+	atomic.StoreInt32(&slow, 0)
+	srv.processDeferredLookups()
+	require.Empty(t, srv.deferred.list(), "ids weren't removed after their status was stored")
+
+	objs, err := database.ListObjects(context.Background(), &db.ListObjectsFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, objs, 2)
+	for _, obj := range objs {
+		require.Equal(t, obj.OID%2 == 0, obj.Online)
+	}
+}
+
+// deadlineStorage is a storage which fails writes when context is done, like a database does
+type deadlineStorage struct {
+	db.Storage
+}
+
+func (s *deadlineStorage) InsertObjectsOrUpdate(ctx context.Context, callbackID int64, onlineIDs []int32, offlineIDs []int32) ([]int32, []int32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return s.Storage.InsertObjectsOrUpdate(ctx, callbackID, onlineIDs, offlineIDs)
+}
+
+func TestProcessSlowLookups(t *testing.T) {
+	zlog := zerolog.Nop()
+	memory, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+	database := &deadlineStorage{Storage: memory}
+
+	// lookups of objects starting from slowFrom take the whole processing timeout
+	var slowFrom int32 = 2
+	tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/objects/"))
+		require.NoError(t, err)
+
+		for int32(id) >= atomic.LoadInt32(&slowFrom) {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Millisecond * 10):
+			}
+		}
+
+		w.Write([]byte(fmt.Sprintf(`{"id":%d,"online":true}`, id)))
+	}))
+	defer tester.Close()
+
+	cli := client.New(&client.Config{TesterServiceAddress: tester.URL, Timeout: time.Millisecond * 500, Retry: client.RetryConfig{MaxAttempts: 1}}, &zlog)
+	srv := New(&Config{ProcessingTimeout: time.Millisecond * 100, Deferred: DeferredConfig{Size: 10, MaxAge: time.Minute}}, database, cli)
+
+	id, err := memory.SaveCallback(context.Background(), []int32{1, 2, 3})
+	require.NoError(t, err)
+
+	srv.processCallback(&db.Callback{ID: id, ObjectIDs: []int32{1, 2, 3}})
+
+	_, err = memory.GetObject(context.Background(), 1)
+	require.NoError(t, err, "fetched status wasn't stored after lookups consumed the timeout")
+	require.Len(t, srv.deferred.list(), 2, "timed out lookups weren't deferred")
+
+	callbacks, err := memory.UnprocessedCallbacks(context.Background(), 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, callbacks)
+
+	atomic.StoreInt32(&slowFrom, 3)
+	srv.processDeferredLookups()
+
+	_, err = memory.GetObject(context.Background(), 2)
+	require.NoError(t, err, "fetched status of deferred object wasn't stored after lookups consumed the timeout")
+	require.Len(t, srv.deferred.list(), 1)
+}
+
+func TestDeferredQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deferred.json")
+	now := time.Now()
+
+	q := newDeferredQueue(3)
+	require.Equal(t, 1, q.add(1, []int32{1, 2, 3, 4}, now.Add(-time.Hour)))
+	require.Equal(t, 0, q.add(2, []int32{3}, now), "queued id should be skipped")
+
+	// nothing is persisted until queue changes again
+	require.NoError(t, q.save(path))
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, q.save(path))
+	require.NoFileExists(t, path)
+
+	q.remove([]int32{1})
+	require.NoError(t, q.save(path))
+
+	loaded := newDeferredQueue(3)
+	require.NoError(t, loaded.load(path))
+
+	lookups := loaded.list()
+	sort.Slice(lookups, func(i, j int) bool { return lookups[i].ID < lookups[j].ID })
+	require.Len(t, lookups, 2)
+	for i, l := range lookups {
+		require.Equal(t, int32(i+2), l.ID)
+		require.Equal(t, int64(1), l.CallbackID)
+		require.True(t, l.DeferredAt.Equal(now.Add(-time.Hour)), "age of id wasn't persisted")
+	}
+
+	expired := loaded.expire(time.Minute, now)
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	require.Equal(t, []int32{2, 3}, expired)
+	require.Empty(t, loaded.list())
+
+	require.NoError(t, newDeferredQueue(1).load(filepath.Join(t.TempDir(), "missing.json")), "missing file isn't an error")
+}
+
+func TestCloseWaitsForCallbacks(t *testing.T) {
+	zlog := zerolog.Nop()
+
+	tests := map[string]struct {
+		shutdownTimeout time.Duration
+		wantErr         bool
+		wantUnprocessed int
+	}{
+		"callback is processed": {
+			shutdownTimeout: 5 * time.Second,
+			wantUnprocessed: 1, // callback received after close began
+		},
+		"shutdown timeout": {
+			shutdownTimeout: 50 * time.Millisecond,
+			wantErr:         true,
+			wantUnprocessed: 2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			database, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+			require.NoError(t, err)
+
+			tester := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(300 * time.Millisecond)
+				w.Write([]byte(`{"id":1,"online":true}`))
+			}))
+			defer tester.Close()
+
+			cli := client.New(&client.Config{TesterServiceAddress: tester.URL}, &zlog)
+			srv := New(&Config{ShutdownTimeout: tc.shutdownTimeout}, database, cli)
+
+			id, err := database.SaveCallback(context.Background(), []int32{1})
+			require.NoError(t, err)
+			require.True(t, srv.trackCallback(id))
+			srv.goProcessCallback(&db.Callback{ID: id, ObjectIDs: []int32{1}})
+
+			closeCtx, closeCancel := context.WithTimeout(context.Background(), tc.shutdownTimeout)
+			defer closeCancel()
+			err = srv.Close(closeCtx)
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// callbacks aren't processed once server is closed
+			id, err = database.SaveCallback(context.Background(), []int32{1})
+			require.NoError(t, err)
+			require.True(t, srv.trackCallback(id))
+			srv.goProcessCallback(&db.Callback{ID: id, ObjectIDs: []int32{1}})
+
+			callbacks, err := database.UnprocessedCallbacks(context.Background(), 0, 10)
+			require.NoError(t, err)
+			require.Len(t, callbacks, tc.wantUnprocessed)
+		})
+	}
+}
+
+func TestHandleCallbackIdempotency(t *testing.T) {
+	zlog := zerolog.Nop()
+	database, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	srv := New(&Config{InboxQueueSize: 10}, database, nil)
+
+	tests := []struct {
+		name         string
+		header       string
+		body         string
+		wantStatus   int
+		wantReplayed bool
+		wantQueued   int
+	}{
+		{
+			name:       "first callback",
+			header:     "key-1",
+			body:       `{"object_ids":[1,2]}`,
+			wantStatus: http.StatusOK,
+			wantQueued: 1,
+		},
+		{
+			name:         "retried callback",
+			header:       "key-1",
+			body:         `{"object_ids":[1,2]}`,
+			wantStatus:   http.StatusOK,
+			wantReplayed: true,
+			wantQueued:   1,
+		},
+		{
+			name:         "retried callback with key in body",
+			body:         `{"object_ids":[1,2],"callback_id":"key-1"}`,
+			wantStatus:   http.StatusOK,
+			wantReplayed: true,
+			wantQueued:   1,
+		},
+		{
+			name:       "key reused with another payload",
+			header:     "key-1",
+			body:       `{"object_ids":[3]}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantQueued: 1,
+		},
+		{
+			name:       "keys don't match",
+			header:     "key-2",
+			body:       `{"object_ids":[3],"callback_id":"key-3"}`,
+			wantStatus: http.StatusBadRequest,
+			wantQueued: 1,
+		},
+		{
+			name:       "callback without key",
+			body:       `{"object_ids":[1,2]}`,
+			wantStatus: http.StatusOK,
+			wantQueued: 2,
+		},
+	}
+
+	// cases depend on each other, so they run in order
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", "application/json")
+			if tc.header != "" {
+				r.Header.Set("Idempotency-Key", tc.header)
+			}
+			rw := httptest.NewRecorder()
+
+			srv.handleCallback(rw, r)
+
+			require.Equal(t, tc.wantStatus, rw.Code, "got unexpected status code")
+			require.Equal(t, tc.wantReplayed, rw.Header().Get("Idempotent-Replayed") == "true", "got unexpected replayed header")
+			require.Len(t, srv.inbox, tc.wantQueued, "got unexpected amount of queued callbacks")
+		})
+	}
+}
+
+func TestHandleCallbackSignature(t *testing.T) {
+	zlog := zerolog.Nop()
+	database, err := db.NewMemory(&db.Config{Retention: time.Minute, SweepInterval: time.Minute}, &zlog)
+	require.NoError(t, err)
+
+	srv := New(&Config{InboxQueueSize: 10, CallbackSecrets: []string{"new", "old"}}, database, nil)
+
+	body := `{"object_ids":[1,2]}`
+	now := time.Now().Unix()
+
+	tests := map[string]struct {
+		timestamp  string
+		signature  string
+		wantStatus int
+	}{
+		"signed with current secret": {
+			timestamp:  strconv.FormatInt(now, 10),
+			signature:  signature.Sign([]byte("new"), now, []byte(body)),
+			wantStatus: http.StatusOK,
+		},
+		"signed with rotated secret": {
+			timestamp:  strconv.FormatInt(now, 10),
+			signature:  signature.Sign([]byte("old"), now, []byte(body)),
+			wantStatus: http.StatusOK,
+		},
+		"not signed": {
+			wantStatus: http.StatusUnauthorized,
+		},
+		"signed with unknown secret": {
+			timestamp:  strconv.FormatInt(now, 10),
+			signature:  signature.Sign([]byte("unknown"), now, []byte(body)),
+			wantStatus: http.StatusUnauthorized,
+		},
+		"signed another body": {
+			timestamp:  strconv.FormatInt(now, 10),
+			signature:  signature.Sign([]byte("new"), now, []byte(`{"object_ids":[3]}`)),
+			wantStatus: http.StatusUnauthorized,
+		},
+		"replayed callback": {
+			timestamp:  strconv.FormatInt(now-3600, 10),
+			signature:  signature.Sign([]byte("new"), now-3600, []byte(body)),
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			if tc.timestamp != "" {
+				r.Header.Set(signature.TimestampHeader, tc.timestamp)
+			}
+			if tc.signature != "" {
+				r.Header.Set(signature.SignatureHeader, tc.signature)
+			}
+			rw := httptest.NewRecorder()
+
+			srv.handleCallback(rw, r)
+
+			require.Equal(t, tc.wantStatus, rw.Code, "got unexpected status code")
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwksPath, map[string]interface{}{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey})
+
+	srv := New(&Config{Auth: AuthConfig{
+		APIKeys: []APIKey{
+			{Name: "reader", Key: "reader-key", Scopes: []string{ScopeObjectsRead}},
+			{Name: "admin", Key: "admin-key", Scopes: []string{ScopeAdmin}},
+		},
+		JWKSPath: jwksPath,
+		Issuer:   "issuer",
+		Audience: "bitburst",
+	}}, nil, nil)
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.Claims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return "Bearer " + signed
+	}
+	claims := func(scope string) *tokenClaims {
+		return &tokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "user",
+				Issuer:    "issuer",
+				Audience:  jwt.ClaimStrings{"bitburst"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Scope: scope,
+		}
+	}
+	expired := claims(ScopeObjectsRead)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	notExpiring := claims(ScopeObjectsRead)
+	notExpiring.ExpiresAt = nil
+	wrongAudience := claims(ScopeObjectsRead)
+	wrongAudience.Audience = jwt.ClaimStrings{"another"}
+
+	tests := map[string]struct {
+		scope         string
+		apiKey        string
+		authorization string
+		wantStatus    int
+	}{
+		"no credentials": {
+			scope:      ScopeObjectsRead,
+			wantStatus: http.StatusUnauthorized,
+		},
+		"api key with scope": {
+			scope:      ScopeObjectsRead,
+			apiKey:     "reader-key",
+			wantStatus: http.StatusOK,
+		},
+		"api key without scope": {
+			scope:      ScopeAdmin,
+			apiKey:     "reader-key",
+			wantStatus: http.StatusForbidden,
+		},
+		"admin api key": {
+			scope:      ScopeObjectsRead,
+			apiKey:     "admin-key",
+			wantStatus: http.StatusOK,
+		},
+		"unknown api key": {
+			scope:      ScopeObjectsRead,
+			apiKey:     "unknown",
+			wantStatus: http.StatusUnauthorized,
+		},
+		"rsa token with scope": {
+			scope:         ScopeObjectsRead,
+			authorization: sign(jwt.SigningMethodRS256, "rsa", rsaKey, claims("profile objects:read")),
+			wantStatus:    http.StatusOK,
+		},
+		"ec token with admin scope": {
+			scope:         ScopeAdmin,
+			authorization: sign(jwt.SigningMethodES256, "ec", ecKey, claims(ScopeAdmin)),
+			wantStatus:    http.StatusOK,
+		},
+		"token without scope": {
+			scope:         ScopeAdmin,
+			authorization: sign(jwt.SigningMethodRS256, "rsa", rsaKey, claims(ScopeObjectsRead)),
+			wantStatus:    http.StatusForbidden,
+		},
+		"token signed with unknown key": {
+			scope:         ScopeObjectsRead,
+			authorization: sign(jwt.SigningMethodRS256, "rsa", otherKey, claims(ScopeObjectsRead)),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"token with unknown key id": {
+			scope:         ScopeObjectsRead,
+			authorization: sign(jwt.SigningMethodRS256, "unknown", rsaKey, claims(ScopeObjectsRead)),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"token signed with hmac": {
+			scope:         ScopeObjectsRead,
+			authorization: sign(jwt.SigningMethodHS256, "rsa", []byte("secret"), claims(ScopeObjectsRead)),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"expired token": {
+			scope:         ScopeObjectsRead,
+			authorization: sign(jwt.SigningMethodRS256, "rsa", rsaKey, expired),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"token without expiration": {
+			scope:         ScopeObjectsRead,
+			authorization: sign(jwt.SigningMethodRS256, "rsa", rsaKey, notExpiring),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"token for another audience": {
+			scope:         ScopeObjectsRead,
+			authorization: sign(jwt.SigningMethodRS256, "rsa", rsaKey, wrongAudience),
+			wantStatus:    http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/objects", nil)
+			if tc.apiKey != "" {
+				r.Header.Set("X-API-Key", tc.apiKey)
+			}
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			rw := httptest.NewRecorder()
+
+			srv.requireScope(tc.scope, func(rw http.ResponseWriter, r *http.Request) {})(rw, r)
+
+			require.Equal(t, tc.wantStatus, rw.Code, "got unexpected status code")
+		})
+	}
+
+	t.Run("rotated keys", func(t *testing.T) {
+		writeJWKS(t, jwksPath, map[string]interface{}{"other": &otherKey.PublicKey})
+		// make sure modification time changes on file systems with coarse timestamps
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(jwksPath, later, later))
+
+		for token, wantStatus := range map[string]int{
+			sign(jwt.SigningMethodRS256, "other", otherKey, claims(ScopeObjectsRead)): http.StatusOK,
+			sign(jwt.SigningMethodRS256, "rsa", rsaKey, claims(ScopeObjectsRead)):     http.StatusUnauthorized,
+		} {
+			r := httptest.NewRequest(http.MethodGet, "/objects", nil)
+			r.Header.Set("Authorization", token)
+			rw := httptest.NewRecorder()
+
+			srv.requireScope(ScopeObjectsRead, func(rw http.ResponseWriter, r *http.Request) {})(rw, r)
+
+			require.Equal(t, wantStatus, rw.Code, "got unexpected status code")
+		}
+	})
+
+	t.Run("auth disabled", func(t *testing.T) {
+		srv := New(&Config{Auth: AuthConfig{Disabled: true}}, nil, nil)
+		rw := httptest.NewRecorder()
+
+		srv.requireScope(ScopeAdmin, func(rw http.ResponseWriter, r *http.Request) {})(rw, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+
+		require.Equal(t, http.StatusOK, rw.Code, "got unexpected status code")
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		srv := New(&Config{}, nil, nil)
+
+		// server doesn't start, and routes aren't served without authentication anyway
+		errChan := make(chan error, 1)
+		srv.Start(errChan)
+		require.Equal(t, errNoCredentials, errors.Cause(<-errChan))
+
+		rw := httptest.NewRecorder()
+		srv.requireScope(ScopeAdmin, func(rw http.ResponseWriter, r *http.Request) {})(rw, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+
+		require.Equal(t, http.StatusUnauthorized, rw.Code, "got unexpected status code")
+	})
+}
+
+// writeJWKS writes public keys to JSON Web Key Set file
+func writeJWKS(t *testing.T, path string, keys map[string]interface{}) {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	for kid, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig",
+				N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, jsonWebKey{Kty: "EC", Kid: kid, Crv: "P-256",
+				X: encode(key.X.Bytes()), Y: encode(key.Y.Bytes())})
+		}
+	}
+
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCert(t, "ca", nil, nil)
+	writePEM(t, filepath.Join(dir, "ca.pem"), ca, nil)
+	serverCert, serverKey := newTestCert(t, "server", ca, caKey)
+	writePEM(t, filepath.Join(dir, "server.pem"), serverCert, serverKey)
+	clientCert, clientKey := newTestCert(t, "client", ca, caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientPair := tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}
+
+	// serve starts server with TLS config of conf, and returns its address
+	serve := func(t *testing.T, conf TLSConfig) string {
+		srv := New(&Config{TLS: conf}, nil, nil)
+		tlsConf, err := srv.newTLSConfig()
+		require.NoError(t, err)
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go func() { _ = srv.httpServer.Serve(tls.NewListener(ln, tlsConf)) }()
+		t.Cleanup(func() { _ = srv.httpServer.Shutdown(context.Background()) })
+
+		return ln.Addr().String()
+	}
+	// get requests /healthz and returns common name of server certificate
+	get := func(addr string, clientConf *tls.Config) (string, error) {
+		cli := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConf}, Timeout: time.Second}
+		resp, err := cli.Get("https://" + addr + "/healthz")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	conf := TLSConfig{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+
+	t.Run("client certificate required", func(t *testing.T) {
+		addr := serve(t, conf)
+
+		_, err := get(addr, &tls.Config{RootCAs: roots})
+		require.Error(t, err, "client without certificate must be rejected")
+
+		cn, err := get(addr, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientPair}})
+		require.NoError(t, err)
+		require.Equal(t, "server", cn)
+	})
+
+	t.Run("client certificate verified if given", func(t *testing.T) {
+		optional := conf
+		optional.ClientAuth = "verify_if_given"
+		addr := serve(t, optional)
+
+		_, err := get(addr, &tls.Config{RootCAs: roots})
+		require.NoError(t, err)
+
+		// certificate that isn't signed by CA is rejected, it's sent explicitly,
+		// because client doesn't send certificate that doesn't match CAs accepted by server
+		selfSigned, selfSignedKey := newTestCert(t, "client", nil, nil)
+		_, err = get(addr, &tls.Config{RootCAs: roots, GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &tls.Certificate{Certificate: [][]byte{selfSigned.Raw}, PrivateKey: selfSignedKey}, nil
+		}})
+		require.Error(t, err)
+	})
+
+	t.Run("min version", func(t *testing.T) {
+		tls13 := conf
+		tls13.ClientCAFile, tls13.MinVersion = "", "1.3"
+		addr := serve(t, tls13)
+
+		_, err := get(addr, &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12})
+		require.Error(t, err, "client with older TLS version must be rejected")
+
+		_, err = get(addr, &tls.Config{RootCAs: roots})
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		for name, conf := range map[string]TLSConfig{
+			"missing key":            {CertFile: filepath.Join(dir, "server.pem"), KeyFile: filepath.Join(dir, "missing.pem")},
+			"unsupported version":    {CertFile: conf.CertFile, KeyFile: conf.KeyFile, MinVersion: "2.0"},
+			"ca without certificate": {ClientCAFile: conf.ClientCAFile},
+		} {
+			_, err := New(&Config{TLS: conf}, nil, nil).newTLSConfig()
+			require.Error(t, err, name)
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		addr := serve(t, conf)
+		clientConf := &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientPair}}
+
+		renewed, renewedKey := newTestCert(t, "renewed", ca, caKey)
+		writePEM(t, filepath.Join(dir, "server.pem"), renewed, renewedKey)
+
+		require.Eventually(t, func() bool {
+			cn, err := get(addr, clientConf)
+			return err == nil && cn == "renewed"
+		}, 5*time.Second, 50*time.Millisecond, "renewed certificate must be served")
+
+		// invalid file doesn't replace loaded certificate
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "server.pem"), []byte("invalid"), 0600))
+		time.Sleep(3 * certReloadDelay)
+		cn, err := get(addr, clientConf)
+		require.NoError(t, err)
+		require.Equal(t, "renewed", cn)
+	})
+}
+
+// newTestCert generates certificate for 127.0.0.1 signed by parent, or self-signed CA if parent is nil
+func newTestCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+// writePEM writes certificate and its key if it's set to a file, file is replaced by rename like certificate managers do
+func writePEM(t *testing.T, path string, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if key != nil {
+		der, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})...)
+	}
+
+	require.NoError(t, writeFileAtomically(path, data))
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bitburst-assessment-task/internal/metrics"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// TLSConfig holds settings of TLS on http listener, server listens plain http if certificate isn't set
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+
+	// MinVersion is a min TLS version accepted from clients: 1.0, 1.1, 1.2 or 1.3, default is 1.2
+	MinVersion string `mapstructure:"min_version"`
+
+	// ClientCAFile is a CA bundle that client certificates are verified against, clients aren't asked for certificates if it's empty
+	ClientCAFile string `mapstructure:"client_ca_file"`
+
+	// ClientAuth is a policy of client certificates when CA bundle is set:
+	// require (default) rejects clients without valid certificate, verify_if_given accepts clients without certificate
+	ClientAuth string `mapstructure:"client_auth"`
+}
+
+// client certificates policies
+const (
+	clientAuthRequire       = "require"
+	clientAuthVerifyIfGiven = "verify_if_given"
+)
+
+// certReloadDelay is how long reload waits after files change, so certificate and key replaced one by one are loaded together
+const certReloadDelay = 100 * time.Millisecond
+
+// tlsVersions maps config values to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// certReloader holds certificate and client CA pool loaded from files, and loads them again when files change,
+// so certificates can be renewed without restart
+type certReloader struct {
+	conf TLSConfig
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// newCertReloader loads certificate and client CA pool
+func newCertReloader(conf TLSConfig) (*certReloader, error) {
+	r := &certReloader{conf: conf}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// reload loads files again, previously loaded certificate is kept if any of files is invalid
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile)
+	if err != nil {
+		return errors.WithMessage(err, "failed to load certificate")
+	}
+
+	var clientCAs *x509.CertPool
+	if r.conf.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.conf.ClientCAFile)
+		if err != nil {
+			return errors.WithMessage(err, "failed to read client CA bundle")
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("client CA bundle doesn't have any certificate")
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs = &cert, clientCAs
+	r.mu.Unlock()
+
+	return nil
+}
+
+// getCertificate returns the current certificate, it's used as tls.Config.GetCertificate
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// tlsConfig returns TLS config of the listener, config is built for every connection,
+// so it has the current client CA pool
+func (r *certReloader) tlsConfig() (*tls.Config, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if r.conf.MinVersion != "" {
+		v, ok := tlsVersions[r.conf.MinVersion]
+		if !ok {
+			return nil, errors.Errorf("unsupported min tls version %q", r.conf.MinVersion)
+		}
+		minVersion = v
+	}
+
+	clientAuth := tls.NoClientCert
+	if r.conf.ClientCAFile != "" {
+		switch r.conf.ClientAuth {
+		case "", clientAuthRequire:
+			clientAuth = tls.RequireAndVerifyClientCert
+		case clientAuthVerifyIfGiven:
+			clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, errors.Errorf("unsupported client auth %q", r.conf.ClientAuth)
+		}
+	}
+
+	base := &tls.Config{
+		MinVersion:     minVersion,
+		ClientAuth:     clientAuth,
+		GetCertificate: r.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	conf := base.Clone()
+	conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		c := base.Clone()
+		c.ClientCAs = r.clientCAs
+		return c, nil
+	}
+
+	return conf, nil
+}
+
+// watch reloads files when they change until done is closed. Directories of files are watched,
+// so files replaced by rename or by swapping a symlink, like kubernetes does with mounted secrets, are reloaded too.
+func (r *certReloader) watch(done <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.WithMessage(err, "failed to create files watcher")
+	}
+
+	dirs := map[string]struct{}{}
+	for _, path := range []string{r.conf.CertFile, r.conf.KeyFile, r.conf.ClientCAFile} {
+		if path != "" {
+			dirs[filepath.Dir(path)] = struct{}{}
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return errors.WithMessagef(err, "failed to watch %s", dir)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		// reload is delayed until files stop changing
+		delay := time.NewTimer(certReloadDelay)
+		delay.Stop()
+		defer delay.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove) != 0 {
+					delay.Reset(certReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Logger.Warn().Err(err).Msg("failed to watch tls files")
+			case <-delay.C:
+				if err := r.reload(); err != nil {
+					metrics.TLSReloads.WithLabelValues("failure").Inc()
+					log.Logger.Err(err).Msg("failed to reload tls files, keeping previous certificate")
+					continue
+				}
+				metrics.TLSReloads.WithLabelValues("success").Inc()
+				log.Logger.Info().Str("cert_file", r.conf.CertFile).Msg("reloaded tls files")
+			}
+		}
+	}()
+
+	return nil
+}
+
+// newTLSConfig loads TLS files and starts watching them until server is shut down,
+// it returns nil if TLS isn't configured
+func (srv *Server) newTLSConfig() (*tls.Config, error) {
+	if srv.conf.TLS.CertFile == "" && srv.conf.TLS.KeyFile == "" {
+		if srv.conf.TLS.ClientCAFile != "" {
+			return nil, errors.New("client CA bundle is set without server certificate")
+		}
+		return nil, nil
+	}
+
+	reloader, err := newCertReloader(srv.conf.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := reloader.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	srv.httpServer.RegisterOnShutdown(func() { once.Do(func() { close(done) }) })
+
+	if err := reloader.watch(done); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
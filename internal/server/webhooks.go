@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bitburst-assessment-task/internal/db"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultDeadLettersLimit is an amount of dead letters returned when limit isn't specified
+const defaultDeadLettersLimit = 100
+
+type webhookReqBody struct {
+	URL    string         `json:"url"`
+	Secret string         `json:"secret"`
+	Events []db.EventType `json:"events"`
+}
+
+// validate checks that url is an absolute http(s) url and that all events are known
+func (b *webhookReqBody) validate() error {
+	u, err := url.Parse(b.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https url")
+	}
+
+	for _, e := range b.Events {
+		known := false
+		for _, t := range db.EventTypes {
+			if e == t {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return errors.Errorf("unknown event type %q", e)
+		}
+	}
+
+	return nil
+}
+
+// webhookRespBody reveals secret of subscription, it's only returned on creation
+type webhookRespBody struct {
+	*db.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+type webhooksRespBody struct {
+	Webhooks []*db.WebhookSubscription `json:"webhooks"`
+}
+
+type deadLettersRespBody struct {
+	DeadLetters []*db.WebhookDeadLetter `json:"dead_letters"`
+	NextCursor  *int64                  `json:"next_cursor,omitempty"`
+}
+
+// handleWebhooks handles all requests coming on /webhooks route,
+// GET lists subscriptions and POST creates a new one
+func (srv *Server) handleWebhooks(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		subs, err := srv.database.WebhookSubscriptions(r.Context())
+		if err != nil {
+			log.Logger.Err(err).Msg("failed to list webhook subscriptions")
+			writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+
+		writeJSON(rw, http.StatusOK, &webhooksRespBody{Webhooks: subs})
+	case http.MethodPost:
+		var body webhookReqBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(rw, http.StatusBadRequest, "malformed request body")
+			return
+		}
+		if err := body.validate(); err != nil {
+			writeJSONError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// generate a secret if caller didn't provide one, it's revealed only in this response
+		if body.Secret == "" {
+			secret := make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				log.Logger.Err(err).Msg("failed to generate webhook secret")
+				writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			body.Secret = hex.EncodeToString(secret)
+		}
+
+		sub, err := srv.database.CreateWebhookSubscription(r.Context(), body.URL, body.Secret, body.Events)
+		if err != nil {
+			log.Logger.Err(err).Msg("failed to create webhook subscription")
+			writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+
+		writeJSON(rw, http.StatusCreated, &webhookRespBody{WebhookSubscription: sub, Secret: sub.Secret})
+	default:
+		rw.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleWebhook handles all requests coming on /webhooks/:id and /webhooks/dead-letters routes
+func (srv *Server) handleWebhook(rw http.ResponseWriter, r *http.Request) {
+	idRaw := strings.TrimPrefix(r.URL.Path, "/webhooks/")
+	if idRaw == "dead-letters" {
+		srv.handleWebhookDeadLetters(rw, r)
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		rw.Header().Set("Allow", http.MethodDelete)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseInt(idRaw, 10, 64)
+	if err != nil {
+		writeJSONError(rw, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	if err := srv.database.DeleteWebhookSubscription(r.Context(), id); err != nil {
+		if err == db.ErrWebhookSubscriptionNotFound {
+			writeJSONError(rw, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Logger.Err(err).Msg("failed to delete webhook subscription")
+		writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// handleWebhookDeadLetters handles requests on /webhooks/dead-letters route,
+// supported query params are cursor and limit
+func (srv *Server) handleWebhookDeadLetters(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		writeJSONError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	var after int64
+	if v := query.Get("cursor"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeJSONError(rw, http.StatusBadRequest, errInvalidQueryParam("cursor").Error())
+			return
+		}
+		after = n
+	}
+
+	limit := int64(defaultDeadLettersLimit)
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n <= 0 || n > maxObjectsLimit {
+			writeJSONError(rw, http.StatusBadRequest, errInvalidQueryParam("limit").Error())
+			return
+		}
+		limit = n
+	}
+
+	// fetch one more row than requested to know if there is a next page
+	letters, err := srv.database.WebhookDeadLetters(r.Context(), after, int32(limit+1))
+	if err != nil {
+		log.Logger.Err(err).Msg("failed to list webhook dead letters")
+		writeJSONError(rw, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	resp := &deadLettersRespBody{DeadLetters: letters}
+	if int64(len(letters)) > limit {
+		resp.DeadLetters = letters[:limit]
+		resp.NextCursor = &resp.DeadLetters[limit-1].ID
+	}
+
+	writeJSON(rw, http.StatusOK, resp)
+}
@@ -0,0 +1,76 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TimestampHeader holds unix time in seconds when request was signed
+	TimestampHeader = "X-Bitburst-Timestamp"
+
+	// SignatureHeader holds signature of request in form of sha256=<hex encoded hmac>
+	SignatureHeader = "X-Bitburst-Signature"
+
+	prefix = "sha256="
+)
+
+// Sign returns signature of body signed at timestamp, it's an HMAC-SHA256 over "<timestamp>.<body>",
+// so timestamp can't be changed without invalidating the signature
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	// ErrMissingSignature is returned when request doesn't have timestamp or signature header
+	ErrMissingSignature = errors.New("request isn't signed")
+
+	// ErrInvalidTimestamp is returned when timestamp header isn't a unix time
+	ErrInvalidTimestamp = errors.New("invalid signature timestamp")
+
+	// ErrExpiredTimestamp is returned when request was signed too long ago or too far in the future,
+	// so it can be a replay of an old request
+	ErrExpiredTimestamp = errors.New("signature timestamp is outside of tolerance")
+
+	// ErrInvalidSignature is returned when signature doesn't match any of secrets
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Verify checks that body was signed at timestamp with one of secrets, more than one secret can be active
+// while a secret is being rotated. Timestamp must be within tolerance from now, so old requests can't be replayed,
+// tolerance <= 0 disables the check.
+func Verify(secrets [][]byte, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	if tolerance > 0 {
+		skew := now.Sub(time.Unix(ts, 0))
+		if skew > tolerance || skew < -tolerance {
+			return ErrExpiredTimestamp
+		}
+	}
+
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
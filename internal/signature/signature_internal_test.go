@@ -0,0 +1,84 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	secret, body := []byte("secret"), []byte(`{"events":[]}`)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(`1620000000.{"events":[]}`))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, want, Sign(secret, 1620000000, body))
+	assert.NotEqual(t, want, Sign(secret, 1620000001, body), "signature must depend on timestamp")
+	assert.NotEqual(t, want, Sign([]byte("other"), 1620000000, body), "signature must depend on secret")
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1620000000, 0)
+	body := []byte(`{"object_ids":[1,2]}`)
+	secrets := [][]byte{[]byte("new"), []byte("old")}
+
+	tests := map[string]struct {
+		timestamp string
+		signature string
+		wantErr   error
+	}{
+		"signed with current secret": {
+			timestamp: "1620000000",
+			signature: Sign([]byte("new"), 1620000000, body),
+		},
+		"signed with rotated secret": {
+			timestamp: "1620000000",
+			signature: Sign([]byte("old"), 1620000000, body),
+		},
+		"signed within tolerance": {
+			timestamp: "1619999750",
+			signature: Sign([]byte("new"), 1619999750, body),
+		},
+		"missing signature": {
+			timestamp: "1620000000",
+			wantErr:   ErrMissingSignature,
+		},
+		"invalid timestamp": {
+			timestamp: "yesterday",
+			signature: Sign([]byte("new"), 1620000000, body),
+			wantErr:   ErrInvalidTimestamp,
+		},
+		"replayed request": {
+			timestamp: "1619999000",
+			signature: Sign([]byte("new"), 1619999000, body),
+			wantErr:   ErrExpiredTimestamp,
+		},
+		"timestamp from the future": {
+			timestamp: "1620001000",
+			signature: Sign([]byte("new"), 1620001000, body),
+			wantErr:   ErrExpiredTimestamp,
+		},
+		"unknown secret": {
+			timestamp: "1620000000",
+			signature: Sign([]byte("unknown"), 1620000000, body),
+			wantErr:   ErrInvalidSignature,
+		},
+		"changed timestamp": {
+			timestamp: "1620000001",
+			signature: Sign([]byte("new"), 1620000000, body),
+			wantErr:   ErrInvalidSignature,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(secrets, tc.timestamp, tc.signature, body, now, 5*time.Minute)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package webhook
+
+import (
+	"bitburst-assessment-task/internal/backoff"
+	"bitburst-assessment-task/internal/db"
+	"bitburst-assessment-task/internal/metrics"
+	"bitburst-assessment-task/internal/signature"
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Config holds configuration of webhooks delivery
+type Config struct {
+	// Workers is an amount of event batches that are delivered concurrently
+	Workers int `mapstructure:"workers"`
+
+	// QueueSize is a max amount of event batches waiting for delivery, batches that don't fit are saved as dead letters
+	QueueSize int `mapstructure:"queue_size"`
+
+	// Timeout is a timeout of one delivery attempt
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// retry policy of failed deliveries, deliveries that fail after MaxAttempts end up in dead letters
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	BaseBackoff time.Duration `mapstructure:"base_backoff"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+}
+
+// Store is a storage of webhook subscriptions and failed deliveries
+type Store interface {
+	WebhookSubscriptions(ctx context.Context) ([]*db.WebhookSubscription, error)
+	SaveWebhookDeadLetter(ctx context.Context, subscriptionID int64, payload []byte, attempts int, lastErr error) error
+}
+
+// deadLetterTimeout is a timeout of saving dead letters
+const deadLetterTimeout = 5 * time.Second
+
+// last errors of events that are saved as dead letters without delivery attempts
+var (
+	errQueueFull    = errors.New("webhooks queue is full")
+	errShuttingDown = errors.New("webhooks dispatcher is shutting down")
+)
+
+// Payload is a body of webhook request
+type Payload struct {
+	SubscriptionID int64      `json:"w_id"`
+	Events         []db.Event `json:"events"`
+	SentAt         time.Time  `json:"sent_at"`
+}
+
+// Dispatcher delivers object events to webhook subscriptions
+type Dispatcher struct {
+	c *http.Client
+
+	store Store
+	queue chan []db.Event
+
+	// stopped is set when Run stops delivering, after that events aren't queued anymore
+	mu      sync.RWMutex
+	stopped bool
+
+	// overflow limits amount of batches that are saved as dead letters concurrently,
+	// overflowWG tracks them, so Run waits for them before it returns
+	overflow   chan struct{}
+	overflowWG sync.WaitGroup
+
+	conf *Config
+
+	logger *zerolog.Logger
+}
+
+// New constructs new webhooks dispatcher
+func New(conf *Config, store Store, logger *zerolog.Logger) *Dispatcher {
+	d := &Dispatcher{}
+
+	d.c = &http.Client{
+		Timeout: conf.Timeout,
+	}
+
+	if conf.Workers <= 0 {
+		conf.Workers = 1
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = 1
+	}
+
+	d.store = store
+	d.queue = make(chan []db.Event, conf.QueueSize)
+	d.overflow = make(chan struct{}, conf.QueueSize+1)
+	d.conf = conf
+
+	subLogger := logger.With().Str("component", "webhook").Logger()
+	d.logger = &subLogger
+
+	return d
+}
+
+// Notify queues events for delivery, it's called by storage synchronously, so it doesn't block.
+// Events that don't fit into the queue, or come after Run stopped, are saved as dead letters in background.
+func (d *Dispatcher) Notify(events []db.Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	err := errShuttingDown
+	if !d.stopped {
+		select {
+		case d.queue <- events:
+			return
+		default:
+			err = errQueueFull
+		}
+	}
+
+	reason := "queue_full"
+	if err == errShuttingDown {
+		reason = "shutdown"
+	}
+	metrics.WebhookEventsDropped.WithLabelValues(reason).Add(float64(len(events)))
+
+	select {
+	case d.overflow <- struct{}{}:
+	default:
+		d.logger.Error().Err(err).Int("count", len(events)).Msg("too many events are being saved as dead letters, events are lost")
+		metrics.WebhookEventsLost.Add(float64(len(events)))
+		return
+	}
+
+	d.logger.Warn().Err(err).Int("count", len(events)).Msg("failed to queue events, saving them as dead letters")
+
+	// Run waits only for batches that were dropped before it stopped
+	if !d.stopped {
+		d.overflowWG.Add(1)
+	}
+	go func(tracked bool) {
+		defer func() { <-d.overflow }()
+		if tracked {
+			defer d.overflowWG.Done()
+		}
+
+		d.deadLetter(events, err)
+	}(!d.stopped)
+}
+
+// Run delivers queued events, it's to run in background.
+// When a job is done on behalf of this function, context should be canceled.
+// Events that are still queued then are saved as dead letters, and Run returns once they are saved.
+func (d *Dispatcher) Run(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < d.conf.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case events := <-d.queue:
+					d.dispatch(ctx, events)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// after that Notify doesn't use the queue
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+
+	for {
+		select {
+		case events := <-d.queue:
+			d.logger.Warn().Int("count", len(events)).Msg("webhooks dispatcher is stopped, saving queued events as dead letters")
+			metrics.WebhookEventsDropped.WithLabelValues("shutdown").Add(float64(len(events)))
+			d.deadLetter(events, errShuttingDown)
+		default:
+			d.overflowWG.Wait()
+			return
+		}
+	}
+}
+
+// dispatch delivers events to every subscription that wants them
+func (d *Dispatcher) dispatch(ctx context.Context, events []db.Event) {
+	subs, err := d.subscriptions(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			d.logger.Warn().Int("count", len(events)).Msg("webhooks dispatcher is stopped, saving events as dead letters")
+			metrics.WebhookEventsDropped.WithLabelValues("shutdown").Add(float64(len(events)))
+			d.deadLetter(events, errShuttingDown)
+			return
+		}
+
+		d.logger.Warn().Err(err).Int("count", len(events)).Msg("failed to get webhook subscriptions, saving events as dead letters")
+		metrics.WebhookEventsDropped.WithLabelValues("subscriptions").Add(float64(len(events)))
+		d.deadLetter(events, errors.WithMessage(err, "failed to get webhook subscriptions"))
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, sub := range subs {
+		payload := newPayload(sub, events)
+		if payload == nil {
+			continue
+		}
+
+		// deliver to every subscription concurrently, so a slow subscriber doesn't hold up the others
+		wg.Add(1)
+		go func(sub *db.WebhookSubscription) {
+			defer wg.Done()
+
+			d.deliver(ctx, sub, payload)
+		}(sub)
+	}
+
+	wg.Wait()
+}
+
+// subscriptions loads webhook subscriptions retrying failed attempts with the same policy as deliveries
+func (d *Dispatcher) subscriptions(ctx context.Context) ([]*db.WebhookSubscription, error) {
+	for attempt := 1; ; attempt++ {
+		subs, err := d.store.WebhookSubscriptions(ctx)
+		if err == nil {
+			return subs, nil
+		}
+
+		if attempt >= d.conf.MaxAttempts || !backoff.Sleep(ctx, backoff.Exponential(d.conf.BaseBackoff, d.conf.MaxBackoff, 0.5, attempt+1)) {
+			return nil, err
+		}
+		d.logger.Debug().Err(err).Int("attempt", attempt+1).Msg("retrying loading of webhook subscriptions")
+	}
+}
+
+// deadLetter saves events as dead letters of every subscription that wants them without delivery attempts
+func (d *Dispatcher) deadLetter(events []db.Event, lastErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+
+	subs, err := d.store.WebhookSubscriptions(ctx)
+	if err != nil {
+		d.logger.Err(err).Int("count", len(events)).Msg("failed to get webhook subscriptions, events are lost")
+		metrics.WebhookEventsLost.Add(float64(len(events)))
+		return
+	}
+
+	for _, sub := range subs {
+		payload := newPayload(sub, events)
+		if payload == nil {
+			continue
+		}
+
+		logger := d.logger.With().Int64("subscription_id", sub.ID).Logger()
+
+		payload.SentAt = time.Now().UTC()
+		body, err := json.Marshal(payload)
+		if err != nil {
+			logger.Err(err).Msg("failed to encode webhook payload")
+			continue
+		}
+
+		if err := d.store.SaveWebhookDeadLetter(ctx, sub.ID, body, 0, lastErr); err != nil {
+			logger.Err(err).Msg("failed to save webhook dead letter")
+			metrics.WebhookEventsLost.Add(float64(len(payload.Events)))
+		}
+	}
+}
+
+// newPayload returns payload with events that subscription wants, or nil if it wants none of them
+func newPayload(sub *db.WebhookSubscription, events []db.Event) *Payload {
+	payload := &Payload{SubscriptionID: sub.ID, Events: make([]db.Event, 0, len(events))}
+	for _, e := range events {
+		if sub.Wants(e.Type) {
+			payload.Events = append(payload.Events, e)
+		}
+	}
+	if len(payload.Events) == 0 {
+		return nil
+	}
+
+	return payload
+}
+
+// deliver sends payload to subscription retrying failed attempts,
+// if all attempts fail payload is saved as a dead letter
+func (d *Dispatcher) deliver(ctx context.Context, sub *db.WebhookSubscription, payload *Payload) {
+	logger := d.logger.With().Int64("subscription_id", sub.ID).Logger()
+
+	payload.SentAt = time.Now().UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Err(err).Msg("failed to encode webhook payload")
+		return
+	}
+
+	var attempt int
+	for attempt = 1; ; attempt++ {
+		err = d.send(ctx, sub, body)
+		if err == nil {
+			logger.Debug().Int("attempts", attempt).Int("count", len(payload.Events)).Msg("delivered webhook")
+			return
+		}
+
+		if attempt >= d.conf.MaxAttempts || !backoff.Sleep(ctx, backoff.Exponential(d.conf.BaseBackoff, d.conf.MaxBackoff, 0.5, attempt+1)) {
+			break
+		}
+		logger.Debug().Err(err).Int("attempt", attempt+1).Msg("retrying webhook delivery")
+	}
+
+	logger.Warn().Err(err).Int("attempts", attempt).Msg("failed to deliver webhook, saving it as dead letter")
+
+	// context can be already canceled on shutdown, so use a fresh one
+	dlCtx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+
+	if err := d.store.SaveWebhookDeadLetter(dlCtx, sub.ID, body, attempt, err); err != nil {
+		logger.Err(err).Msg("failed to save webhook dead letter")
+		metrics.WebhookEventsLost.Add(float64(len(payload.Events)))
+	}
+}
+
+// send makes one signed delivery attempt
+func (d *Dispatcher) send(ctx context.Context, sub *db.WebhookSubscription, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithMessage(err, "failed to construct request")
+	}
+
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signature.TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(signature.SignatureHeader, signature.Sign([]byte(sub.Secret), ts, body))
+
+	resp, err := d.c.Do(req)
+	if err != nil {
+		return errors.WithMessage(err, "failed to send request")
+	}
+	if err := resp.Body.Close(); err != nil {
+		d.logger.Warn().Err(err).Msg("failed to close response body")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
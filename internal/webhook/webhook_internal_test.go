@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"bitburst-assessment-task/internal/db"
+	"bitburst-assessment-task/internal/signature"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubStore keeps subscriptions and dead letters in memory
+type stubStore struct {
+	subs []*db.WebhookSubscription
+
+	// subsErrs is an amount of the next loads of subscriptions that fail,
+	// saving of dead letters waits until release is closed if it's set
+	subsErrs int
+	release  chan struct{}
+
+	mu          sync.Mutex
+	deadLetters []int64
+}
+
+func (s *stubStore) WebhookSubscriptions(ctx context.Context) ([]*db.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subsErrs > 0 {
+		s.subsErrs--
+		return nil, errors.New("database is unavailable")
+	}
+	return s.subs, nil
+}
+
+func (s *stubStore) SaveWebhookDeadLetter(ctx context.Context, subscriptionID int64, payload []byte, attempts int, lastErr error) error {
+	if s.release != nil {
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = append(s.deadLetters, subscriptionID)
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	logger := zerolog.Nop()
+
+	events := []db.Event{
+		{Type: db.EventObjectOnline, ObjectID: 1, OccurredAt: time.Now()},
+		{Type: db.EventObjectExpired, ObjectID: 2, OccurredAt: time.Now()},
+	}
+
+	tests := map[string]struct {
+		status       int
+		subEvents    []db.EventType
+		wantAttempts int32
+		wantEvents   int
+		wantDead     bool
+	}{
+		"delivered": {
+			status:       http.StatusOK,
+			wantAttempts: 1,
+			wantEvents:   2,
+		},
+		"filtered by event type": {
+			status:       http.StatusNoContent,
+			subEvents:    []db.EventType{db.EventObjectExpired},
+			wantAttempts: 1,
+			wantEvents:   1,
+		},
+		"not wanted": {
+			status:    http.StatusOK,
+			subEvents: []db.EventType{db.EventObjectOffline},
+		},
+		"dead letter after retries": {
+			status:       http.StatusInternalServerError,
+			wantAttempts: 3,
+			wantEvents:   2,
+			wantDead:     true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				ts, err := strconv.ParseInt(r.Header.Get(signature.TimestampHeader), 10, 64)
+				require.NoError(t, err)
+				assert.Equal(t, signature.Sign([]byte("secret"), ts, body), r.Header.Get(signature.SignatureHeader))
+
+				var payload Payload
+				require.NoError(t, json.Unmarshal(body, &payload))
+				assert.Equal(t, int64(7), payload.SubscriptionID)
+				assert.Len(t, payload.Events, tc.wantEvents)
+
+				rw.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			store := &stubStore{subs: []*db.WebhookSubscription{{ID: 7, URL: srv.URL, Secret: "secret", Events: tc.subEvents}}}
+			d := New(&Config{
+				Timeout:     time.Second,
+				MaxAttempts: 3,
+				BaseBackoff: time.Millisecond,
+				MaxBackoff:  time.Millisecond * 10,
+			}, store, &logger)
+
+			d.dispatch(context.Background(), events)
+
+			assert.Equal(t, tc.wantAttempts, atomic.LoadInt32(&attempts))
+			if tc.wantDead {
+				assert.Equal(t, []int64{7}, store.deadLetters)
+			} else {
+				assert.Empty(t, store.deadLetters)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	logger := zerolog.Nop()
+	store := &stubStore{subs: []*db.WebhookSubscription{{ID: 7}}, release: make(chan struct{})}
+	d := New(&Config{QueueSize: 1}, store, &logger)
+
+	// second batch doesn't fit into the queue and must be saved as dead letter without blocking
+	d.Notify([]db.Event{{Type: db.EventObjectOnline, ObjectID: 1}})
+	d.Notify([]db.Event{{Type: db.EventObjectOnline, ObjectID: 2}})
+
+	require.Len(t, d.queue, 1)
+	assert.Equal(t, int32(1), (<-d.queue)[0].ObjectID)
+
+	close(store.release)
+	d.overflowWG.Wait()
+	assert.Equal(t, []int64{7}, store.deadLetters)
+}
+
+func TestDispatchSubscriptionsFailure(t *testing.T) {
+	logger := zerolog.Nop()
+
+	var delivered int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&delivered, 1)
+	}))
+	defer srv.Close()
+
+	events := []db.Event{{Type: db.EventObjectOnline, ObjectID: 1}}
+	conf := &Config{Timeout: time.Second, MaxAttempts: 2, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	// transient failure is retried
+	store := &stubStore{subs: []*db.WebhookSubscription{{ID: 7, URL: srv.URL}}, subsErrs: 1}
+	New(conf, store, &logger).dispatch(context.Background(), events)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&delivered))
+	assert.Empty(t, store.deadLetters)
+
+	// events are saved as dead letters once retries are exhausted
+	store = &stubStore{subs: []*db.WebhookSubscription{{ID: 7, URL: srv.URL}}, subsErrs: 2}
+	New(conf, store, &logger).dispatch(context.Background(), events)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&delivered))
+	assert.Equal(t, []int64{7}, store.deadLetters)
+}
+
+func TestRunShutdown(t *testing.T) {
+	logger := zerolog.Nop()
+	store := &stubStore{subs: []*db.WebhookSubscription{{ID: 7}, {ID: 8, Events: []db.EventType{db.EventObjectExpired}}}}
+	d := New(&Config{QueueSize: 2}, store, &logger)
+
+	// events queued before shutdown aren't delivered and must be saved as dead letters
+	d.Notify([]db.Event{{Type: db.EventObjectOnline, ObjectID: 1}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.Run(ctx)
+
+	assert.Empty(t, d.queue)
+	assert.Equal(t, []int64{7}, store.deadLetters)
+
+	// events that come after shutdown aren't queued, they are saved in background
+	d.Notify([]db.Event{{Type: db.EventObjectExpired, ObjectID: 2}})
+
+	assert.Empty(t, d.queue)
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.deadLetters) == 3
+	}, time.Second, time.Millisecond*5)
+	assert.ElementsMatch(t, []int64{7, 7, 8}, store.deadLetters)
+}
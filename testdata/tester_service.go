@@ -2,6 +2,10 @@ package main
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -16,6 +20,9 @@ import (
 // serviceAddr is needed to test using docker compose
 var serviceAddr = flag.String("service-addr", "localhost:9090", "address of test service")
 
+// secret signs callbacks, it must be one of server callback secrets
+var secret = flag.String("secret", "", "secret that callbacks are signed with, callbacks aren't signed if it's empty")
+
 func main() {
 	flag.Parse()
 
@@ -31,8 +38,21 @@ func main() {
 			for i := range ids {
 				ids[i] = strconv.Itoa(rng.Int() % 100)
 			}
-			body := bytes.NewBuffer([]byte(fmt.Sprintf(`{"object_ids":[%s]}`, strings.Join(ids, ","))))
-			resp, err := client.Post(fmt.Sprintf("http://%s/callback", *serviceAddr), "application/json", body)
+			body := []byte(fmt.Sprintf(`{"object_ids":[%s]}`, strings.Join(ids, ",")))
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/callback", *serviceAddr), bytes.NewReader(body))
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if *secret != "" {
+				ts := time.Now().Unix()
+				mac := hmac.New(sha256.New, []byte(*secret))
+				mac.Write([]byte(fmt.Sprintf("%d.%s", ts, body)))
+				req.Header.Set("X-Bitburst-Timestamp", strconv.FormatInt(ts, 10))
+				req.Header.Set("X-Bitburst-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+			}
+			resp, err := client.Do(req)
 			if err != nil {
 				fmt.Println(err)
 				continue
@@ -53,6 +73,29 @@ func main() {
 
 		w.Write([]byte(fmt.Sprintf(`{"id":%d,"online":%v}`, id, id%2 == 0)))
 	})
+	http.HandleFunc("/objects:batchGet", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body struct {
+			IDs []int `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		time.Sleep(time.Duration(rng.Int63n(4000)+300) * time.Millisecond)
+
+		objs := make([]string, len(body.IDs))
+		for i, id := range body.IDs {
+			objs[i] = fmt.Sprintf(`{"id":%d,"online":%v}`, id, id%2 == 0)
+		}
+
+		w.Write([]byte(fmt.Sprintf(`{"objects":[%s]}`, strings.Join(objs, ","))))
+	})
 	go func() { _ = http.ListenAndServe(":9010", nil) }()
 
 	sig := make(chan os.Signal, 1)